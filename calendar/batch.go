@@ -0,0 +1,104 @@
+package calendar
+
+import (
+	"context"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// MaxBatchSize is the largest number of calls Google accepts in one batch request
+const MaxBatchSize = 50
+
+// BatchMethod is the kind of write in a batch
+type BatchMethod string
+
+const (
+	BatchCreate BatchMethod = "create"
+	BatchUpdate BatchMethod = "update"
+	BatchDelete BatchMethod = "delete"
+	BatchCancel BatchMethod = "cancel"
+)
+
+// BatchOp is one write in a batch request
+type BatchOp struct {
+	Method  BatchMethod
+	EventID string                // Existing event, for update, delete and cancel
+	Event   *models.CalendarEvent // New contents, for create and update
+	Props   map[string]string     // Private properties, for create and update
+}
+
+// BatchResult is the outcome of one BatchOp
+type BatchResult struct {
+	Remote *RemoteEvent // Created or updated event; nil for delete and cancel
+	Err    error
+}
+
+// Batcher is implemented by providers that can send several writes in a single
+// request. Batch returns one result per op, in order; an error means the batch
+// as a whole failed and none of its results are known.
+type Batcher interface {
+	Batch(ctx context.Context, calendarID string, ops []BatchOp) ([]BatchResult, error)
+}
+
+// applyBatch sends the ops that need a call as one batch request. Ops that fail
+// inside the batch, or all of them if the request itself fails, are retried as
+// single calls; events that no longer exist are reported as is. A batch that
+// fails as a whole may have been carried out in part, so it is only resent
+// when rate limited, and its creates look for their event before retrying.
+func (c *Client) applyBatch(ctx context.Context, batcher Batcher, ops []writeOp) []*EventResult {
+	results := make([]*EventResult, len(ops))
+
+	var batch []BatchOp
+	var pending []int
+	for i, op := range ops {
+		if op.method == "" {
+			results[i] = c.opResult(op, op.remote, nil)
+			continue
+		}
+
+		batchOp := BatchOp{Method: op.method, EventID: op.eventID, Event: op.event}
+		if op.event != nil && (op.method == BatchCreate || op.method == BatchUpdate) {
+			batchOp.Props = c.properties(op.event)
+		}
+		batch = append(batch, batchOp)
+		pending = append(pending, i)
+	}
+
+	if len(batch) < 2 {
+		for _, i := range pending {
+			results[i] = c.apply(ctx, ops[i])
+		}
+		return results
+	}
+
+	// Every call in a batch counts against the quota, so take a token for each
+	for range batch[1:] {
+		if err := c.limiter.Wait(ctx); err != nil {
+			for _, i := range pending {
+				results[i] = c.skip(ops[i], err)
+			}
+			return results
+		}
+	}
+
+	var replies []BatchResult
+	err := c.callRetrying(ctx, isRateLimited, func() (err error) {
+		replies, err = batcher.Batch(ctx, c.calendarID, batch)
+		return err
+	})
+
+	for j, i := range pending {
+		switch {
+		case err == nil && replies[j].Err == nil:
+			// The event is written; failing overrides must not create it again
+			results[i] = c.opResult(ops[i], replies[j].Remote, c.finishOverrides(ctx, replies[j].Remote, ops[i].event, ops[i].remote))
+		case err == nil && IsNotFound(replies[j].Err):
+			results[i] = c.opResult(ops[i], nil, replies[j].Err)
+		case err != nil && ops[i].method == BatchCreate:
+			results[i], _ = c.createEvent(ctx, ops[i].event, true)
+		default:
+			results[i] = c.apply(ctx, ops[i])
+		}
+	}
+	return results
+}
@@ -0,0 +1,302 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// CalDAVProvider stores events on a CalDAV server (Radicale, Nextcloud, iCloud, ...).
+// Calendar IDs are collection URLs, absolute or relative to the configured URL;
+// "primary" is the configured URL itself. Event IDs are resource paths.
+type CalDAVProvider struct {
+	baseURL    *url.URL
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// HTTPError is returned when a server answers with an unexpected status
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// NewCalDAVProvider creates a provider for the calendar collection or calendar
+// home set at rawURL
+func NewCalDAVProvider(rawURL, username, password string) (*CalDAVProvider, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("the %s backend requires --caldav-url", BackendCalDAV)
+	}
+
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CalDAV URL: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	return &CalDAVProvider{
+		baseURL:    base,
+		username:   username,
+		password:   password,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+// multistatus is the body of a WebDAV 207 Multi-Status response
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				DisplayName  string `xml:"DAV: displayname"`
+				ResourceType struct {
+					Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+				} `xml:"DAV: resourcetype"`
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const propfindCalendars = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:displayname/><d:resourcetype/></d:prop>
+</d:propfind>`
+
+// ListCalendars lists the calendar collections at, and directly below, the configured URL
+func (p *CalDAVProvider) ListCalendars(ctx context.Context) ([]CalendarInfo, error) {
+	var ms multistatus
+	if err := p.davRequest(ctx, "PROPFIND", p.baseURL, "1", propfindCalendars, &ms); err != nil {
+		return nil, err
+	}
+
+	var calendars []CalendarInfo
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if ps.Prop.ResourceType.Calendar == nil {
+				continue
+			}
+			info := CalendarInfo{
+				ID:      resp.Href,
+				Summary: ps.Prop.DisplayName,
+				Primary: strings.TrimSuffix(resp.Href, "/") == strings.TrimSuffix(p.baseURL.Path, "/"),
+			}
+			if info.Summary == "" {
+				info.Summary = resp.Href
+			}
+			calendars = append(calendars, info)
+		}
+	}
+	return calendars, nil
+}
+
+// ListEvents runs a calendar-query REPORT for the VEVENTs in the window
+func (p *CalDAVProvider) ListEvents(ctx context.Context, calendarID string, opts ListOptions) ([]*RemoteEvent, error) {
+	collection, err := p.collectionURL(calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeRange := ""
+	if !opts.TimeMin.IsZero() || !opts.TimeMax.IsZero() {
+		timeRange = "<c:time-range"
+		if !opts.TimeMin.IsZero() {
+			timeRange += ` start="` + opts.TimeMin.UTC().Format("20060102T150405Z") + `"`
+		}
+		if !opts.TimeMax.IsZero() {
+			timeRange += ` end="` + opts.TimeMax.UTC().Format("20060102T150405Z") + `"`
+		}
+		timeRange += "/>"
+	}
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">` + timeRange + `</c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+
+	var ms multistatus
+	if err := p.davRequest(ctx, "REPORT", collection, "1", body, &ms); err != nil {
+		return nil, err
+	}
+
+	var events []*RemoteEvent
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if ps.Prop.CalendarData == "" {
+				continue
+			}
+			_, remote, err := decodeICS([]byte(ps.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", resp.Href, err)
+			}
+			if !opts.matches(remote) {
+				continue
+			}
+			remote.ID = resp.Href
+			remote.Link = p.resolve(resp.Href).String()
+			events = append(events, remote)
+		}
+	}
+	return events, nil
+}
+
+// CreateEvent stores a new calendar object resource in the collection
+func (p *CalDAVProvider) CreateEvent(ctx context.Context, calendarID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	collection, err := p.collectionURL(calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := newUID()
+	target := collection.JoinPath(strings.TrimSuffix(uid, "@calendar-generator") + ".ics")
+	if err := p.put(ctx, target, encodeICS(uid, event, props), true); err != nil {
+		return nil, err
+	}
+
+	return &RemoteEvent{ID: target.Path, Link: target.String(), Event: *event, Properties: props}, nil
+}
+
+// UpdateEvent replaces an existing calendar object resource, keeping its UID
+func (p *CalDAVProvider) UpdateEvent(ctx context.Context, calendarID, eventID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	target := p.resolve(eventID)
+
+	current, err := p.get(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	uid, _, err := decodeICS(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.put(ctx, target, encodeICS(uid, event, props), false); err != nil {
+		return nil, err
+	}
+
+	return &RemoteEvent{ID: target.Path, Link: target.String(), Event: *event, Properties: props}, nil
+}
+
+// DeleteEvent removes a calendar object resource
+func (p *CalDAVProvider) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	resp, err := p.do(ctx, http.MethodDelete, p.resolve(eventID), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// collectionURL resolves a calendar ID to a collection URL
+func (p *CalDAVProvider) collectionURL(calendarID string) (*url.URL, error) {
+	if calendarID == "" || calendarID == "primary" {
+		return p.baseURL, nil
+	}
+
+	ref, err := url.Parse(calendarID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid calendar ID %s: %w", calendarID, err)
+	}
+	collection := p.baseURL.ResolveReference(ref)
+	if !strings.HasSuffix(collection.Path, "/") {
+		collection.Path += "/"
+	}
+	return collection, nil
+}
+
+// resolve resolves an href returned by the server against the configured URL
+func (p *CalDAVProvider) resolve(href string) *url.URL {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return p.baseURL.JoinPath(href)
+	}
+	return p.baseURL.ResolveReference(ref)
+}
+
+func (p *CalDAVProvider) get(ctx context.Context, target *url.URL) ([]byte, error) {
+	resp, err := p.do(ctx, http.MethodGet, target, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (p *CalDAVProvider) put(ctx context.Context, target *url.URL, data []byte, create bool) error {
+	header := http.Header{"Content-Type": {"text/calendar; charset=utf-8"}}
+	if create {
+		header.Set("If-None-Match", "*")
+	}
+
+	resp, err := p.do(ctx, http.MethodPut, target, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// davRequest sends a WebDAV request with an XML body and decodes the multistatus reply
+func (p *CalDAVProvider) davRequest(ctx context.Context, method string, target *url.URL, depth, body string, ms *multistatus) error {
+	header := http.Header{
+		"Content-Type": {"application/xml; charset=utf-8"},
+		"Depth":        {depth},
+	}
+
+	resp, err := p.do(ctx, method, target, header, []byte(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := xml.NewDecoder(resp.Body).Decode(ms); err != nil {
+		return fmt.Errorf("invalid %s response: %w", method, err)
+	}
+	return nil
+}
+
+// do sends an authenticated request, turning error statuses into an HTTPError
+func (p *CalDAVProvider) do(ctx context.Context, method string, target *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		httpErr := &HTTPError{Method: method, URL: target.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, httpErr)
+		}
+		return nil, httpErr
+	}
+	return resp, nil
+}
@@ -0,0 +1,312 @@
+package calendar
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// davServer is a minimal CalDAV server in the manner of Radicale: a calendar
+// home at /cal/ holding calendar collections, each holding .ics resources
+type davServer struct {
+	mu          sync.Mutex
+	collections map[string]string // Display name by collection path
+	objects     map[string][]byte // ICS data by resource path
+	failPuts    int               // Number of PUTs still to fail with 503
+}
+
+const (
+	davUser     = "alice"
+	davPassword = "secret"
+)
+
+func newDAVServer(t *testing.T) (*davServer, *CalDAVProvider) {
+	t.Helper()
+
+	s := &davServer{
+		collections: map[string]string{"/cal/personal/": "Personal", "/cal/work/": "Work"},
+		objects:     make(map[string][]byte),
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	p, err := NewCalDAVProvider(srv.URL+"/cal", davUser, davPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, p
+}
+
+func (s *davServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != davUser || password != davPassword {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Radicale"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "PROPFIND":
+		s.propfind(w, r)
+	case "REPORT":
+		s.report(w, r)
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(data)
+	case http.MethodPut:
+		s.put(w, r)
+	case http.MethodDelete:
+		if _, ok := s.objects[r.URL.Path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// davResponse is one response of a multistatus reply
+type davResponse struct {
+	href, props string
+}
+
+// propfind lists the collection at the request path and, at depth 1, the
+// collections directly below it. Only /cal/ and the calendars are collections.
+func (s *davServer) propfind(w http.ResponseWriter, r *http.Request) {
+	var responses []davResponse
+	if r.URL.Path == "/cal/" {
+		responses = append(responses, davResponse{"/cal/", "<d:resourcetype><d:collection/></d:resourcetype>"})
+	} else if _, ok := s.collections[r.URL.Path]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	for href, name := range s.collections {
+		if href == r.URL.Path || (r.Header.Get("Depth") == "1" && path.Dir(strings.TrimSuffix(href, "/"))+"/" == r.URL.Path) {
+			responses = append(responses, davResponse{href, fmt.Sprintf(
+				"<d:displayname>%s</d:displayname><d:resourcetype><d:collection/><c:calendar/></d:resourcetype>", name)})
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+// report answers a calendar-query with every resource of the collection. The
+// time range is not applied; the provider filters the events itself.
+func (s *davServer) report(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if _, ok := s.collections[r.URL.Path]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !strings.Contains(string(body), "calendar-query") || r.Header.Get("Depth") != "1" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var responses []davResponse
+	for href, data := range s.objects {
+		if path.Dir(href)+"/" == r.URL.Path {
+			var escaped strings.Builder
+			xml.EscapeText(&escaped, data)
+			responses = append(responses, davResponse{href, `<d:getetag>"1"</d:getetag><c:calendar-data>` + escaped.String() + "</c:calendar-data>"})
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+func (s *davServer) put(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.collections[path.Dir(r.URL.Path)+"/"]; !ok {
+		http.Error(w, "Conflict", http.StatusConflict)
+		return
+	}
+	if s.failPuts > 0 {
+		s.failPuts--
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	_, exists := s.objects[r.URL.Path]
+	if exists && r.Header.Get("If-None-Match") == "*" {
+		http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	s.objects[r.URL.Path] = data
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	sort.Slice(responses, func(i, j int) bool { return responses[i].href < responses[j].href })
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+	for _, resp := range responses {
+		fmt.Fprintf(w, "<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>", resp.href, resp.props)
+	}
+	fmt.Fprint(w, "</d:multistatus>")
+}
+
+// resources returns the paths of the resources in a collection, sorted
+func (s *davServer) resources(collection string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var paths []string
+	for href := range s.objects {
+		if path.Dir(href)+"/" == collection {
+			paths = append(paths, href)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *davServer) uid(href string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, _, _ := decodeICS(s.objects[href])
+	return uid
+}
+
+func TestCalDAVListCalendars(t *testing.T) {
+	_, p := newDAVServer(t)
+
+	calendars, err := p.ListCalendars(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CalendarInfo{
+		{ID: "/cal/personal/", Summary: "Personal"},
+		{ID: "/cal/work/", Summary: "Work"},
+	}
+	if fmt.Sprint(calendars) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", calendars, want)
+	}
+}
+
+func TestCalDAVUpsert(t *testing.T) {
+	ctx := context.Background()
+	s, p := newDAVServer(t)
+	client := NewClientWithProvider(p, "personal")
+	client.SetRateLimit(0)
+	client.SetSource("/templates/test.yaml")
+	events := testEvents(2)
+
+	results, err := client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionCreated, ActionCreated}; !equalActions(got, want) {
+		t.Errorf("first run: got %v, want %v", got, want)
+	}
+	resources := s.resources("/cal/personal/")
+	if len(resources) != 2 {
+		t.Fatalf("collection has %d resources, want 2", len(resources))
+	}
+	if n := len(s.resources("/cal/work/")); n != 0 {
+		t.Errorf("other collection has %d resources, want 0", n)
+	}
+
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUnchanged}; !equalActions(got, want) {
+		t.Errorf("second run: got %v, want %v", got, want)
+	}
+
+	// An update rewrites the resource in place, keeping its UID
+	updated := results[1].Remote.ID
+	uid := s.uid(updated)
+	events[1].Location = "Room 2"
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUpdated}; !equalActions(got, want) {
+		t.Errorf("after an edit: got %v, want %v", got, want)
+	}
+	if got := s.uid(updated); got != uid {
+		t.Errorf("UID changed from %s to %s", uid, got)
+	}
+
+	remote, err := client.ListEvents(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range remote {
+		if e.ID == updated && e.Event.Location != "Room 2" {
+			t.Errorf("updated event has location %q, want %q", e.Event.Location, "Room 2")
+		}
+	}
+
+	// Dropping an event from the template orphans it; pruning deletes it
+	plan, err := client.Plan(ctx, events[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, client.RemoveOrphans(ctx, plan, false, nil)), []EventAction{ActionDeleted}; !equalActions(got, want) {
+		t.Errorf("pruning: got %v, want %v", got, want)
+	}
+	if got := s.resources("/cal/personal/"); len(got) != 1 || got[0] == updated {
+		t.Errorf("collection has %v after pruning, want only the first event", got)
+	}
+}
+
+func TestCalDAVErrors(t *testing.T) {
+	ctx := context.Background()
+	s, p := newDAVServer(t)
+
+	if err := p.DeleteEvent(ctx, "personal", "/cal/personal/missing.ics"); !IsNotFound(err) {
+		t.Errorf("deleting a missing event: got %v, want ErrNotFound", err)
+	}
+	if _, err := p.UpdateEvent(ctx, "personal", "/cal/personal/missing.ics", &testEvents(1)[0], nil); !IsNotFound(err) {
+		t.Errorf("updating a missing event: got %v, want ErrNotFound", err)
+	}
+
+	wrong, err := NewCalDAVProvider(strings.TrimSuffix(p.baseURL.String(), "/"), davUser, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var httpErr *HTTPError
+	if _, err := wrong.ListCalendars(ctx); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: got %v, want a 401 HTTPError", err)
+	}
+
+	// Server errors are retried by the client
+	s.failPuts = 2
+	client := NewClientWithProvider(p, "personal")
+	client.SetRateLimit(0)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	if _, err := client.CreateEvent(ctx, &testEvents(1)[0]); err != nil {
+		t.Errorf("creating after two server errors: %v", err)
+	}
+	if n := len(s.resources("/cal/personal/")); n != 1 {
+		t.Errorf("collection has %d resources, want 1", n)
+	}
+}
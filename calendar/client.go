@@ -3,40 +3,65 @@ package calendar
 import (
 	"context"
 	"fmt"
-
-	"google.golang.org/api/calendar/v3"
+	"math"
+	"path/filepath"
 )
 
-// Client wraps the Google Calendar service with helper methods
+// Client writes events to a calendar backend with helper methods
 type Client struct {
-	service    *calendar.Service
+	provider   Provider
 	calendarID string
+	source     string
+	workers    int
+	batchSize  int
+	limiter    *RateLimiter
+	retry      RetryPolicy
 }
 
-// NewClient creates a new Calendar client
+// Defaults for the concurrency and rate limit of a new client. Google allows
+// bursts of roughly ten requests per second per user.
+const (
+	DefaultWorkers = 4
+	DefaultQPS     = 5
+)
+
+// NewClient creates a new Google Calendar client
 func NewClient(ctx context.Context, credentialsPath, tokenPath, calendarID string) (*Client, error) {
-	srv, err := GetCalendarService(ctx, credentialsPath, tokenPath)
+	provider, err := NewGoogleProvider(ctx, credentialsPath, tokenPath)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewClientWithProvider(provider, calendarID), nil
+}
+
+// NewClientWithProvider creates a client for any calendar backend
+func NewClientWithProvider(provider Provider, calendarID string) *Client {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
 
 	return &Client{
-		service:    srv,
+		provider:   provider,
 		calendarID: calendarID,
-	}, nil
+		workers:    DefaultWorkers,
+		batchSize:  MaxBatchSize,
+		limiter:    NewRateLimiter(DefaultQPS, DefaultQPS),
+		retry:      DefaultRetryPolicy,
+	}
 }
 
 // ListCalendars returns all available calendars
-func (c *Client) ListCalendars() ([]*calendar.CalendarListEntry, error) {
-	list, err := c.service.CalendarList.List().Do()
+func (c *Client) ListCalendars(ctx context.Context) ([]CalendarInfo, error) {
+	var calendars []CalendarInfo
+	err := c.call(ctx, func() (err error) {
+		calendars, err = c.provider.ListCalendars(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to list calendars: %w", err)
 	}
-	return list.Items, nil
+	return calendars, nil
 }
 
 // GetCalendarID returns the current calendar ID
@@ -49,23 +74,61 @@ func (c *Client) SetCalendarID(calendarID string) {
 	c.calendarID = calendarID
 }
 
+// SetSource sets the name of the template that owns the events written by
+// this client. Only events owned by the same source are reported as orphans.
+func (c *Client) SetSource(source string) {
+	c.source = source
+}
+
+// SetConcurrency sets how many events are written to the calendar at once
+func (c *Client) SetConcurrency(workers int) {
+	c.workers = max(1, workers)
+}
+
+// SetBatchSize sets how many writes are grouped into one request on backends
+// that support batching, up to MaxBatchSize; 0 or 1 sends every write on its own
+func (c *Client) SetBatchSize(size int) {
+	c.batchSize = min(size, MaxBatchSize)
+}
+
+// SetRateLimit limits calls to the calendar backend to qps per second on
+// average; qps <= 0 removes the limit
+func (c *Client) SetRateLimit(qps float64) {
+	c.limiter = NewRateLimiter(qps, int(math.Ceil(qps)))
+}
+
+// SetRetryPolicy sets how calls failing with rate limit or server errors are retried
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SourceName derives the owning source name from a template file path. The
+// absolute path is used, so that templates of the same name in different
+// directories do not own each other's events.
+func SourceName(templatePath string) string {
+	if abs, err := filepath.Abs(templatePath); err == nil {
+		templatePath = abs
+	}
+	return filepath.ToSlash(filepath.Clean(templatePath))
+}
+
 // FindCalendarByName finds a calendar by its summary (name)
-func (c *Client) FindCalendarByName(name string) (*calendar.CalendarListEntry, error) {
-	calendars, err := c.ListCalendars()
+func (c *Client) FindCalendarByName(ctx context.Context, name string) (*CalendarInfo, error) {
+	calendars, err := c.ListCalendars(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, cal := range calendars {
+	for i, cal := range calendars {
 		if cal.Summary == name {
-			return cal, nil
+			return &calendars[i], nil
 		}
 	}
 
 	return nil, fmt.Errorf("calendar not found: %s", name)
 }
 
-// GetService returns the underlying calendar service
-func (c *Client) GetService() *calendar.Service {
-	return c.service
+// Provider returns the underlying calendar backend
+func (c *Client) Provider() Provider {
+	return c.provider
 }
@@ -1,27 +1,65 @@
 package calendar
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/monil/calendar-event-generator/models"
-	"google.golang.org/api/calendar/v3"
+)
+
+// Private properties used to recognise events created by this tool
+const (
+	propertyKey    = "cegKey"    // models.CalendarEvent.StableKey
+	propertyHash   = "cegHash"   // models.CalendarEvent.ContentHash
+	propertySource = "cegSource" // Template the event belongs to, see SetSource
+)
+
+// EventAction describes what was done to an event in the calendar
+type EventAction string
+
+const (
+	ActionCreated   EventAction = "created"
+	ActionUpdated   EventAction = "updated"
+	ActionUnchanged EventAction = "unchanged"
+	ActionDeleted   EventAction = "deleted"
+	ActionCancelled EventAction = "cancelled"
 )
 
 // EventResult represents the result of creating an event
 type EventResult struct {
 	Event   *models.CalendarEvent
-	GEvent  *calendar.Event
+	Remote  *RemoteEvent
+	Action  EventAction
 	Success bool
 	Error   error
 	Link    string
 }
 
-// CreateEvent creates a single event in Google Calendar
-func (c *Client) CreateEvent(event *models.CalendarEvent) (*EventResult, error) {
-	gEvent := c.convertToGoogleEvent(event)
+// CreateEvent creates a single event in the calendar. A create that failed
+// with a server error or timeout may still have been written, so before it is
+// retried the event is looked up by its properties and not created twice.
+func (c *Client) CreateEvent(ctx context.Context, event *models.CalendarEvent) (*EventResult, error) {
+	return c.createEvent(ctx, event, false)
+}
 
-	created, err := c.service.Events.Insert(c.calendarID, gEvent).Do()
+// createEvent is CreateEvent, looking the event up before the first attempt
+// too if an earlier call may have written it
+func (c *Client) createEvent(ctx context.Context, event *models.CalendarEvent, mayExist bool) (*EventResult, error) {
+	var created *RemoteEvent
+	var lastErr error
+	err := c.call(ctx, func() (err error) {
+		if mayExist || (lastErr != nil && !isRateLimited(lastErr)) {
+			if created, err = c.findCreated(ctx, event); created != nil || err != nil {
+				return err
+			}
+		}
+		created, err = c.provider.CreateEvent(ctx, c.calendarID, event, c.properties(event))
+		mayExist, lastErr = false, err
+		return err
+	})
 	if err != nil {
 		return &EventResult{
 			Event:   event,
@@ -30,141 +68,232 @@ func (c *Client) CreateEvent(event *models.CalendarEvent) (*EventResult, error)
 		}, err
 	}
 
+	err = c.finishOverrides(ctx, created, event, nil)
 	return &EventResult{
 		Event:   event,
-		GEvent:  created,
-		Success: true,
-		Link:    created.HtmlLink,
-	}, nil
+		Remote:  created,
+		Action:  ActionCreated,
+		Success: err == nil,
+		Error:   err,
+		Link:    created.Link,
+	}, err
 }
 
-// CreateEvents creates multiple events with progress reporting
-func (c *Client) CreateEvents(events []models.CalendarEvent, callback func(int, int, *EventResult)) ([]*EventResult, error) {
-	results := make([]*EventResult, len(events))
-
-	for i, event := range events {
-		result, _ := c.CreateEvent(&event)
-		results[i] = result
+// findCreated returns the event written by an earlier create of event, or nil
+// if there is none
+func (c *Client) findCreated(ctx context.Context, event *models.CalendarEvent) (*RemoteEvent, error) {
+	props := c.properties(event)
+	events, err := c.provider.ListEvents(ctx, c.calendarID, ListOptions{Properties: props})
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return events[0], nil
+}
 
-		if callback != nil {
-			callback(i+1, len(events), result)
-		}
+// UpdateEvent overwrites an existing calendar event with the given event
+func (c *Client) UpdateEvent(ctx context.Context, eventID string, event *models.CalendarEvent) (*EventResult, error) {
+	return c.updateEvent(ctx, eventID, event, nil)
+}
 
-		// Small delay to avoid rate limiting
-		if i < len(events)-1 {
-			time.Sleep(100 * time.Millisecond)
-		}
+// updateEvent is UpdateEvent. If old, the event as listed before, has
+// overrides that event no longer has, they are reverted.
+func (c *Client) updateEvent(ctx context.Context, eventID string, event *models.CalendarEvent, old *RemoteEvent) (*EventResult, error) {
+	var updated *RemoteEvent
+	err := c.call(ctx, func() (err error) {
+		updated, err = c.provider.UpdateEvent(ctx, c.calendarID, eventID, event, c.properties(event))
+		return err
+	})
+	if err != nil {
+		return &EventResult{
+			Event:   event,
+			Success: false,
+			Error:   err,
+		}, err
 	}
 
-	return results, nil
+	err = c.finishOverrides(ctx, updated, event, old)
+	return &EventResult{
+		Event:   event,
+		Remote:  updated,
+		Action:  ActionUpdated,
+		Success: err == nil,
+		Error:   err,
+		Link:    updated.Link,
+	}, err
 }
 
-// convertToGoogleEvent converts a CalendarEvent to a Google Calendar Event
-func (c *Client) convertToGoogleEvent(event *models.CalendarEvent) *calendar.Event {
-	gEvent := &calendar.Event{
-		Summary:     event.Name,
-		Description: event.FormatDescription(),
-		Location:    event.Location,
+// finishOverrides writes the overrides of an event that was just created or
+// updated as remote, when the provider stores them separately, and reverts the
+// occurrences overridden in old, the event before the update, that event no
+// longer overrides. If that fails, the event loses its content hash, so the
+// next run updates it again rather than skipping it; the event itself is kept.
+func (c *Client) finishOverrides(ctx context.Context, remote *RemoteEvent, event *models.CalendarEvent, old *RemoteEvent) error {
+	overrider, ok := c.provider.(Overrider)
+	if !ok || event == nil {
+		return nil
 	}
 
-	// Set start and end times
-	if event.AllDay {
-		gEvent.Start = &calendar.EventDateTime{
-			Date: event.StartTime.Format("2006-01-02"),
-		}
-		gEvent.End = &calendar.EventDateTime{
-			Date: event.EndTime.Format("2006-01-02"),
-		}
-	} else {
-		gEvent.Start = &calendar.EventDateTime{
-			DateTime: event.StartTime.Format(time.RFC3339),
-		}
-		if tz := event.StartTime.Location().String(); tz != "Local" {
-			gEvent.Start.TimeZone = tz
-		}
+	var overrides []models.Override
+	if event.Exceptions != nil {
+		overrides = event.Exceptions.Overrides
+	}
 
-		gEvent.End = &calendar.EventDateTime{
-			DateTime: event.EndTime.Format(time.RFC3339),
+	var err error
+	for _, o := range overrides {
+		if err = c.overrideOccurrence(ctx, overrider, remote.ID, event, o); err != nil {
+			break
 		}
-		if tz := event.EndTime.Location().String(); tz != "Local" {
-			gEvent.End.TimeZone = tz
+	}
+	if err == nil && old != nil && old.Event.Exceptions != nil {
+		for _, o := range old.Event.Exceptions.Overrides {
+			kept := slices.ContainsFunc(overrides, func(n models.Override) bool {
+				return sameStart(n.OriginalStart, o.OriginalStart, event.AllDay)
+			})
+			if kept {
+				continue
+			}
+			if err = c.restoreOccurrence(ctx, overrider, remote.ID, event, o.OriginalStart); err != nil {
+				break
+			}
 		}
 	}
+	if err == nil {
+		return nil
+	}
 
-	// Set recurrence rule
-	if event.Recurrence != nil {
-		rrule := c.buildRRule(event.Recurrence)
-		if rrule != "" {
-			gEvent.Recurrence = []string{rrule}
-		}
+	props := c.properties(event)
+	props[propertyHash] = ""
+	if staleErr := c.call(ctx, func() error {
+		_, err := c.provider.UpdateEvent(ctx, c.calendarID, remote.ID, event, props)
+		return err
+	}); staleErr != nil {
+		return fmt.Errorf("unable to apply overrides: %w (and unable to mark the event for a retry: %v)", err, staleErr)
 	}
+	return fmt.Errorf("unable to apply overrides: %w", err)
+}
 
-	// Set color
-	if event.ColorID != "" {
-		gEvent.ColorId = event.ColorID
+// overrideOccurrence finds the occurrence an override replaces and writes the override onto it
+func (c *Client) overrideOccurrence(ctx context.Context, overrider Overrider, eventID string, event *models.CalendarEvent, o models.Override) error {
+	var occurrenceID string
+	err := c.call(ctx, func() (err error) {
+		occurrenceID, err = overrider.OccurrenceID(ctx, c.calendarID, eventID, o.OriginalStart)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if occurrenceID == "" {
+		return fmt.Errorf("no occurrence of '%s' starts at %s", event.Name, o.OriginalStart.Format("Jan 2, 2006 3:04 PM"))
 	}
 
-	// Set reminders
-	if len(event.Reminders) > 0 {
-		overrides := make([]*calendar.EventReminder, len(event.Reminders))
-		for i, r := range event.Reminders {
-			overrides[i] = &calendar.EventReminder{
-				Method:  r.Method,
-				Minutes: int64(r.Minutes),
-			}
-		}
-		gEvent.Reminders = &calendar.EventReminders{
-			UseDefault: false,
-			Overrides:  overrides,
-		}
+	return c.call(ctx, func() error {
+		return overrider.OverrideOccurrence(ctx, c.calendarID, occurrenceID, event, o)
+	})
+}
+
+// restoreOccurrence puts the occurrence of a recurring event originally
+// starting at start back to the series, if the event still has it
+func (c *Client) restoreOccurrence(ctx context.Context, overrider Overrider, eventID string, event *models.CalendarEvent, start time.Time) error {
+	var occurrenceID string
+	err := c.call(ctx, func() (err error) {
+		occurrenceID, err = overrider.OccurrenceID(ctx, c.calendarID, eventID, start)
+		return err
+	})
+	if err != nil || occurrenceID == "" {
+		return err
 	}
 
-	return gEvent
+	return c.call(ctx, func() error {
+		return overrider.RestoreOccurrence(ctx, c.calendarID, occurrenceID, event, start)
+	})
 }
 
-// buildRRule creates an RRULE string from RecurrenceRule
-func (c *Client) buildRRule(r *models.RecurrenceRule) string {
-	if r == nil {
-		return ""
+// sameStart reports whether two occurrence starts are the same, by date only
+// for all-day events
+func sameStart(a, b time.Time, allDay bool) bool {
+	if allDay {
+		return a.Format("2006-01-02") == b.Format("2006-01-02")
 	}
+	return a.Equal(b)
+}
 
-	rule := "RRULE:FREQ=" + r.Frequency
+// DeleteEvent removes an event from the calendar
+func (c *Client) DeleteEvent(ctx context.Context, eventID string) error {
+	err := c.call(ctx, func() error {
+		return c.provider.DeleteEvent(ctx, c.calendarID, eventID)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete event: %w", err)
+	}
+	return nil
+}
+
+// IsNotFound reports whether err means the event no longer exists
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
 
-	if r.Interval > 1 {
-		rule += fmt.Sprintf(";INTERVAL=%d", r.Interval)
+// CancelEvent marks an event as cancelled and notifies its attendees
+func (c *Client) CancelEvent(ctx context.Context, eventID string) error {
+	canceller, ok := c.provider.(Canceller)
+	if !ok {
+		return fmt.Errorf("this calendar backend does not support cancelling events")
 	}
 
-	if r.Until != nil {
-		rule += ";UNTIL=" + r.Until.UTC().Format("20060102T150405Z")
+	err := c.call(ctx, func() error {
+		return canceller.CancelEvent(ctx, c.calendarID, eventID)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to cancel event: %w", err)
 	}
+	return nil
+}
 
-	if r.Count > 0 {
-		rule += fmt.Sprintf(";COUNT=%d", r.Count)
+// ListEvents returns all events in the calendar matching opts.
+// Recurring events are returned as a single master event rather than expanded.
+func (c *Client) ListEvents(ctx context.Context, opts ListOptions) ([]*RemoteEvent, error) {
+	var events []*RemoteEvent
+	err := c.call(ctx, func() (err error) {
+		events, err = c.provider.ListEvents(ctx, c.calendarID, opts)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
 	}
+	return events, nil
+}
 
-	if len(r.ByDay) > 0 {
-		rule += ";BYDAY="
-		for i, day := range r.ByDay {
-			if i > 0 {
-				rule += ","
-			}
-			rule += day
-		}
+// CreateEvents creates multiple events concurrently with progress reporting.
+// Progress is reported in the order of events. If ctx is cancelled, events not
+// yet started are reported as failed and ctx.Err() is returned with the results.
+func (c *Client) CreateEvents(ctx context.Context, events []models.CalendarEvent, callback func(int, int, *EventResult)) ([]*EventResult, error) {
+	ops := make([]writeOp, len(events))
+	for i := range events {
+		ops[i] = writeOp{method: BatchCreate, event: &events[i]}
 	}
 
-	return rule
+	return c.applyAll(ctx, ops, callback), ctx.Err()
 }
 
-// DryRunEvent validates an event without creating it
-func (c *Client) DryRunEvent(event *models.CalendarEvent) *calendar.Event {
-	return c.convertToGoogleEvent(event)
+// DeleteEvents deletes events by ID concurrently with progress reporting.
+// The Remote of each result carries the event ID.
+func (c *Client) DeleteEvents(ctx context.Context, eventIDs []string, callback func(int, int, *EventResult)) []*EventResult {
+	ops := make([]writeOp, len(eventIDs))
+	for i, id := range eventIDs {
+		ops[i] = writeOp{method: BatchDelete, eventID: id, remote: &RemoteEvent{ID: id}}
+	}
+
+	return c.applyAll(ctx, ops, callback)
 }
 
-// DryRunEvents validates multiple events and returns Google Calendar event representations
-func (c *Client) DryRunEvents(events []models.CalendarEvent) []*calendar.Event {
-	gEvents := make([]*calendar.Event, len(events))
-	for i, e := range events {
-		gEvents[i] = c.DryRunEvent(&e)
+// properties returns the private properties that tag an event as written by this tool
+func (c *Client) properties(event *models.CalendarEvent) map[string]string {
+	props := map[string]string{
+		propertyKey:  event.StableKey(),
+		propertyHash: event.ContentHash(),
+	}
+	if c.source != "" {
+		props[propertySource] = c.source
 	}
-	return gEvents
+	return props
 }
@@ -0,0 +1,544 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monil/calendar-event-generator/calendar/fake"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// faults makes chosen requests to a fake calendar fail before they reach it,
+// or lose their response after the fake has carried them out
+type faults struct {
+	mu    sync.Mutex
+	fail  func(r *http.Request) (status int, reason string) // Status 0 lets the request through
+	lose  func(r *http.Request) bool                        // Answer 503 once the request is done
+	calls map[string]int                                    // Requests seen, by requestKind
+}
+
+// testClient returns a client writing to a fake Google Calendar through
+// faults, with quick retries and no rate limit
+func testClient(t *testing.T) (*Client, *fake.Service, *faults) {
+	t.Helper()
+
+	svc := fake.NewService()
+	f := &faults{calls: make(map[string]int)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.calls[requestKind(r)]++
+		var status int
+		var reason string
+		if f.fail != nil {
+			status, reason = f.fail(r)
+		}
+		if status == 0 && f.lose != nil && f.lose(r) {
+			svc.ServeHTTP(httptest.NewRecorder(), r)
+			status, reason = http.StatusServiceUnavailable, "backendError"
+		}
+		f.mu.Unlock()
+
+		if status != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error":{"code":%d,"message":"injected","errors":[{"domain":"global","reason":%q,"message":"injected"}]}}`, status, reason)
+			return
+		}
+		svc.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	provider, err := NewGoogleProviderWithEndpoint(context.Background(), srv.URL+"/calendar/v3/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientWithProvider(provider, "primary")
+	client.SetRateLimit(0)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	client.SetSource("/templates/test.yaml")
+	return client, svc, f
+}
+
+// requestKind names a request by its method and the kind of resource it addresses
+func requestKind(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/batch/"):
+		return r.Method + " batch"
+	case strings.HasSuffix(path, "/instances"):
+		return r.Method + " instances"
+	case strings.HasSuffix(path, "/events"):
+		return r.Method + " events"
+	case strings.Contains(path, "/events/"):
+		return r.Method + " event"
+	}
+	return r.Method + " " + path
+}
+
+func (f *faults) count(kind string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[kind]
+}
+
+// failFirst fails the first n requests of a kind with status and reason
+func (f *faults) failFirst(kind string, n, status int, reason string) {
+	f.fail = func(r *http.Request) (int, string) {
+		if requestKind(r) == kind && f.calls[kind] <= n {
+			return status, reason
+		}
+		return 0, ""
+	}
+}
+
+// loseFirst loses the responses to the first n requests of a kind
+func (f *faults) loseFirst(kind string, n int) {
+	f.lose = func(r *http.Request) bool {
+		return requestKind(r) == kind && f.calls[kind] <= n
+	}
+}
+
+// testEvents returns n one-hour events on consecutive days
+func testEvents(n int) []models.CalendarEvent {
+	start := time.Date(2027, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := make([]models.CalendarEvent, n)
+	for i := range events {
+		events[i] = models.CalendarEvent{
+			Name:      fmt.Sprintf("Event %d", i+1),
+			StartTime: start.AddDate(0, 0, i),
+			EndTime:   start.AddDate(0, 0, i).Add(time.Hour),
+			Location:  "Room 1",
+		}
+	}
+	return events
+}
+
+// actions lists the action of each result, failing the test on errors
+func actions(t *testing.T, results []*EventResult) []EventAction {
+	t.Helper()
+	var got []EventAction
+	for _, r := range results {
+		if !r.Success {
+			t.Fatalf("%s: %v", r.Event.Name, r.Error)
+		}
+		got = append(got, r.Action)
+	}
+	return got
+}
+
+func equalActions(a, b []EventAction) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestUpsertEvents(t *testing.T) {
+	ctx := context.Background()
+	client, svc, _ := testClient(t)
+	client.SetBatchSize(1)
+	events := testEvents(2)
+
+	results, err := client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionCreated, ActionCreated}; !equalActions(got, want) {
+		t.Errorf("first run: got %v, want %v", got, want)
+	}
+
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUnchanged}; !equalActions(got, want) {
+		t.Errorf("second run: got %v, want %v", got, want)
+	}
+
+	events[1].Location = "Room 2"
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUpdated}; !equalActions(got, want) {
+		t.Errorf("after an edit: got %v, want %v", got, want)
+	}
+
+	stored := svc.Events("primary")
+	if len(stored) != 2 {
+		t.Fatalf("calendar has %d events, want 2", len(stored))
+	}
+	if stored[1].Location != "Room 2" {
+		t.Errorf("updated event has location %q, want %q", stored[1].Location, "Room 2")
+	}
+	if got := stored[1].ExtendedProperties.Private[propertySource]; got != "/templates/test.yaml" {
+		t.Errorf("event source is %q, want %q", got, "/templates/test.yaml")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+	events := testEvents(3)
+	if _, err := client.UpsertEvents(ctx, events, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep the first event, move the second, drop the third and add a fourth
+	edited := testEvents(4)
+	edited[1].Location = "Room 2"
+	edited = append(edited[:2], edited[3])
+
+	plan, err := client.Plan(ctx, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action PlanAction
+		name   string
+	}{
+		{PlanNoop, "Event 1"},
+		{PlanUpdate, "Event 2"},
+		{PlanCreate, "Event 4"},
+		{PlanOrphan, "Event 3"},
+	}
+	if len(plan.Entries) != len(want) {
+		t.Fatalf("plan has %d entries, want %d", len(plan.Entries), len(want))
+	}
+	for i, w := range want {
+		if e := plan.Entries[i]; e.Action != w.action || e.Name() != w.name {
+			t.Errorf("entry %d: got %s %s, want %s %s", i, e.Action, e.Name(), w.action, w.name)
+		}
+	}
+
+	changes := plan.Entries[1].Changes
+	if len(changes) != 1 || changes[0] != (FieldChange{Field: "location", Old: "Room 1", New: "Room 2"}) {
+		t.Errorf("update changes: got %+v, want the location only", changes)
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	ctx := context.Background()
+	client, svc, _ := testClient(t)
+	if _, err := client.UpsertEvents(ctx, testEvents(3), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// An event of another template in the same window is not this source's orphan
+	other := NewClientWithProvider(client.Provider(), "primary")
+	other.SetRateLimit(0)
+	other.SetSource("/templates/other.yaml")
+	extra := testEvents(1)
+	extra[0].Name = "Other"
+	if _, err := other.UpsertEvents(ctx, extra, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := client.Plan(ctx, testEvents(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := plan.Count(PlanOrphan); n != 2 {
+		t.Fatalf("plan has %d orphans, want 2", n)
+	}
+
+	results := client.RemoveOrphans(ctx, plan, false, nil)
+	if got, want := actions(t, results), []EventAction{ActionDeleted, ActionDeleted}; !equalActions(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var names []string
+	for _, e := range svc.Events("primary") {
+		names = append(names, e.Summary)
+	}
+	if got, want := strings.Join(names, ", "), "Event 1, Other"; got != want {
+		t.Errorf("calendar has %s, want %s", got, want)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	client, svc, f := testClient(t)
+
+	results, err := client.UpsertEvents(ctx, testEvents(5), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions(t, results)
+
+	if n := f.count("POST batch"); n != 1 {
+		t.Errorf("sent %d batch requests, want 1", n)
+	}
+	if n := f.count("POST events"); n != 0 {
+		t.Errorf("sent %d single inserts, want 0", n)
+	}
+	if n := len(svc.Events("primary")); n != 5 {
+		t.Errorf("calendar has %d events, want 5", n)
+	}
+}
+
+func TestBatchFallback(t *testing.T) {
+	ctx := context.Background()
+	client, svc, f := testClient(t)
+	f.failFirst("POST batch", 1, http.StatusBadRequest, "badRequest")
+
+	results, err := client.UpsertEvents(ctx, testEvents(5), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(t, results); len(got) != 5 {
+		t.Fatalf("got %d results, want 5", len(got))
+	}
+
+	if n := f.count("POST batch"); n != 1 {
+		t.Errorf("sent %d batch requests, want 1", n)
+	}
+	if n := f.count("POST events"); n != 5 {
+		t.Errorf("sent %d single inserts, want 5", n)
+	}
+	if n := len(svc.Events("primary")); n != 5 {
+		t.Errorf("calendar has %d events, want 5", n)
+	}
+}
+
+func TestBatchLostResponse(t *testing.T) {
+	ctx := context.Background()
+	client, svc, f := testClient(t)
+	f.loseFirst("POST batch", 1)
+
+	// The batch is written but answered with a 503; it is not resent, and
+	// each create finds its event rather than inserting it again
+	results, err := client.UpsertEvents(ctx, testEvents(5), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(t, results); len(got) != 5 {
+		t.Fatalf("got %d results, want 5", len(got))
+	}
+
+	if n := f.count("POST batch"); n != 1 {
+		t.Errorf("sent %d batch requests, want 1", n)
+	}
+	if n := f.count("POST events"); n != 0 {
+		t.Errorf("sent %d single inserts, want 0", n)
+	}
+	if n := len(svc.Events("primary")); n != 5 {
+		t.Errorf("calendar has %d events, want 5", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		reason   string
+		attempts int
+		success  bool
+	}{
+		{"rate limited", http.StatusForbidden, "rateLimitExceeded", 3, true},
+		{"user rate limited", http.StatusForbidden, "userRateLimitExceeded", 3, true},
+		{"server error", http.StatusServiceUnavailable, "backendError", 3, true},
+		{"too many requests", http.StatusTooManyRequests, "rateLimitExceeded", 3, true},
+		{"forbidden", http.StatusForbidden, "forbidden", 1, false},
+		{"bad request", http.StatusBadRequest, "invalid", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, svc, f := testClient(t)
+			f.failFirst("POST events", 2, tt.status, tt.reason)
+
+			result, err := client.CreateEvent(context.Background(), &testEvents(1)[0])
+			if (err == nil) != tt.success {
+				t.Fatalf("got error %v, want success %v", err, tt.success)
+			}
+			if result.Success != tt.success {
+				t.Errorf("result success is %v, want %v", result.Success, tt.success)
+			}
+			if n := f.count("POST events"); n != tt.attempts {
+				t.Errorf("sent %d requests, want %d", n, tt.attempts)
+			}
+			want := 0
+			if tt.success {
+				want = 1
+			}
+			if n := len(svc.Events("primary")); n != want {
+				t.Errorf("calendar has %d events, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestRetryLostResponse(t *testing.T) {
+	ctx := context.Background()
+	client, svc, f := testClient(t)
+	f.loseFirst("POST events", 1)
+
+	// The first insert is written but answered with a 503, so the retry
+	// finds the event instead of creating it again
+	result, err := client.CreateEvent(ctx, &testEvents(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(svc.Events("primary")); n != 1 {
+		t.Errorf("calendar has %d events, want 1", n)
+	}
+	if n := f.count("POST events"); n != 1 {
+		t.Errorf("sent %d inserts, want 1", n)
+	}
+	if result.Remote == nil || result.Remote.ID != svc.Events("primary")[0].Id {
+		t.Errorf("result does not refer to the event in the calendar")
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	client, _, f := testClient(t)
+	f.failFirst("POST events", 10, http.StatusInternalServerError, "backendError")
+
+	if _, err := client.CreateEvent(context.Background(), &testEvents(1)[0]); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := f.count("POST events"); n != 4 {
+		t.Errorf("sent %d requests, want 4", n)
+	}
+}
+
+func TestOverrideFailure(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("batch=%v", batch), func(t *testing.T) {
+			ctx := context.Background()
+			client, svc, f := testClient(t)
+			if !batch {
+				client.SetBatchSize(1)
+			}
+
+			// Writes to an occurrence fail, so the override cannot be applied
+			f.fail = func(r *http.Request) (int, string) {
+				if r.Method == http.MethodPatch && strings.Contains(r.URL.Path, "_") {
+					return http.StatusBadRequest, "invalid"
+				}
+				return 0, ""
+			}
+
+			events := testEvents(2)
+			events[0].Recurrence = &models.RecurrenceRule{Frequency: "WEEKLY", Interval: 1, Count: 4}
+			events[0].Exceptions = &models.Exceptions{Overrides: []models.Override{{
+				OriginalStart: events[0].StartTime.AddDate(0, 0, 7),
+				StartTime:     events[0].StartTime.AddDate(0, 0, 7).Add(time.Hour),
+				EndTime:       events[0].EndTime.AddDate(0, 0, 7).Add(time.Hour),
+			}}}
+
+			results, err := client.UpsertEvents(ctx, events, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Success || results[0].Remote == nil || results[0].Action != ActionCreated {
+				t.Fatalf("got %+v, want a created event reported as failed", results[0])
+			}
+			if !results[1].Success {
+				t.Errorf("second event: %v", results[1].Error)
+			}
+
+			stored := svc.Events("primary")
+			if len(stored) != 2 {
+				t.Fatalf("calendar has %d events, want 2", len(stored))
+			}
+			if hash := stored[0].ExtendedProperties.Private[propertyHash]; hash != "" {
+				t.Errorf("event kept its content hash %q, want it cleared", hash)
+			}
+
+			// The next run updates the event rather than creating it again
+			f.fail = nil
+			results, err = client.UpsertEvents(ctx, events, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := actions(t, results), []EventAction{ActionUpdated, ActionUnchanged}; !equalActions(got, want) {
+				t.Errorf("retry: got %v, want %v", got, want)
+			}
+			masters := 0
+			for _, e := range svc.Events("primary") {
+				if e.RecurringEventId == "" {
+					masters++
+				} else if !strings.HasPrefix(e.Id, stored[0].Id+"_") {
+					t.Errorf("occurrence %s is not of the original event %s", e.Id, stored[0].Id)
+				}
+			}
+			if masters != 2 {
+				t.Errorf("calendar has %d events after the retry, want 2", masters)
+			}
+		})
+	}
+}
+
+func TestOverrideRemoved(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("batch=%v", batch), func(t *testing.T) {
+			ctx := context.Background()
+			client, svc, _ := testClient(t)
+			if !batch {
+				client.SetBatchSize(1)
+			}
+
+			events := testEvents(2)
+			events[0].Recurrence = &models.RecurrenceRule{Frequency: "WEEKLY", Interval: 1, Count: 4}
+			moved := events[0].StartTime.AddDate(0, 0, 7)
+			events[0].Exceptions = &models.Exceptions{Overrides: []models.Override{{
+				OriginalStart: moved,
+				StartTime:     moved.Add(time.Hour),
+				EndTime:       moved.Add(2 * time.Hour),
+				Location:      "Room 9",
+			}}}
+			if _, err := client.UpsertEvents(ctx, events, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			// Dropping the override puts the occurrence back to the series
+			events[0].Exceptions = nil
+			results, err := client.UpsertEvents(ctx, events, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := actions(t, results), []EventAction{ActionUpdated, ActionUnchanged}; !equalActions(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+
+			var instances int
+			for _, e := range svc.Events("primary") {
+				if e.RecurringEventId == "" {
+					continue
+				}
+				instances++
+				start, _ := time.Parse(time.RFC3339, e.Start.DateTime)
+				if !start.Equal(moved) || e.Location != "Room 1" {
+					t.Errorf("occurrence starts at %s in %q, want %s in %q", start, e.Location, moved, "Room 1")
+				}
+			}
+			if instances != 1 {
+				t.Errorf("calendar has %d occurrences, want 1", instances)
+			}
+
+			remote, err := client.ListEvents(ctx, ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range remote {
+				if e.Event.Exceptions != nil && len(e.Event.Exceptions.Overrides) > 0 {
+					t.Errorf("%s still has overrides %+v", e.Event.Name, e.Event.Exceptions.Overrides)
+				}
+			}
+
+			plan, err := client.Plan(ctx, events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := plan.Count(PlanNoop); n != 2 {
+				t.Errorf("got %d unchanged events after the update, want 2", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+// Package fake provides an in-memory stand-in for the Google Calendar v3 API.
+// It implements the endpoints used by this tool so the calendar package can be
+// exercised without a Google account, either from tests via NewServer or from
+// the CLI by pointing --endpoint at a running Service.
+package fake
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// PrimaryCalendarID is the ID of the calendar that "primary" refers to
+const PrimaryCalendarID = "user@example.com"
+
+// maxBatchSize is the number of calls Google accepts in one batch request
+const maxBatchSize = 50
+
+// Service is an in-memory implementation of the Calendar v3 endpoints
+type Service struct {
+	mu        sync.Mutex
+	calendars map[string]*fakeCalendar
+	nextID    int
+	mux       *http.ServeMux
+}
+
+type fakeCalendar struct {
+	entry  *calendar.CalendarListEntry
+	events map[string]*calendar.Event
+}
+
+// NewService creates a Service holding an empty primary calendar
+func NewService() *Service {
+	s := &Service{calendars: make(map[string]*fakeCalendar)}
+	s.AddCalendar(PrimaryCalendarID, "Primary", true)
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /calendar/v3/users/me/calendarList", s.listCalendars)
+	s.mux.HandleFunc("GET /calendar/v3/calendars/{calendarId}/events", s.listEvents)
+	s.mux.HandleFunc("POST /calendar/v3/calendars/{calendarId}/events", s.insertEvent)
+	s.mux.HandleFunc("GET /calendar/v3/calendars/{calendarId}/events/{eventId}", s.getEvent)
+	s.mux.HandleFunc("PATCH /calendar/v3/calendars/{calendarId}/events/{eventId}", s.patchEvent)
+	s.mux.HandleFunc("PUT /calendar/v3/calendars/{calendarId}/events/{eventId}", s.updateEvent)
+	s.mux.HandleFunc("DELETE /calendar/v3/calendars/{calendarId}/events/{eventId}", s.deleteEvent)
+	s.mux.HandleFunc("GET /calendar/v3/calendars/{calendarId}/events/{eventId}/instances", s.listInstances)
+	s.mux.HandleFunc("POST /calendar/v3/freeBusy", s.freeBusy)
+	s.mux.HandleFunc("POST /batch/calendar/v3", s.batch)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// AddCalendar adds a calendar to the user's calendar list
+func (s *Service) AddCalendar(id, summary string, primary bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calendars[id] = &fakeCalendar{
+		entry: &calendar.CalendarListEntry{
+			Id:         id,
+			Summary:    summary,
+			Primary:    primary,
+			AccessRole: "owner",
+		},
+		events: make(map[string]*calendar.Event),
+	}
+}
+
+// Events returns a copy of the non-cancelled events in a calendar, ordered by start
+func (s *Service) Events(calendarID string) []*calendar.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal := s.calendar(calendarID)
+	if cal == nil {
+		return nil
+	}
+	return cal.sorted(func(e *calendar.Event) bool { return e.Status != "cancelled" })
+}
+
+// Server is a Service listening on a local httptest.Server
+type Server struct {
+	*httptest.Server
+	Service *Service
+}
+
+// NewServer starts a Server with an empty primary calendar. Callers should
+// Close it when done.
+func NewServer() *Server {
+	svc := NewService()
+	return &Server{
+		Server:  httptest.NewServer(svc),
+		Service: svc,
+	}
+}
+
+// Endpoint returns the API base URL to pass to option.WithEndpoint or --endpoint
+func (s *Server) Endpoint() string {
+	return s.URL + "/calendar/v3/"
+}
+
+// calendar looks up a calendar, resolving the "primary" alias. Callers must hold s.mu.
+func (s *Service) calendar(id string) *fakeCalendar {
+	if id == "primary" {
+		id = PrimaryCalendarID
+	}
+	return s.calendars[id]
+}
+
+// sorted returns copies of the events accepted by keep, ordered by start time
+func (c *fakeCalendar) sorted(keep func(*calendar.Event) bool) []*calendar.Event {
+	var events []*calendar.Event
+	for _, e := range c.events {
+		if keep(e) {
+			copied := *e
+			events = append(events, &copied)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		si, sj := eventTime(events[i].Start), eventTime(events[j].Start)
+		if si.Equal(sj) {
+			return events[i].Id < events[j].Id
+		}
+		return si.Before(sj)
+	})
+	return events
+}
+
+func (s *Service) listCalendars(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := &calendar.CalendarList{Kind: "calendar#calendarList"}
+	for _, cal := range s.calendars {
+		list.Items = append(list.Items, cal.entry)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Id < list.Items[j].Id })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Service) listEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal := s.calendar(r.PathValue("calendarId"))
+	if cal == nil {
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		return
+	}
+
+	query := r.URL.Query()
+	timeMin, _ := time.Parse(time.RFC3339, query.Get("timeMin"))
+	timeMax, _ := time.Parse(time.RFC3339, query.Get("timeMax"))
+	showDeleted := query.Get("showDeleted") == "true"
+	properties := query["privateExtendedProperty"]
+
+	events := cal.sorted(func(e *calendar.Event) bool {
+		if e.Status == "cancelled" && !showDeleted {
+			return false
+		}
+		start, end := eventTime(e.Start), eventTime(e.End)
+		if !timeMax.IsZero() && !start.Before(timeMax) {
+			return false
+		}
+		if !timeMin.IsZero() && len(e.Recurrence) == 0 && !end.After(timeMin) {
+			return false
+		}
+		for _, prop := range properties {
+			name, value, _ := strings.Cut(prop, "=")
+			if e.ExtendedProperties == nil || e.ExtendedProperties.Private[name] != value {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Paginate with the offset as page token
+	maxResults, err := strconv.Atoi(query.Get("maxResults"))
+	if err != nil || maxResults <= 0 || maxResults > 2500 {
+		maxResults = 250
+	}
+	offset, _ := strconv.Atoi(query.Get("pageToken"))
+	if offset > len(events) {
+		offset = len(events)
+	}
+
+	page := &calendar.Events{Kind: "calendar#events", Summary: cal.entry.Summary}
+	end := min(offset+maxResults, len(events))
+	page.Items = events[offset:end]
+	if end < len(events) {
+		page.NextPageToken = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Service) insertEvent(w http.ResponseWriter, r *http.Request) {
+	var event calendar.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if event.Start == nil || event.End == nil {
+		writeError(w, http.StatusBadRequest, "required", "Missing start or end time.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cal := s.calendar(r.PathValue("calendarId"))
+	if cal == nil {
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		return
+	}
+
+	s.nextID++
+	now := time.Now().UTC().Format(time.RFC3339)
+	event.Id = fmt.Sprintf("fake%06d", s.nextID)
+	event.Kind = "calendar#event"
+	event.Status = "confirmed"
+	event.HtmlLink = "https://calendar.example.com/event?eid=" + event.Id
+	event.Created = now
+	event.Updated = now
+	event.Etag = strconv.Quote(strconv.Itoa(s.nextID))
+	cal.events[event.Id] = &event
+
+	writeJSON(w, http.StatusOK, &event)
+}
+
+func (s *Service) getEvent(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := s.event(w, r)
+	if event == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, event)
+}
+
+func (s *Service) patchEvent(w http.ResponseWriter, r *http.Request) {
+	s.modifyEvent(w, r, true)
+}
+
+func (s *Service) updateEvent(w http.ResponseWriter, r *http.Request) {
+	s.modifyEvent(w, r, false)
+}
+
+// modifyEvent applies a patch (merging fields) or update (replacing the event)
+func (s *Service) modifyEvent(w http.ResponseWriter, r *http.Request, merge bool) {
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := s.event(w, r)
+	if event == nil {
+		return
+	}
+
+	current := make(map[string]any)
+	if merge {
+		data, _ := json.Marshal(event)
+		json.Unmarshal(data, &current)
+	}
+	mergeJSON(current, body)
+
+	data, _ := json.Marshal(current)
+	var updated calendar.Event
+	if err := json.Unmarshal(data, &updated); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	// Server-managed fields cannot be changed by the client
+	updated.Id = event.Id
+	updated.RecurringEventId = event.RecurringEventId
+	updated.OriginalStartTime = event.OriginalStartTime
+	updated.Kind = event.Kind
+	updated.HtmlLink = event.HtmlLink
+	updated.Created = event.Created
+	updated.Updated = time.Now().UTC().Format(time.RFC3339)
+	if updated.Status == "" {
+		updated.Status = "confirmed"
+	}
+
+	s.calendar(r.PathValue("calendarId")).events[event.Id] = &updated
+	writeJSON(w, http.StatusOK, &updated)
+}
+
+func (s *Service) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := s.event(w, r)
+	if event == nil {
+		return
+	}
+
+	// Google keeps deleted events around as cancelled
+	event.Status = "cancelled"
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// event looks up the event addressed by the request, writing an error response
+// when it does not exist. Callers must hold s.mu.
+func (s *Service) event(w http.ResponseWriter, r *http.Request) *calendar.Event {
+	cal := s.calendar(r.PathValue("calendarId"))
+	if cal == nil {
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		return nil
+	}
+
+	event, ok := cal.events[r.PathValue("eventId")]
+	if !ok {
+		event = cal.instance(r.PathValue("eventId"))
+	}
+	if event == nil {
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		return nil
+	}
+	if event.Status == "cancelled" {
+		writeError(w, http.StatusGone, "deleted", "Resource has been deleted")
+		return nil
+	}
+	return event
+}
+
+// listInstances returns the occurrence of a recurring event starting at the
+// originalStart parameter or, without it, the occurrences that were modified.
+// Occurrences are not checked against the recurrence rule.
+func (s *Service) listInstances(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	master := s.event(w, r)
+	if master == nil {
+		return
+	}
+	cal := s.calendar(r.PathValue("calendarId"))
+
+	list := &calendar.Events{Kind: "calendar#events", Summary: cal.entry.Summary}
+	if value := r.URL.Query().Get("originalStart"); value != "" {
+		start, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", "Invalid originalStart.")
+			return
+		}
+
+		id := master.Id + "_" + start.UTC().Format("20060102T150405Z")
+		if master.Start.Date != "" {
+			id = master.Id + "_" + start.Format("20060102")
+		}
+		if instance, ok := cal.events[id]; ok {
+			copied := *instance
+			list.Items = append(list.Items, &copied)
+		} else if instance := cal.instance(id); instance != nil {
+			list.Items = append(list.Items, instance)
+		}
+	} else {
+		list.Items = cal.sorted(func(e *calendar.Event) bool { return e.RecurringEventId == master.Id })
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// instance synthesizes the unmodified occurrence of a recurring event with an
+// instance ID of the form <eventId>_<start>, or returns nil if there is none
+func (c *fakeCalendar) instance(id string) *calendar.Event {
+	masterID, stamp, ok := strings.Cut(id, "_")
+	if !ok {
+		return nil
+	}
+	master, ok := c.events[masterID]
+	if !ok || len(master.Recurrence) == 0 {
+		return nil
+	}
+
+	instance := *master
+	instance.Id = id
+	instance.RecurringEventId = master.Id
+	instance.Recurrence = nil
+
+	if master.Start.Date != "" {
+		start, err := time.Parse("20060102", stamp)
+		if err != nil {
+			return nil
+		}
+		days := int(eventTime(master.End).Sub(eventTime(master.Start)).Hours() / 24)
+		instance.OriginalStartTime = &calendar.EventDateTime{Date: start.Format("2006-01-02")}
+		instance.Start = instance.OriginalStartTime
+		instance.End = &calendar.EventDateTime{Date: start.AddDate(0, 0, days).Format("2006-01-02")}
+		return &instance
+	}
+
+	start, err := time.Parse("20060102T150405Z", stamp)
+	if err != nil {
+		return nil
+	}
+	duration := eventTime(master.End).Sub(eventTime(master.Start))
+	instance.OriginalStartTime = &calendar.EventDateTime{DateTime: start.Format(time.RFC3339), TimeZone: master.Start.TimeZone}
+	instance.Start = instance.OriginalStartTime
+	instance.End = &calendar.EventDateTime{DateTime: start.Add(duration).Format(time.RFC3339), TimeZone: master.End.TimeZone}
+	return &instance
+}
+
+func (s *Service) freeBusy(w http.ResponseWriter, r *http.Request) {
+	var req calendar.FreeBusyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+
+	timeMin, err1 := time.Parse(time.RFC3339, req.TimeMin)
+	timeMax, err2 := time.Parse(time.RFC3339, req.TimeMax)
+	if err1 != nil || err2 != nil {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid timeMin or timeMax.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &calendar.FreeBusyResponse{
+		Kind:      "calendar#freeBusy",
+		TimeMin:   req.TimeMin,
+		TimeMax:   req.TimeMax,
+		Calendars: make(map[string]calendar.FreeBusyCalendar),
+	}
+
+	for _, item := range req.Items {
+		cal := s.calendar(item.Id)
+		if cal == nil {
+			resp.Calendars[item.Id] = calendar.FreeBusyCalendar{
+				Errors: []*calendar.Error{{Domain: "global", Reason: "notFound"}},
+			}
+			continue
+		}
+
+		busy := []*calendar.TimePeriod{}
+		for _, e := range cal.sorted(func(e *calendar.Event) bool { return e.Status != "cancelled" }) {
+			if e.Transparency == "transparent" {
+				continue
+			}
+			start, end := eventTime(e.Start), eventTime(e.End)
+			if start.Before(timeMax) && end.After(timeMin) {
+				busy = append(busy, &calendar.TimePeriod{
+					Start: start.UTC().Format(time.RFC3339),
+					End:   end.UTC().Format(time.RFC3339),
+				})
+			}
+		}
+		resp.Calendars[item.Id] = calendar.FreeBusyCalendar{Busy: busy}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// batch runs each embedded request of a multipart/mixed batch request in turn
+// and replies with the embedded responses, keyed by Content-ID
+func (s *Service) batch(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		writeError(w, http.StatusBadRequest, "badContent", "Batch requests must be multipart/mixed.")
+		return
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for count := 0; ; count++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "badContent", err.Error())
+			return
+		}
+		if count == maxBatchSize {
+			writeError(w, http.StatusBadRequest, "badRequest", "Too many requests in batch.")
+			return
+		}
+
+		inner, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "badContent", err.Error())
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, inner.WithContext(r.Context()))
+		resp := rec.Result()
+
+		header := textproto.MIMEHeader{"Content-Type": {"application/http"}}
+		if id := part.Header.Get("Content-Id"); id != "" {
+			header.Set("Content-Id", "<response-"+strings.Trim(id, "<>")+">")
+		}
+		out, _ := mw.CreatePart(header)
+		resp.Write(out)
+	}
+	mw.Close()
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// eventTime returns the instant of a timed or all-day EventDateTime
+func eventTime(dt *calendar.EventDateTime) time.Time {
+	if dt == nil {
+		return time.Time{}
+	}
+	if dt.DateTime != "" {
+		t, _ := time.Parse(time.RFC3339, dt.DateTime)
+		return t
+	}
+	t, _ := time.Parse("2006-01-02", dt.Date)
+	return t
+}
+
+// mergeJSON applies a JSON merge patch: objects are merged recursively, every
+// other value replaces the existing one
+func mergeJSON(dst, patch map[string]any) {
+	for key, value := range patch {
+		if obj, ok := value.(map[string]any); ok {
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeJSON(existing, obj)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format parsed by googleapi.CheckResponse
+func writeError(w http.ResponseWriter, status int, reason, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    status,
+			"message": message,
+			"errors": []map[string]string{
+				{"domain": "global", "reason": reason, "message": message},
+			},
+		},
+	})
+}
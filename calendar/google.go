@@ -0,0 +1,344 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// GoogleProvider stores events in Google Calendar
+type GoogleProvider struct {
+	service    *calendar.Service
+	httpClient *http.Client // Used for batch requests
+}
+
+// NewGoogleProvider creates an authenticated Google Calendar provider
+func NewGoogleProvider(ctx context.Context, credentialsPath, tokenPath string) (*GoogleProvider, error) {
+	client, err := NewAuth(credentialsPath, tokenPath).GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create calendar service: %w", err)
+	}
+
+	return &GoogleProvider{service: srv, httpClient: client}, nil
+}
+
+// NewGoogleProviderWithEndpoint creates an unauthenticated provider talking to
+// a Calendar v3 compatible server, such as the one in package fake
+func NewGoogleProviderWithEndpoint(ctx context.Context, endpoint string) (*GoogleProvider, error) {
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+
+	srv, err := calendar.NewService(ctx, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create calendar service: %w", err)
+	}
+
+	return &GoogleProvider{service: srv, httpClient: http.DefaultClient}, nil
+}
+
+// GetService returns the underlying calendar service
+func (p *GoogleProvider) GetService() *calendar.Service {
+	return p.service
+}
+
+// ListCalendars returns all calendars in the user's calendar list
+func (p *GoogleProvider) ListCalendars(ctx context.Context) ([]CalendarInfo, error) {
+	list, err := p.service.CalendarList.List().Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := make([]CalendarInfo, len(list.Items))
+	for i, item := range list.Items {
+		calendars[i] = CalendarInfo{
+			ID:      item.Id,
+			Summary: item.Summary,
+			Primary: item.Primary,
+		}
+	}
+	return calendars, nil
+}
+
+// ListEvents returns the events of a calendar, following pagination
+func (p *GoogleProvider) ListEvents(ctx context.Context, calendarID string, opts ListOptions) ([]*RemoteEvent, error) {
+	call := p.service.Events.List(calendarID).SingleEvents(false).MaxResults(250)
+	if !opts.TimeMin.IsZero() {
+		call = call.TimeMin(opts.TimeMin.Format(time.RFC3339))
+	}
+	if !opts.TimeMax.IsZero() {
+		call = call.TimeMax(opts.TimeMax.Format(time.RFC3339))
+	}
+	// Each call of PrivateExtendedProperty replaces the filters of the last
+	var filters []string
+	for name, value := range opts.Properties {
+		filters = append(filters, name+"="+value)
+	}
+	if len(filters) > 0 {
+		call = call.PrivateExtendedProperty(filters...)
+	}
+
+	var events []*RemoteEvent
+	var exceptions []*calendar.Event
+	err := call.Pages(ctx, func(page *calendar.Events) error {
+		for _, item := range page.Items {
+			// Exception instances belong to their recurring event
+			if item.RecurringEventId != "" {
+				exceptions = append(exceptions, item)
+				continue
+			}
+			remote, err := p.toRemoteEvent(item)
+			if err != nil {
+				return fmt.Errorf("failed to read event '%s': %w", item.Summary, err)
+			}
+			events = append(events, remote)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapGoogleError(err)
+	}
+
+	if err := p.attachOverrides(events, exceptions); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// CreateEvent inserts an event into a calendar
+func (p *GoogleProvider) CreateEvent(ctx context.Context, calendarID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	gEvent := p.convertToGoogleEvent(event, props)
+
+	created, err := p.service.Events.Insert(calendarID, gEvent).Context(ctx).Do()
+	if err != nil {
+		return nil, wrapGoogleError(err)
+	}
+
+	return p.toRemoteEvent(created)
+}
+
+// UpdateEvent patches an existing event in a calendar
+func (p *GoogleProvider) UpdateEvent(ctx context.Context, calendarID, eventID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	gEvent := p.convertToPatch(event, props)
+
+	updated, err := p.service.Events.Patch(calendarID, eventID, gEvent).Context(ctx).Do()
+	if err != nil {
+		return nil, wrapGoogleError(err)
+	}
+
+	return p.toRemoteEvent(updated)
+}
+
+// DeleteEvent removes an event from a calendar
+func (p *GoogleProvider) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	return wrapGoogleError(p.service.Events.Delete(calendarID, eventID).Context(ctx).Do())
+}
+
+// CancelEvent marks an event as cancelled and notifies its attendees
+func (p *GoogleProvider) CancelEvent(ctx context.Context, calendarID, eventID string) error {
+	patch := &calendar.Event{Status: "cancelled"}
+	_, err := p.service.Events.Patch(calendarID, eventID, patch).SendUpdates("all").Context(ctx).Do()
+	return wrapGoogleError(err)
+}
+
+// wrapGoogleError maps missing events onto ErrNotFound
+func wrapGoogleError(err error) error {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) && (gErr.Code == http.StatusNotFound || gErr.Code == http.StatusGone) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
+
+// toRemoteEvent converts a Google Calendar Event to a RemoteEvent
+func (p *GoogleProvider) toRemoteEvent(gEvent *calendar.Event) (*RemoteEvent, error) {
+	event, err := p.convertFromGoogleEvent(gEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &RemoteEvent{
+		ID:    gEvent.Id,
+		Link:  gEvent.HtmlLink,
+		Event: event,
+	}
+	if gEvent.ExtendedProperties != nil {
+		remote.Properties = gEvent.ExtendedProperties.Private
+	}
+	return remote, nil
+}
+
+// convertToGoogleEvent converts a CalendarEvent to a Google Calendar Event
+func (p *GoogleProvider) convertToGoogleEvent(event *models.CalendarEvent, props map[string]string) *calendar.Event {
+	gEvent := &calendar.Event{
+		Summary:     event.Name,
+		Description: event.FormatDescription(),
+		Location:    event.Location,
+	}
+
+	if len(props) > 0 {
+		gEvent.ExtendedProperties = &calendar.EventExtendedProperties{
+			Private: props,
+		}
+	}
+
+	// Set start and end times
+	gEvent.Start = eventDateTime(event.StartTime, event.AllDay)
+	gEvent.End = eventDateTime(event.EndTime, event.AllDay)
+
+	// Set recurrence rule and exceptions
+	if event.Recurrence != nil {
+		rrule := p.buildRRule(event.Recurrence)
+		if rrule != "" {
+			gEvent.Recurrence = []string{rrule}
+		}
+	}
+	gEvent.Recurrence = append(gEvent.Recurrence, exceptionLines(event)...)
+
+	// Set color
+	if event.ColorID != "" {
+		gEvent.ColorId = event.ColorID
+	}
+
+	// Set reminders
+	if len(event.Reminders) > 0 {
+		overrides := make([]*calendar.EventReminder, len(event.Reminders))
+		for i, r := range event.Reminders {
+			overrides[i] = &calendar.EventReminder{
+				Method:  r.Method,
+				Minutes: int64(r.Minutes),
+			}
+		}
+		gEvent.Reminders = &calendar.EventReminders{
+			UseDefault:      false,
+			Overrides:       overrides,
+			ForceSendFields: []string{"UseDefault"},
+		}
+	}
+
+	return gEvent
+}
+
+// convertToPatch converts a CalendarEvent to a patch of an existing Google
+// Calendar Event. Fields the event leaves empty are cleared, so that values
+// removed from the template do not stay on the calendar.
+func (p *GoogleProvider) convertToPatch(event *models.CalendarEvent, props map[string]string) *calendar.Event {
+	gEvent := p.convertToGoogleEvent(event, props)
+	gEvent.ForceSendFields = []string{"Summary", "Description", "Location"}
+	if gEvent.ColorId == "" {
+		gEvent.NullFields = append(gEvent.NullFields, "ColorId")
+	}
+	if len(gEvent.Recurrence) == 0 {
+		gEvent.NullFields = append(gEvent.NullFields, "Recurrence")
+	}
+	if gEvent.Reminders == nil {
+		gEvent.Reminders = &calendar.EventReminders{UseDefault: true, NullFields: []string{"Overrides"}}
+	}
+	return gEvent
+}
+
+// convertFromGoogleEvent converts a Google Calendar Event back to a CalendarEvent
+func (p *GoogleProvider) convertFromGoogleEvent(gEvent *calendar.Event) (models.CalendarEvent, error) {
+	description, links := models.ParseDescription(gEvent.Description)
+	event := models.CalendarEvent{
+		Name:        gEvent.Summary,
+		Description: description,
+		Location:    gEvent.Location,
+		Links:       links,
+		ColorID:     gEvent.ColorId,
+	}
+
+	var err error
+	if gEvent.Start != nil && gEvent.Start.Date != "" {
+		event.AllDay = true
+		if event.StartTime, err = time.ParseInLocation("2006-01-02", gEvent.Start.Date, time.Local); err != nil {
+			return event, fmt.Errorf("failed to parse start date: %w", err)
+		}
+		if gEvent.End != nil && gEvent.End.Date != "" {
+			if event.EndTime, err = time.ParseInLocation("2006-01-02", gEvent.End.Date, time.Local); err != nil {
+				return event, fmt.Errorf("failed to parse end date: %w", err)
+			}
+		}
+	} else {
+		if event.StartTime, err = parseEventDateTime(gEvent.Start); err != nil {
+			return event, fmt.Errorf("failed to parse start time: %w", err)
+		}
+		if event.EndTime, err = parseEventDateTime(gEvent.End); err != nil {
+			return event, fmt.Errorf("failed to parse end time: %w", err)
+		}
+	}
+
+	for _, line := range gEvent.Recurrence {
+		if strings.HasPrefix(line, "RRULE:") {
+			if event.Recurrence, err = models.ParseRRule(line); err != nil {
+				return event, err
+			}
+		}
+	}
+	if event.Exceptions, err = parseExceptionLines(gEvent.Recurrence); err != nil {
+		return event, err
+	}
+
+	if gEvent.Reminders != nil && !gEvent.Reminders.UseDefault {
+		for _, r := range gEvent.Reminders.Overrides {
+			event.Reminders = append(event.Reminders, models.Reminder{
+				Method:  r.Method,
+				Minutes: int(r.Minutes),
+			})
+		}
+	}
+
+	return event, nil
+}
+
+// parseEventDateTime parses a timed EventDateTime, honouring its time zone
+func parseEventDateTime(dt *calendar.EventDateTime) (time.Time, error) {
+	if dt == nil || dt.DateTime == "" {
+		return time.Time{}, fmt.Errorf("missing date-time")
+	}
+
+	t, err := time.Parse(time.RFC3339, dt.DateTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if dt.TimeZone != "" {
+		if loc, err := time.LoadLocation(dt.TimeZone); err == nil {
+			t = t.In(loc)
+		}
+	}
+
+	return t, nil
+}
+
+// buildRRule creates an RRULE string from RecurrenceRule
+func (p *GoogleProvider) buildRRule(r *models.RecurrenceRule) string {
+	return r.ToRRuleString()
+}
+
+// DryRunEvent validates an event without creating it
+func (p *GoogleProvider) DryRunEvent(event *models.CalendarEvent) *calendar.Event {
+	return p.convertToGoogleEvent(event, nil)
+}
+
+// DryRunEvents validates multiple events and returns Google Calendar event representations
+func (p *GoogleProvider) DryRunEvents(events []models.CalendarEvent) []*calendar.Event {
+	gEvents := make([]*calendar.Event, len(events))
+	for i, e := range events {
+		gEvents[i] = p.DryRunEvent(&e)
+	}
+	return gEvents
+}
@@ -0,0 +1,173 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Batch sends ops as a single multipart/mixed request to the Google batch endpoint
+func (p *GoogleProvider) Batch(ctx context.Context, calendarID string, ops []BatchOp) ([]BatchResult, error) {
+	base, err := url.Parse(p.service.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API base path: %w", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, op := range ops {
+		if err := p.writeBatchPart(mw, base.Path, calendarID, i, op); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	// The batch endpoint for https://host/calendar/v3/ is https://host/batch/calendar/v3
+	batchURL := *base
+	batchURL.Path = path.Join("/batch", base.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, batchURL.String(), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return p.readBatchResponse(resp, ops)
+}
+
+// writeBatchPart writes one op as an embedded HTTP request
+func (p *GoogleProvider) writeBatchPart(mw *multipart.Writer, basePath, calendarID string, index int, op BatchOp) error {
+	target := basePath + "calendars/" + url.PathEscape(calendarID) + "/events"
+	if op.EventID != "" {
+		target += "/" + url.PathEscape(op.EventID)
+	}
+
+	var method string
+	var gEvent *calendar.Event
+	switch op.Method {
+	case BatchCreate:
+		method = http.MethodPost
+		gEvent = p.convertToGoogleEvent(op.Event, op.Props)
+	case BatchUpdate:
+		method = http.MethodPatch
+		gEvent = p.convertToPatch(op.Event, op.Props)
+	case BatchDelete:
+		method = http.MethodDelete
+	case BatchCancel:
+		method = http.MethodPatch
+		target += "?sendUpdates=all"
+		gEvent = &calendar.Event{Status: "cancelled"}
+	default:
+		return fmt.Errorf("unknown batch method: %s", op.Method)
+	}
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"application/http"},
+		"Content-Id":   {"<item" + strconv.Itoa(index) + ">"},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(part, "%s %s HTTP/1.1\r\n", method, target)
+	if gEvent == nil {
+		_, err = io.WriteString(part, "\r\n")
+		return err
+	}
+
+	data, err := json.Marshal(gEvent)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(part, "Content-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(data))
+	_, err = part.Write(data)
+	return err
+}
+
+// readBatchResponse maps the parts of a multipart/mixed reply back onto ops
+// using their Content-ID
+func (p *GoogleProvider) readBatchResponse(resp *http.Response, ops []BatchOp) ([]BatchResult, error) {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response type %q", resp.Header.Get("Content-Type"))
+	}
+
+	results := make([]BatchResult, len(ops))
+	seen := make([]bool, len(ops))
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch response: %w", err)
+		}
+
+		id := strings.Trim(part.Header.Get("Content-Id"), "<>")
+		index, err := strconv.Atoi(strings.TrimPrefix(id, "response-item"))
+		if err != nil || index < 0 || index >= len(ops) {
+			return nil, fmt.Errorf("unexpected part %q in batch response", id)
+		}
+
+		inner, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch response part %s: %w", id, err)
+		}
+		results[index] = p.readBatchItem(inner, ops[index])
+		inner.Body.Close()
+		seen[index] = true
+	}
+
+	for i := range results {
+		if !seen[i] {
+			results[i].Err = fmt.Errorf("no response for batch item %d", i)
+		}
+	}
+	return results, nil
+}
+
+// readBatchItem turns one embedded HTTP response into a BatchResult
+func (p *GoogleProvider) readBatchItem(resp *http.Response, op BatchOp) BatchResult {
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return BatchResult{Err: wrapGoogleError(err)}
+	}
+
+	if op.Method == BatchDelete || op.Method == BatchCancel {
+		return BatchResult{}
+	}
+
+	var gEvent calendar.Event
+	if err := json.NewDecoder(resp.Body).Decode(&gEvent); err != nil {
+		return BatchResult{Err: fmt.Errorf("invalid batch response: %w", err)}
+	}
+
+	remote, err := p.toRemoteEvent(&gEvent)
+	return BatchResult{Remote: remote, Err: err}
+}
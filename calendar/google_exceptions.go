@@ -0,0 +1,202 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+	"google.golang.org/api/calendar/v3"
+)
+
+// exceptionLines returns the EXDATE and RDATE lines of an event's recurrence
+func exceptionLines(event *models.CalendarEvent) []string {
+	if event.Exceptions == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, t := range event.Exceptions.ExcludeDates {
+		lines = append(lines, "EXDATE"+formatRecurrenceDate(t, event.AllDay))
+	}
+	for _, t := range event.Exceptions.ExtraDates {
+		lines = append(lines, "RDATE"+formatRecurrenceDate(t, event.AllDay))
+	}
+	return lines
+}
+
+// formatRecurrenceDate formats the parameters and value of an EXDATE or RDATE
+// line, in the event's time zone when it has one
+func formatRecurrenceDate(t time.Time, allDay bool) string {
+	if allDay {
+		return ";VALUE=DATE:" + t.Format("20060102")
+	}
+	if tz := t.Location().String(); tz != "Local" && tz != "UTC" {
+		return ";TZID=" + tz + ":" + t.Format("20060102T150405")
+	}
+	return ":" + t.UTC().Format("20060102T150405Z")
+}
+
+// parseExceptionLines reads the EXDATE and RDATE lines of a Google recurrence
+func parseExceptionLines(lines []string) (*models.Exceptions, error) {
+	exceptions := &models.Exceptions{}
+
+	for _, line := range lines {
+		head, values, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(head, ";")
+
+		var target *[]time.Time
+		switch strings.ToUpper(params[0]) {
+		case "EXDATE":
+			target = &exceptions.ExcludeDates
+		case "RDATE":
+			target = &exceptions.ExtraDates
+		default:
+			continue
+		}
+
+		loc := time.Local
+		for _, param := range params[1:] {
+			if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+				var err error
+				if loc, err = time.LoadLocation(tzid); err != nil {
+					return nil, fmt.Errorf("invalid %s: %w", line, err)
+				}
+			}
+		}
+
+		for _, value := range strings.Split(values, ",") {
+			var t time.Time
+			var err error
+			switch {
+			case strings.HasSuffix(value, "Z"):
+				t, err = time.Parse("20060102T150405Z", value)
+			case strings.Contains(value, "T"):
+				t, err = time.ParseInLocation("20060102T150405", value, loc)
+			default:
+				t, err = time.ParseInLocation("20060102", value, loc)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", line, err)
+			}
+			*target = append(*target, t)
+		}
+	}
+
+	if len(exceptions.ExcludeDates)+len(exceptions.ExtraDates) == 0 {
+		return nil, nil
+	}
+	return exceptions, nil
+}
+
+// OccurrenceID returns the ID of the occurrence of a recurring event that
+// starts at start, or "" if the event has none
+func (p *GoogleProvider) OccurrenceID(ctx context.Context, calendarID, eventID string, start time.Time) (string, error) {
+	instances, err := p.service.Events.Instances(calendarID, eventID).
+		OriginalStart(start.Format(time.RFC3339)).
+		Context(ctx).Do()
+	if err != nil {
+		return "", wrapGoogleError(err)
+	}
+	if len(instances.Items) == 0 {
+		return "", nil
+	}
+	return instances.Items[0].Id, nil
+}
+
+// OverrideOccurrence patches an occurrence of a recurring event, turning it
+// into an exception instance
+func (p *GoogleProvider) OverrideOccurrence(ctx context.Context, calendarID, occurrenceID string, event *models.CalendarEvent, o models.Override) error {
+	patch := &calendar.Event{
+		Location: o.Location,
+		Start:    eventDateTime(o.StartTime, event.AllDay),
+		End:      eventDateTime(o.EndTime, event.AllDay),
+	}
+	if o.Description != "" {
+		patch.Description = (&models.CalendarEvent{Description: o.Description, Links: event.Links}).FormatDescription()
+	}
+
+	_, err := p.service.Events.Patch(calendarID, occurrenceID, patch).Context(ctx).Do()
+	return wrapGoogleError(err)
+}
+
+// RestoreOccurrence patches an exception instance of a recurring event back to
+// the time, location and description of the series
+func (p *GoogleProvider) RestoreOccurrence(ctx context.Context, calendarID, occurrenceID string, event *models.CalendarEvent, originalStart time.Time) error {
+	start := originalStart.In(event.StartTime.Location())
+	patch := &calendar.Event{
+		Location:        event.Location,
+		Description:     event.FormatDescription(),
+		Start:           eventDateTime(start, event.AllDay),
+		End:             eventDateTime(start.Add(event.EndTime.Sub(event.StartTime)), event.AllDay),
+		ForceSendFields: []string{"Location", "Description"},
+	}
+
+	_, err := p.service.Events.Patch(calendarID, occurrenceID, patch).Context(ctx).Do()
+	return wrapGoogleError(err)
+}
+
+// attachOverrides adds the modified exception instances listed with a set of
+// events to their recurring events as overrides. Cancelled instances are left
+// out, as are instances whose recurring event is not in the set and instances
+// that match the series again, see RestoreOccurrence.
+func (p *GoogleProvider) attachOverrides(events []*RemoteEvent, exceptions []*calendar.Event) error {
+	masters := make(map[string]*models.CalendarEvent, len(events))
+	for _, e := range events {
+		masters[e.ID] = &e.Event
+	}
+
+	for _, item := range exceptions {
+		master, ok := masters[item.RecurringEventId]
+		if !ok || item.Status == "cancelled" || item.OriginalStartTime == nil {
+			continue
+		}
+
+		occurrence, err := p.convertFromGoogleEvent(item)
+		if err != nil {
+			return fmt.Errorf("failed to read occurrence of '%s': %w", master.Name, err)
+		}
+		o := models.Override{StartTime: occurrence.StartTime, EndTime: occurrence.EndTime}
+		if master.AllDay {
+			o.OriginalStart, err = time.ParseInLocation("2006-01-02", item.OriginalStartTime.Date, time.Local)
+		} else {
+			o.OriginalStart, err = parseEventDateTime(item.OriginalStartTime)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read occurrence of '%s': %w", master.Name, err)
+		}
+		if occurrence.Location != master.Location {
+			o.Location = occurrence.Location
+		}
+		if occurrence.Description != master.Description {
+			o.Description = occurrence.Description
+		}
+		if sameStart(o.StartTime, o.OriginalStart, master.AllDay) && o.EndTime.Sub(o.StartTime) == master.EndTime.Sub(master.StartTime) &&
+			o.Location == "" && o.Description == "" {
+			continue
+		}
+
+		if master.Exceptions == nil {
+			master.Exceptions = &models.Exceptions{}
+		}
+		master.Exceptions.Overrides = append(master.Exceptions.Overrides, o)
+	}
+	return nil
+}
+
+// eventDateTime converts a time to a timed or all-day EventDateTime
+func eventDateTime(t time.Time, allDay bool) *calendar.EventDateTime {
+	if allDay {
+		return &calendar.EventDateTime{Date: t.Format("2006-01-02")}
+	}
+
+	dt := &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}
+	if tz := t.Location().String(); tz != "Local" {
+		dt.TimeZone = tz
+	}
+	return dt
+}
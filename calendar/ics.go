@@ -0,0 +1,98 @@
+package calendar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	ical "github.com/arran4/golang-ical"
+	"github.com/monil/calendar-event-generator/exporter"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// icsProperties lists the private properties stored as X- properties in ICS data
+var icsProperties = []string{propertyKey, propertyHash, propertySource}
+
+// icsPropertyName maps a private property to its ICS name, e.g. cegKey -> X-CEG-KEY
+func icsPropertyName(name string) string {
+	return "X-CEG-" + strings.ToUpper(strings.TrimPrefix(name, "ceg"))
+}
+
+// newUID generates a random UID for a new ICS event
+func newUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("unable to generate UID: %v", err))
+	}
+	return hex.EncodeToString(b) + "@calendar-generator"
+}
+
+// encodeICS serializes a single event as an iCalendar object
+func encodeICS(uid string, event *models.CalendarEvent, props map[string]string) []byte {
+	cal := exporter.NewCalendar("")
+
+	xProps := make(map[string]string, len(props))
+	for name, value := range props {
+		xProps[icsPropertyName(name)] = value
+	}
+	exporter.AddEvent(cal, *event, uid, xProps)
+
+	var buf bytes.Buffer
+	cal.SerializeTo(&buf)
+	return buf.Bytes()
+}
+
+// decodeICS parses an iCalendar object holding a single event, returning its
+// UID and the master event (the VEVENT without a RECURRENCE-ID) with the other
+// VEVENTs attached as overrides
+func decodeICS(data []byte) (string, *RemoteEvent, error) {
+	cal, err := ical.ParseCalendar(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid iCalendar data: %w", err)
+	}
+
+	var uid string
+	var remote *RemoteEvent
+	var overrides []*ical.VEvent
+	for _, vevent := range cal.Events() {
+		if vevent.GetProperty(ical.ComponentPropertyRecurrenceId) != nil {
+			overrides = append(overrides, vevent)
+			continue
+		}
+		if remote != nil {
+			continue
+		}
+
+		event, err := exporter.ParseEvent(vevent)
+		if err != nil {
+			return "", nil, err
+		}
+
+		uid = vevent.Id()
+		remote = &RemoteEvent{Event: event, Properties: make(map[string]string)}
+		for _, name := range icsProperties {
+			if p := vevent.GetProperty(ical.ComponentProperty(icsPropertyName(name))); p != nil {
+				remote.Properties[name] = p.Value
+			}
+		}
+	}
+
+	if remote == nil {
+		return "", nil, fmt.Errorf("iCalendar data contains no event")
+	}
+
+	for _, vevent := range overrides {
+		o, err := exporter.ParseOverride(vevent, remote.Event)
+		if err != nil {
+			return "", nil, err
+		}
+		if remote.Event.Exceptions == nil {
+			remote.Event.Exceptions = &models.Exceptions{}
+		}
+		remote.Event.Exceptions.Overrides = append(remote.Event.Exceptions.Overrides, o)
+	}
+
+	return uid, remote, nil
+}
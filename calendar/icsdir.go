@@ -0,0 +1,170 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// ICSDirProvider stores events as .ics files in a local directory, one file per
+// event, for offline use. The root directory is the "primary" calendar and each
+// subdirectory is another calendar. Event IDs are file names without extension.
+type ICSDirProvider struct {
+	root string
+}
+
+// NewICSDirProvider creates a provider rooted at dir
+func NewICSDirProvider(dir string) *ICSDirProvider {
+	return &ICSDirProvider{root: dir}
+}
+
+// ListCalendars returns the root directory and its subdirectories
+func (p *ICSDirProvider) ListCalendars(ctx context.Context) ([]CalendarInfo, error) {
+	calendars := []CalendarInfo{{ID: "primary", Summary: filepath.Base(p.root), Primary: true}}
+
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return calendars, nil
+		}
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			calendars = append(calendars, CalendarInfo{ID: e.Name(), Summary: e.Name()})
+		}
+	}
+	return calendars, nil
+}
+
+// ListEvents reads every .ics file of a calendar directory
+func (p *ICSDirProvider) ListEvents(ctx context.Context, calendarID string, opts ListOptions) ([]*RemoteEvent, error) {
+	dir, err := p.dir(calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var events []*RemoteEvent
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".ics") {
+			continue
+		}
+
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		_, remote, err := decodeICS(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if !opts.matches(remote) {
+			continue
+		}
+
+		remote.ID = strings.TrimSuffix(e.Name(), ".ics")
+		remote.Link = path
+		events = append(events, remote)
+	}
+	return events, nil
+}
+
+// CreateEvent writes a new .ics file
+func (p *ICSDirProvider) CreateEvent(ctx context.Context, calendarID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	dir, err := p.dir(calendarID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	uid := newUID()
+	id := strings.TrimSuffix(uid, "@calendar-generator")
+	path := filepath.Join(dir, id+".ics")
+	if err := os.WriteFile(path, encodeICS(uid, event, props), 0644); err != nil {
+		return nil, err
+	}
+
+	return &RemoteEvent{ID: id, Link: path, Event: *event, Properties: props}, nil
+}
+
+// UpdateEvent overwrites an existing .ics file, keeping its UID
+func (p *ICSDirProvider) UpdateEvent(ctx context.Context, calendarID, eventID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error) {
+	path, err := p.eventPath(calendarID, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return nil, err
+	}
+	uid, _, err := decodeICS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path, encodeICS(uid, event, props), 0644); err != nil {
+		return nil, err
+	}
+
+	return &RemoteEvent{ID: eventID, Link: path, Event: *event, Properties: props}, nil
+}
+
+// DeleteEvent removes an .ics file
+func (p *ICSDirProvider) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	path, err := p.eventPath(calendarID, eventID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return err
+	}
+	return nil
+}
+
+// dir returns the directory holding a calendar
+func (p *ICSDirProvider) dir(calendarID string) (string, error) {
+	if calendarID == "" || calendarID == "primary" {
+		return p.root, nil
+	}
+	if !filepath.IsLocal(calendarID) {
+		return "", fmt.Errorf("invalid calendar ID: %s", calendarID)
+	}
+	return filepath.Join(p.root, calendarID), nil
+}
+
+// eventPath returns the file holding an event
+func (p *ICSDirProvider) eventPath(calendarID, eventID string) (string, error) {
+	dir, err := p.dir(calendarID)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(eventID) || strings.ContainsRune(eventID, filepath.Separator) {
+		return "", fmt.Errorf("invalid event ID: %s", eventID)
+	}
+	return filepath.Join(dir, eventID+".ics"), nil
+}
@@ -0,0 +1,187 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// icsFiles returns the names of the .ics files in dir, sorted
+func icsFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, "*.ics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		names[i] = filepath.Base(name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestICSDirUpsert(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	client := NewClientWithProvider(NewICSDirProvider(root), "work")
+	client.SetRateLimit(0)
+	client.SetSource("/templates/test.yaml")
+	events := testEvents(2)
+
+	results, err := client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionCreated, ActionCreated}; !equalActions(got, want) {
+		t.Errorf("first run: got %v, want %v", got, want)
+	}
+	dir := filepath.Join(root, "work")
+	if n := len(icsFiles(t, dir)); n != 2 {
+		t.Fatalf("calendar directory has %d files, want 2", n)
+	}
+	if n := len(icsFiles(t, root)); n != 0 {
+		t.Errorf("primary calendar has %d files, want 0", n)
+	}
+
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUnchanged}; !equalActions(got, want) {
+		t.Errorf("second run: got %v, want %v", got, want)
+	}
+
+	// An update rewrites the file in place, keeping its UID
+	file := filepath.Join(dir, results[1].Remote.ID+".ics")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, _, err := decodeICS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events[1].Location = "Room 2"
+	results, err = client.UpsertEvents(ctx, events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, results), []EventAction{ActionUnchanged, ActionUpdated}; !equalActions(got, want) {
+		t.Errorf("after an edit: got %v, want %v", got, want)
+	}
+
+	data, err = os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotUID, remote, err := decodeICS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUID != uid {
+		t.Errorf("UID changed from %s to %s", uid, gotUID)
+	}
+	if remote.Event.Location != "Room 2" {
+		t.Errorf("updated event has location %q, want %q", remote.Event.Location, "Room 2")
+	}
+	if remote.Properties[propertySource] != "/templates/test.yaml" {
+		t.Errorf("event source is %q, want %q", remote.Properties[propertySource], "/templates/test.yaml")
+	}
+
+	// Dropping an event from the template orphans it; pruning deletes its file
+	plan, err := client.Plan(ctx, events[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actions(t, client.RemoveOrphans(ctx, plan, false, nil)), []EventAction{ActionDeleted}; !equalActions(got, want) {
+		t.Errorf("pruning: got %v, want %v", got, want)
+	}
+	if got := icsFiles(t, dir); len(got) != 1 || got[0] == filepath.Base(file) {
+		t.Errorf("calendar directory has %v after pruning, want only the first event", got)
+	}
+}
+
+func TestICSDirListCalendars(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "calendars")
+	p := NewICSDirProvider(root)
+
+	// A missing root is an empty primary calendar
+	calendars, err := p.ListCalendars(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []CalendarInfo{{ID: "primary", Summary: "calendars", Primary: true}}; fmt.Sprint(calendars) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", calendars, want)
+	}
+	if events, err := p.ListEvents(ctx, "primary", ListOptions{}); err != nil || len(events) != 0 {
+		t.Errorf("missing root: got %d events and error %v, want none", len(events), err)
+	}
+
+	if _, err := p.CreateEvent(ctx, "team", &testEvents(1)[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	calendars, err = p.ListCalendars(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CalendarInfo{{ID: "primary", Summary: "calendars", Primary: true}, {ID: "team", Summary: "team"}}
+	if fmt.Sprint(calendars) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", calendars, want)
+	}
+}
+
+func TestICSDirListEvents(t *testing.T) {
+	ctx := context.Background()
+	p := NewICSDirProvider(t.TempDir())
+	events := testEvents(3)
+	for i := range events {
+		if _, err := p.CreateEvent(ctx, "primary", &events[i], map[string]string{propertyKey: events[i].StableKey()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only events overlapping the window are listed
+	opts := ListOptions{TimeMin: events[1].StartTime, TimeMax: events[1].EndTime}
+	remote, err := p.ListEvents(ctx, "primary", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remote) != 1 || remote[0].Event.Name != "Event 2" {
+		t.Fatalf("got %d events, want Event 2 only", len(remote))
+	}
+	if remote[0].Properties[propertyKey] != events[1].StableKey() {
+		t.Errorf("got key %q, want %q", remote[0].Properties[propertyKey], events[1].StableKey())
+	}
+	if !remote[0].Event.StartTime.Equal(events[1].StartTime) || !remote[0].Event.EndTime.Equal(events[1].EndTime) {
+		t.Errorf("got %s to %s, want %s to %s", remote[0].Event.StartTime, remote[0].Event.EndTime, events[1].StartTime, events[1].EndTime)
+	}
+}
+
+func TestICSDirInvalidIDs(t *testing.T) {
+	ctx := context.Background()
+	p := NewICSDirProvider(t.TempDir())
+	event := &testEvents(1)[0]
+
+	for _, id := range []string{"../outside", "/etc"} {
+		if _, err := p.CreateEvent(ctx, id, event, nil); err == nil {
+			t.Errorf("calendar ID %q: got no error", id)
+		}
+	}
+	for _, id := range []string{"../outside", "a/b", ".."} {
+		if err := p.DeleteEvent(ctx, "primary", id); err == nil || IsNotFound(err) {
+			t.Errorf("event ID %q: got %v, want an invalid ID error", id, err)
+		}
+	}
+
+	if err := p.DeleteEvent(ctx, "primary", "missing"); !IsNotFound(err) {
+		t.Errorf("deleting a missing event: got %v, want ErrNotFound", err)
+	}
+	if _, err := p.UpdateEvent(ctx, "primary", "missing", event, nil); !IsNotFound(err) {
+		t.Errorf("updating a missing event: got %v, want ErrNotFound", err)
+	}
+}
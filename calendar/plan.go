@@ -0,0 +1,304 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// PlanAction describes what applying a template would do to one event
+type PlanAction string
+
+const (
+	PlanCreate  PlanAction = "create"
+	PlanUpdate  PlanAction = "update"
+	PlanReplace PlanAction = "replace" // Update of an orphan the event looks like an edit of, see pairEdited
+	PlanNoop    PlanAction = "noop"
+	PlanOrphan  PlanAction = "orphan" // Created by this tool but no longer in the template
+)
+
+// FieldChange is a single field that differs between the calendar and the template
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// PlanEntry is the planned action for a single event
+type PlanEntry struct {
+	Action  PlanAction
+	Event   *models.CalendarEvent // Template event, nil for orphans
+	Remote  *RemoteEvent          // Existing calendar event, nil for creates
+	Changes []FieldChange         // Only set for updates and replaces
+}
+
+// Name returns the display name of the event the entry refers to
+func (e *PlanEntry) Name() string {
+	if e.Event != nil {
+		return e.Event.Name
+	}
+	return e.Remote.Event.Name
+}
+
+// Plan is the difference between a template and the live calendar
+type Plan struct {
+	Entries []*PlanEntry
+}
+
+// Count returns the number of entries with the given action
+func (p *Plan) Count(action PlanAction) int {
+	n := 0
+	for _, e := range p.Entries {
+		if e.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Replaces reports whether the plan creates events while others of the same
+// source are orphaned, as happens when events without an ID are edited in ways
+// pairEdited cannot match. Adding such a template leaves the old copies behind.
+func (p *Plan) Replaces() bool {
+	return p.Count(PlanCreate) > 0 && p.Count(PlanOrphan) > 0
+}
+
+// Unpair turns the replaces of a plan back into a create of the template
+// event and an orphan of the calendar event it would have replaced
+func (p *Plan) Unpair() {
+	var orphans []*PlanEntry
+	for _, e := range p.Entries {
+		if e.Action != PlanReplace {
+			continue
+		}
+		orphans = append(orphans, &PlanEntry{Action: PlanOrphan, Remote: e.Remote})
+		e.Action, e.Remote, e.Changes = PlanCreate, nil, nil
+	}
+	p.Entries = append(p.Entries, orphans...)
+	sort.SliceStable(p.Entries, func(i, j int) bool {
+		a, b := p.Entries[i], p.Entries[j]
+		if (a.Action == PlanOrphan) != (b.Action == PlanOrphan) {
+			return b.Action == PlanOrphan
+		}
+		return a.Action == PlanOrphan && a.Remote.Event.StartTime.Before(b.Remote.Event.StartTime)
+	})
+}
+
+// Filter returns the plan without the entries for template events that keep
+// rejects. Orphans are kept.
+func (p *Plan) Filter(keep func(*models.CalendarEvent) bool) *Plan {
+	filtered := &Plan{}
+	for _, e := range p.Entries {
+		if e.Event == nil || keep(e.Event) {
+			filtered.Entries = append(filtered.Entries, e)
+		}
+	}
+	return filtered
+}
+
+// Plan compares events against the events this tool previously created in the
+// template's time window. Entries for template events keep the template order;
+// orphaned calendar events follow, sorted by start time. When a source is set,
+// only events owned by it are reported as orphans, wherever they are in the
+// calendar, and matching events from another source are updated to take ownership.
+// Template events whose ID is a calendar event ID, as written by Pull, match
+// that event even if this tool did not create it. Events created before the
+// description was dropped from StableKey match on their legacy key and are
+// updated to the new one. New events that look like edits of an orphan
+// replace it, see pairEdited.
+func (c *Client) Plan(ctx context.Context, events []models.CalendarEvent) (*Plan, error) {
+	existing, err := c.findManagedEvents(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	seen := make(map[string]bool)
+	matched := make(map[string]bool) // Calendar event IDs matched by a template event
+
+	for i := range events {
+		event := &events[i]
+		key := event.StableKey()
+
+		remote, found := existing[key]
+		if !found && event.ID == "" {
+			remote, found = existing[event.LegacyStableKey()]
+		}
+		entry := &PlanEntry{Event: event, Remote: remote}
+
+		switch {
+		case seen[key]:
+			// Duplicate of an earlier template event, it is only created once
+			entry.Action = PlanNoop
+		case !found:
+			entry.Action = PlanCreate
+		case remote.Properties[propertyHash] == event.ContentHash() && remote.Properties[propertyKey] == key && c.owns(remote):
+			entry.Action = PlanNoop
+		default:
+			entry.Action = PlanUpdate
+			entry.Changes = diffEvents(remote.Event, *event)
+		}
+
+		seen[key] = true
+		if found {
+			matched[remote.ID] = true
+		}
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	var orphans []*PlanEntry
+	for key, remote := range existing {
+		// Only managed events are orphaned, each under its own key
+		if !seen[key] && !matched[remote.ID] && remote.Properties[propertyKey] == key && c.owns(remote) {
+			orphans = append(orphans, &PlanEntry{Action: PlanOrphan, Remote: remote})
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Remote.Event.StartTime.Before(orphans[j].Remote.Event.StartTime)
+	})
+	orphans = pairEdited(plan.Entries, orphans)
+	plan.Entries = append(plan.Entries, orphans...)
+
+	return plan, nil
+}
+
+// pairEdited turns creates that are edits of an orphan into replaces of it.
+// Editing the name of an event without an ID changes its stable key, so it
+// would otherwise be created again next to its old copy. A create is paired
+// with the only orphan of the same start, if that orphan is not claimed by
+// another create. Callers show replaces before applying them, see Unpair.
+// The orphans that are left are returned.
+func pairEdited(entries, orphans []*PlanEntry) []*PlanEntry {
+	sameStart := func(a, b *models.CalendarEvent) bool {
+		return a.StartTime.Equal(b.StartTime) && a.AllDay == b.AllDay
+	}
+
+	claims := make(map[*PlanEntry]int) // Creates of each orphan's start
+	for _, entry := range entries {
+		if entry.Action != PlanCreate {
+			continue
+		}
+		for _, orphan := range orphans {
+			if sameStart(&orphan.Remote.Event, entry.Event) {
+				claims[orphan]++
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.Action != PlanCreate || entry.Event.ID != "" {
+			continue
+		}
+
+		found := -1
+		for i, orphan := range orphans {
+			if !sameStart(&orphan.Remote.Event, entry.Event) {
+				continue
+			}
+			if found >= 0 || claims[orphan] > 1 {
+				found = -1 // Ambiguous, leave them all alone
+				break
+			}
+			found = i
+		}
+		if found < 0 {
+			continue
+		}
+
+		entry.Action = PlanReplace
+		entry.Remote = orphans[found].Remote
+		entry.Changes = diffEvents(entry.Remote.Event, *entry.Event)
+		orphans = slices.Delete(orphans, found, found+1)
+	}
+	return orphans
+}
+
+// owns reports whether a managed calendar event belongs to the client's source
+func (c *Client) owns(e *RemoteEvent) bool {
+	return c.source == "" || e.Properties[propertySource] == c.source
+}
+
+// diffEvents lists the user-visible fields that differ between old and new
+func diffEvents(old, new models.CalendarEvent) []FieldChange {
+	var changes []FieldChange
+	add := func(field, o, n string) {
+		if o != n {
+			changes = append(changes, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+
+	add("name", old.Name, new.Name)
+	add("description", old.Description, new.Description)
+	add("location", old.Location, new.Location)
+	add("all_day", fmt.Sprint(old.AllDay), fmt.Sprint(new.AllDay))
+	add("start", formatPlanTime(old.StartTime, old.AllDay), formatPlanTime(new.StartTime, new.AllDay))
+	add("end", formatPlanTime(old.EndTime, old.AllDay), formatPlanTime(new.EndTime, new.AllDay))
+	add("recurrence", old.Recurrence.ToRRuleString(), new.Recurrence.ToRRuleString())
+	add("color_id", old.ColorID, new.ColorID)
+
+	if !slices.Equal(old.Links, new.Links) {
+		add("links", fmt.Sprint(old.Links), fmt.Sprint(new.Links))
+	}
+	if !slices.Equal(old.Reminders, new.Reminders) {
+		add("reminders", fmt.Sprint(old.Reminders), fmt.Sprint(new.Reminders))
+	}
+
+	var oldExceptions, newExceptions models.Exceptions
+	if old.Exceptions != nil {
+		oldExceptions = *old.Exceptions
+	}
+	if new.Exceptions != nil {
+		newExceptions = *new.Exceptions
+	}
+	add("exclude_dates", formatPlanTimes(oldExceptions.ExcludeDates, old.AllDay), formatPlanTimes(newExceptions.ExcludeDates, new.AllDay))
+	add("extra_dates", formatPlanTimes(oldExceptions.ExtraDates, old.AllDay), formatPlanTimes(newExceptions.ExtraDates, new.AllDay))
+	add("overrides", formatOverrides(old, oldExceptions.Overrides), formatOverrides(new, newExceptions.Overrides))
+
+	return changes
+}
+
+// formatPlanTimes formats a list of times for display in a plan, in order
+func formatPlanTimes(times []time.Time, allDay bool) string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = formatPlanTime(t, allDay)
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ", ")
+}
+
+// formatOverrides formats the overrides of an event for display in a plan, in
+// order of the occurrences they replace. A location or description equal to
+// the event's is shown as kept, as calendars report it either way.
+func formatOverrides(event models.CalendarEvent, overrides []models.Override) string {
+	formatted := make([]string, len(overrides))
+	for i, o := range overrides {
+		f := fmt.Sprintf("%s: %s to %s", formatPlanTime(o.OriginalStart, event.AllDay),
+			formatPlanTime(o.StartTime, event.AllDay), formatPlanTime(o.EndTime, event.AllDay))
+		if o.Location != "" && o.Location != event.Location {
+			f += fmt.Sprintf(" at %q", o.Location)
+		}
+		if o.Description != "" && o.Description != event.Description {
+			f += fmt.Sprintf(" (%q)", o.Description)
+		}
+		formatted[i] = f
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, "; ")
+}
+
+// formatPlanTime formats a time for display in a plan, comparing instants in UTC
+func formatPlanTime(t time.Time, allDay bool) string {
+	if t.IsZero() {
+		return ""
+	}
+	if allDay {
+		return t.Format("2006-01-02")
+	}
+	return t.UTC().Format(time.RFC3339)
+}
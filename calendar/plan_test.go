@@ -0,0 +1,155 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// planActions describes each entry of a plan as "action name (day)"
+func planActions(plan *Plan) []string {
+	var got []string
+	for _, e := range plan.Entries {
+		var start time.Time
+		if e.Event != nil {
+			start = e.Event.StartTime
+		} else {
+			start = e.Remote.Event.StartTime
+		}
+		got = append(got, fmt.Sprintf("%s %s (%s)", e.Action, e.Name(), start.Format("Jan 2")))
+	}
+	return got
+}
+
+// studyEvents returns events named Study on the given days of March 2027
+func studyEvents(days ...int) []models.CalendarEvent {
+	events := make([]models.CalendarEvent, len(days))
+	for i, day := range days {
+		start := time.Date(2027, 3, day, 18, 0, 0, 0, time.UTC)
+		events[i] = models.CalendarEvent{Name: "Study", StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	}
+	return events
+}
+
+func checkPlan(t *testing.T, plan *Plan, want ...string) {
+	t.Helper()
+	if got := planActions(plan); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got plan\n  %v\nwant\n  %v", got, want)
+	}
+}
+
+func TestPlanDescriptionEdit(t *testing.T) {
+	ctx := context.Background()
+	client, svc, _ := testClient(t)
+	events := testEvents(1)
+	events[0].Description = "Bring the slides"
+	if _, err := client.UpsertEvents(ctx, events, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	events[0].Description = "Bring the handouts"
+	plan, err := client.Plan(ctx, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan, "update Event 1 (Mar 1)")
+
+	if _, err := client.ApplyPlan(ctx, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(svc.Events("primary")); n != 1 {
+		t.Errorf("calendar has %d events, want 1", n)
+	}
+}
+
+func TestPlanLegacyKey(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+	event := testEvents(1)[0]
+	event.Description = "Bring the slides"
+
+	// An event created when the key still included the description
+	props := client.properties(&event)
+	props[propertyKey] = event.LegacyStableKey()
+	if _, err := client.Provider().CreateEvent(ctx, "primary", &event, props); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := client.Plan(ctx, []models.CalendarEvent{event})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan, "update Event 1 (Mar 1)")
+	if len(plan.Entries[0].Changes) != 0 {
+		t.Errorf("got changes %+v, want only the key updated", plan.Entries[0].Changes)
+	}
+
+	if _, err := client.ApplyPlan(ctx, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = client.Plan(ctx, []models.CalendarEvent{event})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan, "noop Event 1 (Mar 1)")
+}
+
+func TestPlanRepeatedNames(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+	if _, err := client.UpsertEvents(ctx, studyEvents(1, 2, 3), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving one of several same-named events creates it and orphans the old
+	// one, rather than rewriting whichever event has the same name
+	plan, err := client.Plan(ctx, studyEvents(1, 3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan,
+		"noop Study (Mar 1)",
+		"noop Study (Mar 3)",
+		"create Study (Mar 4)",
+		"orphan Study (Mar 2)",
+	)
+}
+
+func TestPlanReplace(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+	if _, err := client.UpsertEvents(ctx, studyEvents(1, 2), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := studyEvents(1, 2)
+	renamed[1].Name = "Study group"
+	plan, err := client.Plan(ctx, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan, "noop Study (Mar 1)", "replace Study group (Mar 2)")
+	if got := plan.Entries[1].Changes; len(got) != 1 || got[0].Field != "name" {
+		t.Errorf("got changes %+v, want the name", got)
+	}
+
+	plan.Unpair()
+	checkPlan(t, plan, "noop Study (Mar 1)", "create Study group (Mar 2)", "orphan Study (Mar 2)")
+
+	// Two new events at the start of one orphan are not paired with it
+	renamed = append(renamed, renamed[1])
+	renamed[2].Name = "Reading"
+	plan, err = client.Plan(ctx, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, plan,
+		"noop Study (Mar 1)",
+		"create Study group (Mar 2)",
+		"create Reading (Mar 2)",
+		"orphan Study (Mar 2)",
+	)
+}
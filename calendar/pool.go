@@ -0,0 +1,139 @@
+package calendar
+
+import (
+	"context"
+	"sync"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// writeOp is a single change to make in the calendar. Ops without a method
+// need no call and are only reported, as unchanged.
+type writeOp struct {
+	method  BatchMethod
+	eventID string
+	event   *models.CalendarEvent
+	remote  *RemoteEvent // Existing event, if any
+}
+
+// applyAll runs ops on the client's worker pool, grouping them into batch
+// requests when the provider supports it. The callback is called on the
+// caller's goroutine in op order, whatever order the ops finish in. Once ctx is
+// done, ops that have not started are not run and are reported as failed.
+func (c *Client) applyAll(ctx context.Context, ops []writeOp, callback func(int, int, *EventResult)) []*EventResult {
+	n := len(ops)
+	size := 1
+	batcher, ok := c.provider.(Batcher)
+	if ok && c.batchSize > 1 {
+		size = min(c.batchSize, MaxBatchSize)
+	}
+
+	results := make([]*EventResult, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// Each job is the first index of a chunk of at most size ops
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(c.workers, (n+size-1)/size)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lo := range jobs {
+				hi := min(lo+size, n)
+				switch {
+				case ctx.Err() != nil:
+					for i := lo; i < hi; i++ {
+						results[i] = c.skip(ops[i], ctx.Err())
+					}
+				case size > 1:
+					copy(results[lo:hi], c.applyBatch(ctx, batcher, ops[lo:hi]))
+				default:
+					results[lo] = c.apply(ctx, ops[lo])
+				}
+				for i := lo; i < hi; i++ {
+					close(done[i])
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for lo := 0; lo < n; lo += size {
+			jobs <- lo
+		}
+		close(jobs)
+	}()
+
+	for i := range n {
+		<-done[i]
+		if callback != nil {
+			callback(i+1, n, results[i])
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+// apply runs a single op
+func (c *Client) apply(ctx context.Context, op writeOp) *EventResult {
+	var err error
+	switch op.method {
+	case BatchCreate:
+		result, _ := c.CreateEvent(ctx, op.event)
+		return result
+	case BatchUpdate:
+		result, _ := c.updateEvent(ctx, op.eventID, op.event, op.remote)
+		return result
+	case BatchDelete:
+		err = c.DeleteEvent(ctx, op.eventID)
+	case BatchCancel:
+		err = c.CancelEvent(ctx, op.eventID)
+	}
+	return c.opResult(op, op.remote, err)
+}
+
+// skip reports an op that was not run because of err
+func (c *Client) skip(op writeOp, err error) *EventResult {
+	if op.method == "" {
+		return c.opResult(op, op.remote, nil)
+	}
+	return &EventResult{Event: op.event, Remote: op.remote, Error: err}
+}
+
+// opResult builds the result of an op that returned remote and err
+func (c *Client) opResult(op writeOp, remote *RemoteEvent, err error) *EventResult {
+	result := &EventResult{
+		Event:   op.event,
+		Remote:  remote,
+		Success: err == nil,
+		Error:   err,
+	}
+
+	switch op.method {
+	case BatchCreate:
+		result.Action = ActionCreated
+	case BatchUpdate:
+		result.Action = ActionUpdated
+	case BatchDelete:
+		result.Action = ActionDeleted
+	case BatchCancel:
+		result.Action = ActionCancelled
+	default:
+		result.Action = ActionUnchanged
+	}
+
+	if remote == nil {
+		result.Remote = op.remote
+	}
+	if result.Remote != nil {
+		result.Link = result.Remote.Link
+		if result.Event == nil {
+			result.Event = &result.Remote.Event
+		}
+	}
+	return result
+}
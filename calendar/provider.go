@@ -0,0 +1,135 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/monil/calendar-event-generator/config"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// Supported calendar backends
+const (
+	BackendGoogle = "google"
+	BackendCalDAV = "caldav"
+	BackendICSDir = "ics-dir"
+)
+
+// ErrNotFound is returned by providers when an event does not exist
+var ErrNotFound = errors.New("event not found")
+
+// Provider is a calendar backend that events can be read from and written to.
+// Calendar and event IDs are backend specific; the calendar ID "primary"
+// always selects the backend's default calendar.
+type Provider interface {
+	ListCalendars(ctx context.Context) ([]CalendarInfo, error)
+	ListEvents(ctx context.Context, calendarID string, opts ListOptions) ([]*RemoteEvent, error)
+	CreateEvent(ctx context.Context, calendarID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error)
+	UpdateEvent(ctx context.Context, calendarID, eventID string, event *models.CalendarEvent, props map[string]string) (*RemoteEvent, error)
+	DeleteEvent(ctx context.Context, calendarID, eventID string) error
+}
+
+// Canceller is implemented by providers that can cancel an event and notify
+// its attendees rather than deleting it outright
+type Canceller interface {
+	CancelEvent(ctx context.Context, calendarID, eventID string) error
+}
+
+// Overrider is implemented by providers that store the overrides of a recurring
+// event as exception instances, written one call at a time once the event exists
+type Overrider interface {
+	// OccurrenceID returns the ID of the occurrence of a recurring event that
+	// starts at start, or "" if the event has none
+	OccurrenceID(ctx context.Context, calendarID, eventID string, start time.Time) (string, error)
+	// OverrideOccurrence writes an override of event onto one of its occurrences
+	OverrideOccurrence(ctx context.Context, calendarID, occurrenceID string, event *models.CalendarEvent, override models.Override) error
+	// RestoreOccurrence puts an overridden occurrence, originally starting at
+	// originalStart, back to the time, location and description of event
+	RestoreOccurrence(ctx context.Context, calendarID, occurrenceID string, event *models.CalendarEvent, originalStart time.Time) error
+}
+
+// CalendarInfo describes a calendar available in a backend
+type CalendarInfo struct {
+	ID      string
+	Summary string
+	Primary bool
+}
+
+// RemoteEvent is an event as stored in a calendar backend
+type RemoteEvent struct {
+	ID         string
+	Link       string
+	Event      models.CalendarEvent
+	Properties map[string]string // Private properties written by this tool
+}
+
+// ListOptions narrows the events returned by ListEvents
+type ListOptions struct {
+	TimeMin    time.Time         // Zero means unbounded
+	TimeMax    time.Time         // Zero means unbounded
+	Properties map[string]string // Only events with all of these private properties
+}
+
+// matches reports whether an event falls at least partly within the window and
+// carries the requested properties. Recurring events are matched on their first
+// occurrence only.
+func (o ListOptions) matches(e *RemoteEvent) bool {
+	if !o.TimeMax.IsZero() && !e.Event.StartTime.Before(o.TimeMax) {
+		return false
+	}
+	if !o.TimeMin.IsZero() && e.Event.Recurrence == nil && !e.Event.EndTime.After(o.TimeMin) {
+		return false
+	}
+	for name, value := range o.Properties {
+		if e.Properties[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Open creates a Client for the backend selected in cfg
+func Open(ctx context.Context, cfg *config.Config, calendarID string) (*Client, error) {
+	var provider Provider
+
+	switch cfg.Backend {
+	case "", BackendGoogle:
+		var p *GoogleProvider
+		var err error
+		if cfg.Endpoint == "" {
+			p, err = NewGoogleProvider(ctx, cfg.CredentialsPath, cfg.TokenPath)
+		} else {
+			p, err = NewGoogleProviderWithEndpoint(ctx, cfg.Endpoint)
+		}
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	case BackendCalDAV:
+		p, err := NewCalDAVProvider(cfg.CalDAVURL, cfg.CalDAVUsername, cfg.CalDAVPassword)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	case BackendICSDir:
+		if cfg.ICSDir == "" {
+			return nil, fmt.Errorf("the %s backend requires --ics-dir", BackendICSDir)
+		}
+		provider = NewICSDirProvider(cfg.ICSDir)
+	default:
+		return nil, fmt.Errorf("unknown calendar backend: %s", cfg.Backend)
+	}
+
+	client := NewClientWithProvider(provider, calendarID)
+	client.SetConcurrency(cfg.Workers)
+	client.SetBatchSize(cfg.BatchSize)
+	client.SetRateLimit(cfg.QPS)
+
+	retry := DefaultRetryPolicy
+	retry.MaxRetries = cfg.MaxRetries
+	client.SetRetryPolicy(retry)
+
+	return client, nil
+}
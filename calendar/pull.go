@@ -0,0 +1,69 @@
+package calendar
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// Pull returns the events of the calendar within the window of opts as
+// template events, with their times shown in loc and sorted by start. When a
+// source is set, only events owned by it are returned.
+// Events whose stable key cannot be recomputed from their content, such as
+// events this tool did not create, get their calendar event ID as template ID
+// so that adding the template again updates them instead of duplicating them.
+func (c *Client) Pull(ctx context.Context, opts ListOptions, loc *time.Location) ([]models.CalendarEvent, error) {
+	if c.source != "" {
+		opts.Properties = map[string]string{propertySource: c.source}
+	}
+
+	remote, err := c.ListEvents(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.CalendarEvent, 0, len(remote))
+	for _, r := range remote {
+		event := r.Event
+		localizeEvent(&event, loc)
+		if key := r.Properties[propertyKey]; key != event.StableKey() && key != event.LegacyStableKey() {
+			event.ID = r.ID
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	return events, nil
+}
+
+// localizeEvent moves the times of an event into loc. All-day events keep
+// their dates.
+func localizeEvent(e *models.CalendarEvent, loc *time.Location) {
+	move := func(t time.Time) time.Time {
+		if e.AllDay {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		return t.In(loc)
+	}
+
+	e.StartTime = move(e.StartTime)
+	e.EndTime = move(e.EndTime)
+	if x := e.Exceptions; x != nil {
+		for i := range x.ExcludeDates {
+			x.ExcludeDates[i] = move(x.ExcludeDates[i])
+		}
+		for i := range x.ExtraDates {
+			x.ExtraDates[i] = move(x.ExtraDates[i])
+		}
+		for i := range x.Overrides {
+			o := &x.Overrides[i]
+			o.OriginalStart = move(o.OriginalStart)
+			o.StartTime = move(o.StartTime)
+			o.EndTime = move(o.EndTime)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+func TestPull(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+
+	// More events than fit in a page of results, written out of order
+	events := testEvents(260)
+	events[0], events[259] = events[259], events[0]
+	events[1].Recurrence = &models.RecurrenceRule{Frequency: "DAILY", Interval: 1, Count: 3}
+	moved := events[1].StartTime.AddDate(0, 0, 1)
+	events[1].Exceptions = &models.Exceptions{
+		ExcludeDates: []time.Time{moved.AddDate(0, 0, 1)},
+		Overrides: []models.Override{{
+			OriginalStart: moved,
+			StartTime:     moved.Add(2 * time.Hour),
+			EndTime:       moved.Add(3 * time.Hour),
+			Location:      "Room 9",
+		}},
+	}
+	if _, err := client.UpsertEvents(ctx, events, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// An event another tool wrote, and one from another template
+	if _, err := client.Provider().CreateEvent(ctx, "primary", &models.CalendarEvent{
+		Name:      "Dentist",
+		StartTime: events[3].StartTime,
+		EndTime:   events[3].EndTime,
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	other := NewClientWithProvider(client.Provider(), "primary")
+	other.SetRateLimit(0)
+	other.SetSource("/templates/other.yaml")
+	if _, err := other.UpsertEvents(ctx, []models.CalendarEvent{{Name: "Other", StartTime: events[4].StartTime, EndTime: events[4].EndTime}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	pulled, err := client.Pull(ctx, ListOptions{}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pulled) != len(events) {
+		t.Fatalf("got %d events, want the %d of the template", len(pulled), len(events))
+	}
+	for i, e := range pulled {
+		if i > 0 && e.StartTime.Before(pulled[i-1].StartTime) {
+			t.Errorf("%s at %s pulled after %s", e.Name, e.StartTime, pulled[i-1].StartTime)
+		}
+		if e.ID != "" {
+			t.Errorf("%s: got ID %q, want none for an event of the template", e.Name, e.ID)
+		}
+	}
+	series := pulled[1]
+	if x := series.Exceptions; series.Recurrence == nil || x == nil || len(x.ExcludeDates) != 1 || len(x.Overrides) != 1 ||
+		x.Overrides[0].Location != "Room 9" || !x.Overrides[0].StartTime.Equal(moved.Add(2*time.Hour)) {
+		t.Errorf("got %+v with exceptions %+v, want the series and its exceptions", series, series.Exceptions)
+	}
+
+	// Events read back as written, so pushing them again changes nothing
+	plan, err := client.Plan(ctx, pulled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := plan.Count(PlanNoop); n != len(events) || len(plan.Entries) != len(events) {
+		t.Errorf("got %d entries with %d unchanged, want %d unchanged", len(plan.Entries), n, len(events))
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := client.Pull(ctx, ListOptions{}, newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range local {
+		if e.StartTime.Location() != newYork || !e.StartTime.Equal(pulled[i].StartTime) {
+			t.Errorf("%s: got %s, want %s in America/New_York", e.Name, e.StartTime, pulled[i].StartTime)
+		}
+	}
+
+	// Without a source every event in the window is pulled, and events the
+	// tool did not write get their calendar ID so that pushing them updates them
+	client.SetSource("")
+	pulled, err = client.Pull(ctx, ListOptions{TimeMin: events[3].StartTime, TimeMax: events[5].StartTime}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range pulled {
+		names = append(names, e.Name)
+	}
+	// The series started before the window but still has occurrences in it
+	if got, want := fmt.Sprint(names), "[Event 2 Event 4 Dentist Event 5 Other]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if dentist := pulled[2]; dentist.ID == "" {
+		t.Error("event the tool did not write has no ID")
+	}
+
+	plan, err = client.Plan(ctx, pulled[2:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := plan.Count(PlanCreate); n != 0 {
+		t.Errorf("got %d creates, want the Dentist event matched by its ID", n)
+	}
+}
+
+func TestPullAllDay(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := testClient(t)
+	day := models.CalendarEvent{
+		Name:      "Offsite",
+		AllDay:    true,
+		StartTime: time.Date(2027, 3, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2027, 3, 12, 0, 0, 0, 0, time.UTC),
+	}
+	if _, err := client.UpsertEvents(ctx, []models.CalendarEvent{day}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// All-day events keep their dates in any timezone
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := client.Pull(ctx, ListOptions{}, tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pulled) != 1 {
+		t.Fatalf("got %d events, want 1", len(pulled))
+	}
+	if got := pulled[0]; !got.AllDay || got.StartTime.Format("2006-01-02") != "2027-03-10" || got.EndTime.Format("2006-01-02") != "2027-03-12" {
+		t.Errorf("got %s to %s, want Mar 10 to 12", got.StartTime, got.EndTime)
+	}
+}
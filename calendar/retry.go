@@ -0,0 +1,124 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy controls how failed backend calls are retried
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt; 0 disables retrying
+	BaseDelay  time.Duration // Delay before the first retry, doubled on each retry
+	MaxDelay   time.Duration // Upper bound for a single delay
+}
+
+// DefaultRetryPolicy retries up to 5 times over roughly half a minute
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+// call runs fn once the rate limiter allows it, retrying retryable errors with
+// exponential backoff and jitter
+func (c *Client) call(ctx context.Context, fn func() error) error {
+	return c.callRetrying(ctx, isRetryable, fn)
+}
+
+// callRetrying is call, retrying only the errors for which retryable is true
+func (c *Client) callRetrying(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil || attempt >= c.retry.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(c.retry.delay(attempt, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// delay returns how long to wait before the given retry. The server's
+// Retry-After is honoured; otherwise the exponential delay is jittered between
+// half and all of its value so concurrent workers do not retry in lockstep.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		if seconds, convErr := strconv.Atoi(gErr.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+			return min(time.Duration(seconds)*time.Second, p.MaxDelay)
+		}
+	}
+
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// isRetryable reports whether a failed call may succeed when repeated: rate
+// limiting, server errors and network timeouts
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if isRateLimited(err) {
+		return true
+	}
+
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code != http.StatusForbidden && retryableStatus(gErr.Code)
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return retryableStatus(httpErr.StatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isRateLimited reports whether a call was refused for exceeding a quota,
+// which means it was not carried out
+func isRateLimited(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		if gErr.Code == http.StatusTooManyRequests {
+			return true
+		}
+		if gErr.Code == http.StatusForbidden {
+			for _, item := range gErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,128 @@
+package calendar
+
+import (
+	"context"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// UpsertEvents creates or updates events so that re-running the same template
+// does not duplicate them. Existing events are matched on their stable key:
+// identical ones are left alone, changed ones are patched and new ones inserted.
+func (c *Client) UpsertEvents(ctx context.Context, events []models.CalendarEvent, callback func(int, int, *EventResult)) ([]*EventResult, error) {
+	plan, err := c.Plan(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ApplyPlan(ctx, plan, callback)
+}
+
+// ApplyPlan creates and updates the template events of a plan concurrently,
+// reporting progress in plan order. Orphaned entries are ignored; removing them
+// is left to the caller. If ctx is cancelled, entries not yet started are
+// reported as failed and ctx.Err() is returned with the results.
+func (c *Client) ApplyPlan(ctx context.Context, plan *Plan, callback func(int, int, *EventResult)) ([]*EventResult, error) {
+	var entries []*PlanEntry
+	for _, e := range plan.Entries {
+		if e.Event != nil {
+			entries = append(entries, e)
+		}
+	}
+
+	ops := make([]writeOp, len(entries))
+	for i, entry := range entries {
+		ops[i] = writeOp{event: entry.Event, remote: entry.Remote}
+		switch entry.Action {
+		case PlanCreate:
+			ops[i].method = BatchCreate
+		case PlanUpdate, PlanReplace:
+			ops[i].method = BatchUpdate
+			ops[i].eventID = entry.Remote.ID
+		}
+	}
+
+	return c.applyAll(ctx, ops, callback), ctx.Err()
+}
+
+// RemoveOrphans deletes, or cancels when cancel is set, the orphaned entries of a plan
+func (c *Client) RemoveOrphans(ctx context.Context, plan *Plan, cancel bool, callback func(int, int, *EventResult)) []*EventResult {
+	var orphans []*PlanEntry
+	for _, e := range plan.Entries {
+		if e.Action == PlanOrphan {
+			orphans = append(orphans, e)
+		}
+	}
+
+	method := BatchDelete
+	if cancel {
+		method = BatchCancel
+	}
+
+	ops := make([]writeOp, len(orphans))
+	for i, entry := range orphans {
+		ops[i] = writeOp{method: method, eventID: entry.Remote.ID, remote: entry.Remote}
+	}
+
+	return c.applyAll(ctx, ops, callback)
+}
+
+// findManagedEvents returns the events previously created by this tool within
+// the time window covered by events, plus every event owned by the client's
+// source wherever it is in the calendar, indexed by their stable key. Every
+// event in the window is also indexed by the key of a template event whose ID
+// is the calendar event ID, so templates written by Pull match their events.
+func (c *Client) findManagedEvents(ctx context.Context, events []models.CalendarEvent) (map[string]*RemoteEvent, error) {
+	managed := make(map[string]*RemoteEvent)
+
+	var queries []ListOptions
+	if len(events) > 0 {
+		queries = append(queries, eventWindow(events))
+	}
+	if c.source != "" {
+		queries = append(queries, ListOptions{Properties: map[string]string{propertySource: c.source}})
+	}
+
+	for _, opts := range queries {
+		remote, err := c.ListEvents(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range remote {
+			if key := e.Properties[propertyKey]; key != "" {
+				managed[key] = e
+			}
+			if key := remoteKey(e); managed[key] == nil {
+				managed[key] = e
+			}
+		}
+	}
+
+	return managed, nil
+}
+
+// remoteKey returns the stable key of a template event whose ID is the ID of e
+func remoteKey(e *RemoteEvent) string {
+	return (&models.CalendarEvent{ID: e.ID}).StableKey()
+}
+
+// eventWindow returns list options spanning every event in the slice
+func eventWindow(events []models.CalendarEvent) ListOptions {
+	opts := ListOptions{
+		TimeMin: events[0].StartTime,
+		TimeMax: events[0].EndTime,
+	}
+	for _, e := range events[1:] {
+		if e.StartTime.Before(opts.TimeMin) {
+			opts.TimeMin = e.StartTime
+		}
+		if e.EndTime.After(opts.TimeMax) {
+			opts.TimeMax = e.EndTime
+		}
+	}
+	// TimeMin/TimeMax are exclusive bounds, so widen by a day to be safe
+	opts.TimeMin = opts.TimeMin.AddDate(0, 0, -1)
+	opts.TimeMax = opts.TimeMax.AddDate(0, 0, 1)
+	return opts
+}
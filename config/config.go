@@ -7,10 +7,22 @@ import (
 
 // Config holds application configuration
 type Config struct {
+	Backend         string // google, caldav or ics-dir
 	CredentialsPath string
 	TokenPath       string
+	JournalDir      string
+	Endpoint        string // Calendar API base URL, skips OAuth when set (google backend)
+	CalDAVURL       string
+	CalDAVUsername  string
+	CalDAVPassword  string
+	ICSDir          string
 	CalendarID      string
+	Workers         int     // Events written concurrently
+	BatchSize       int     // Writes grouped into one request where supported, 0 to disable
+	QPS             float64 // Calls per second to the calendar backend, 0 for no limit
+	MaxRetries      int     // Retries of calls failing with rate limit or server errors
 	Timezone        string
+	LintRules       map[string]bool // Lint rules switched on or off by name, over their defaults
 	DryRun          bool
 	Verbose         bool
 }
@@ -18,9 +30,16 @@ type Config struct {
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Backend:         "google",
 		CredentialsPath: "credentials.json",
 		TokenPath:       getDefaultTokenPath(),
+		JournalDir:      getDefaultJournalDir(),
+		CalDAVPassword:  os.Getenv("CALDAV_PASSWORD"),
 		CalendarID:      "primary",
+		Workers:         4,
+		BatchSize:       50,
+		QPS:             5,
+		MaxRetries:      5,
 		Timezone:        "local",
 		DryRun:          false,
 		Verbose:         false,
@@ -40,6 +59,16 @@ func getDefaultTokenPath() string {
 	return filepath.Join(tokenDir, "token.json")
 }
 
+// getDefaultJournalDir returns platform-appropriate storage for the run journal
+func getDefaultJournalDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "runs"
+	}
+
+	return filepath.Join(configDir, "calendar-event-generator", "runs")
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	// Check if credentials file exists (only if not dry-run)
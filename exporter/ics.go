@@ -1,10 +1,10 @@
 package exporter
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,65 +14,380 @@ import (
 
 // GenerateICS converts a list of CalendarEvents to an iCalendar file content
 func GenerateICS(events []models.CalendarEvent, w io.Writer) error {
+	cal := NewCalendar(ical.MethodRequest)
+
+	for _, e := range events {
+		AddEvent(cal, e, generateUID(e), nil)
+	}
+
+	return cal.SerializeTo(w)
+}
+
+// NewCalendar creates an empty iCalendar with this tool's product ID. Pass an
+// empty method for calendar objects that are stored rather than sent.
+func NewCalendar(method ical.Method) *ical.Calendar {
 	cal := ical.NewCalendar()
-	cal.SetMethod(ical.MethodRequest)
+	if method != "" {
+		cal.SetMethod(method)
+	}
 	cal.SetProductId("-//Monil//Calendar Event Generator//EN")
 	cal.SetVersion("2.0")
+	return cal
+}
 
-	for _, e := range events {
-		event := cal.AddEvent(generateUID(e))
-		event.SetSummary(e.Name)
-		
-		if e.Description != "" {
-			desc := e.FormatDescription()
-			event.SetDescription(desc)
-		}
-
-		if e.Location != "" {
-			event.SetLocation(e.Location)
-		}
-
-		event.SetDtStampTime(time.Now())
-
-		if e.AllDay {
-			// All day events require standard date format (YYYYMMDD)
-			event.SetProperty(ical.ComponentPropertyDtStart, e.StartTime.Format("20060102"), ical.WithValue("DATE"))
-			// End date for all day events is exclusive, so add 1 day if not set or same as start
-			endTime := e.EndTime
-			if endTime.IsZero() || endTime.Equal(e.StartTime) {
-				endTime = e.StartTime.AddDate(0, 0, 1)
-			} else {
-				// If end date is present, ensure it's treated as next day for exclusive end
-				endTime = endTime.AddDate(0, 0, 1)
+// AddEvent adds e to cal as a VEVENT with the given UID. Each entry of props is
+// written as an extra property, so names should use the "X-" prefix.
+func AddEvent(cal *ical.Calendar, e models.CalendarEvent, uid string, props map[string]string) *ical.VEvent {
+	event := cal.AddEvent(uid)
+	event.SetSummary(e.Name)
+
+	if e.Description != "" || len(e.Links) > 0 {
+		desc := e.FormatDescription()
+		event.SetDescription(desc)
+	}
+
+	if e.Location != "" {
+		event.SetLocation(e.Location)
+	}
+
+	event.SetDtStampTime(time.Now())
+
+	if e.AllDay {
+		// All day events require standard date format (YYYYMMDD)
+		event.SetProperty(ical.ComponentPropertyDtStart, e.StartTime.Format("20060102"), ical.WithValue("DATE"))
+		// End date for all day events is exclusive, so add 1 day if not set or same as start.
+		// Otherwise the template parser has already made it exclusive.
+		endTime := e.EndTime
+		if endTime.IsZero() || !endTime.After(e.StartTime) {
+			endTime = e.StartTime.AddDate(0, 0, 1)
+		}
+		event.SetProperty(ical.ComponentPropertyDtEnd, endTime.Format("20060102"), ical.WithValue("DATE"))
+	} else {
+		event.SetStartAt(e.StartTime)
+		if !e.EndTime.IsZero() {
+			event.SetEndAt(e.EndTime)
+		} else {
+			// Default 1 hour if no end time? Or just start?
+			// Let's default to start + 1h if missing
+			event.SetEndAt(e.StartTime.Add(time.Hour))
+		}
+	}
+
+	if e.Recurrence != nil {
+		rrule := e.Recurrence.ToRRuleString()
+		// Remove "RRULE:" prefix as library might add it or we set property directly
+		// golang-ical SetProperty takes value. RRuleString includes key.
+		// Let's assume we pass value.
+		val := strings.TrimPrefix(rrule, "RRULE:")
+		event.SetProperty(ical.ComponentPropertyRrule, val)
+	}
+
+	if x := e.Exceptions; x != nil {
+		for _, t := range x.ExcludeDates {
+			event.AddExdate(formatICSTime(t, e.AllDay), dateValue(e.AllDay)...)
+		}
+		for _, t := range x.ExtraDates {
+			event.AddRdate(formatICSTime(t, e.AllDay), dateValue(e.AllDay)...)
+		}
+	}
+
+	for _, r := range e.Reminders {
+		addAlarm(event, e, r)
+	}
+
+	for name, value := range props {
+		event.SetProperty(ical.ComponentProperty(name), value)
+	}
+
+	if e.Exceptions != nil {
+		for _, o := range e.Exceptions.Overrides {
+			addOverride(cal, e, uid, o)
+		}
+	}
+
+	return event
+}
+
+// addAlarm adds a VALARM for a reminder; email reminders use an EMAIL alarm,
+// everything else a DISPLAY alarm
+func addAlarm(event *ical.VEvent, e models.CalendarEvent, r models.Reminder) {
+	alarm := event.AddAlarm()
+	if r.Method == "email" {
+		alarm.SetProperty(ical.ComponentPropertyAction, string(ical.ActionEmail))
+		alarm.SetProperty(ical.ComponentPropertySummary, e.Name)
+	} else {
+		alarm.SetProperty(ical.ComponentPropertyAction, string(ical.ActionDisplay))
+	}
+	alarm.SetProperty(ical.ComponentPropertyDescription, e.Name)
+	alarm.SetProperty(ical.ComponentPropertyTrigger, fmt.Sprintf("-PT%dM", r.Minutes))
+}
+
+// addOverride adds a VEVENT with a RECURRENCE-ID replacing one occurrence of e
+func addOverride(cal *ical.Calendar, e models.CalendarEvent, uid string, o models.Override) {
+	event := cal.AddEvent(uid)
+	event.SetProperty(ical.ComponentPropertyRecurrenceId, formatICSTime(o.OriginalStart, e.AllDay), dateValue(e.AllDay)...)
+	event.SetSummary(e.Name)
+	event.SetDtStampTime(time.Now())
+
+	desc := e.FormatDescription()
+	if o.Description != "" {
+		desc = (&models.CalendarEvent{Description: o.Description, Links: e.Links}).FormatDescription()
+	}
+	if desc != "" {
+		event.SetDescription(desc)
+	}
+
+	location := e.Location
+	if o.Location != "" {
+		location = o.Location
+	}
+	if location != "" {
+		event.SetLocation(location)
+	}
+
+	event.SetProperty(ical.ComponentPropertyDtStart, formatICSTime(o.StartTime, e.AllDay), dateValue(e.AllDay)...)
+	event.SetProperty(ical.ComponentPropertyDtEnd, formatICSTime(o.EndTime, e.AllDay), dateValue(e.AllDay)...)
+}
+
+// formatICSTime formats an occurrence start as a UTC date-time, or a date for all-day events
+func formatICSTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format("20060102")
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// dateValue returns the VALUE=DATE parameter for all-day events
+func dateValue(allDay bool) []ical.PropertyParameter {
+	if allDay {
+		return []ical.PropertyParameter{ical.WithValue("DATE")}
+	}
+	return nil
+}
+
+// ParseEvent converts a VEVENT back into a CalendarEvent
+func ParseEvent(event *ical.VEvent) (models.CalendarEvent, error) {
+	var e models.CalendarEvent
+	var err error
+
+	e.Name = propertyValue(event, ical.ComponentPropertySummary)
+	e.Description, e.Links = models.ParseDescription(ical.FromText(propertyValue(event, ical.ComponentPropertyDescription)))
+	e.Location = ical.FromText(propertyValue(event, ical.ComponentPropertyLocation))
+
+	start := event.GetProperty(ical.ComponentPropertyDtStart)
+	if start == nil {
+		return e, fmt.Errorf("event '%s' has no start time", e.Name)
+	}
+
+	if value, ok := start.ICalParameters["VALUE"]; ok && len(value) > 0 && value[0] == "DATE" {
+		e.AllDay = true
+		if e.StartTime, err = event.GetAllDayStartAt(); err != nil {
+			return e, fmt.Errorf("failed to parse start date: %w", err)
+		}
+		if e.EndTime, err = event.GetAllDayEndAt(); err != nil {
+			if e.EndTime, err = endFromDuration(event, e.StartTime, 24*time.Hour); err != nil {
+				return e, err
+			}
+		}
+	} else {
+		if e.StartTime, err = event.GetStartAt(); err != nil {
+			return e, fmt.Errorf("failed to parse start time: %w", err)
+		}
+		if e.EndTime, err = event.GetEndAt(); err != nil {
+			if e.EndTime, err = endFromDuration(event, e.StartTime, time.Hour); err != nil {
+				return e, err
+			}
+		}
+	}
+
+	if rrule := propertyValue(event, ical.ComponentPropertyRrule); rrule != "" {
+		if e.Recurrence, err = models.ParseRRule(rrule); err != nil {
+			return e, err
+		}
+	}
+
+	exceptions := &models.Exceptions{}
+	if exceptions.ExcludeDates, err = parseDateList(event, ical.ComponentPropertyExdate); err != nil {
+		return e, err
+	}
+	if exceptions.ExtraDates, err = parseDateList(event, ical.ComponentPropertyRdate); err != nil {
+		return e, err
+	}
+	if len(exceptions.ExcludeDates)+len(exceptions.ExtraDates) > 0 {
+		e.Exceptions = exceptions
+	}
+
+	if e.Reminders, err = parseAlarms(event, e.StartTime); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// endFromDuration returns the end of an event without DTEND from its
+// DURATION, or start plus fallback when it has neither
+func endFromDuration(event *ical.VEvent, start time.Time, fallback time.Duration) (time.Time, error) {
+	duration := propertyValue(event, ical.ComponentPropertyDuration)
+	if duration == "" {
+		return start.Add(fallback), nil
+	}
+	d, err := parseICSDuration(duration)
+	if err != nil || d < 0 {
+		return start, fmt.Errorf("invalid DURATION %q", duration)
+	}
+
+	// Days of a duration are calendar days, which matter across DST changes
+	days := d / (24 * time.Hour)
+	return start.AddDate(0, 0, int(days)).Add(d - days*24*time.Hour), nil
+}
+
+// parseAlarms converts the VALARMs of an event into reminders. Alarms that
+// go off after the event starts cannot be expressed as reminders and are skipped.
+func parseAlarms(event *ical.VEvent, start time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	for _, alarm := range event.Alarms() {
+		trigger := alarm.GetProperty(ical.ComponentPropertyTrigger)
+		if trigger == nil {
+			continue
+		}
+
+		var before time.Duration
+		if value, ok := trigger.ICalParameters["VALUE"]; ok && len(value) > 0 && value[0] == "DATE-TIME" {
+			at, err := time.Parse("20060102T150405Z", trigger.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid alarm TRIGGER %q", trigger.Value)
 			}
-			event.SetProperty(ical.ComponentPropertyDtEnd, endTime.Format("20060102"), ical.WithValue("DATE"))
+			before = start.Sub(at)
 		} else {
-			event.SetStartAt(e.StartTime)
-			if !e.EndTime.IsZero() {
-				event.SetEndAt(e.EndTime)
-			} else {
-				// Default 1 hour if no end time? Or just start?
-				// Let's default to start + 1h if missing
-				event.SetEndAt(e.StartTime.Add(time.Hour))
+			offset, err := parseICSDuration(trigger.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid alarm TRIGGER %q", trigger.Value)
 			}
+			before = -offset
+		}
+		if before < 0 {
+			continue
 		}
 
-		if e.Recurrence != nil {
-			rrule := e.Recurrence.ToRRuleString()
-			// Remove "RRULE:" prefix as library might add it or we set property directly
-			// golang-ical SetProperty takes value. RRuleString includes key.
-			// Let's assume we pass value.
-			val := strings.TrimPrefix(rrule, "RRULE:")
-			event.SetProperty(ical.ComponentPropertyRrule, val)
+		method := "popup"
+		if action := alarm.GetProperty(ical.ComponentPropertyAction); action != nil && strings.EqualFold(action.Value, string(ical.ActionEmail)) {
+			method = "email"
 		}
+		reminders = append(reminders, models.Reminder{Method: method, Minutes: int(before.Minutes())})
 	}
+	return reminders, nil
+}
 
-	return cal.SerializeTo(w)
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICSDuration parses an RFC 5545 duration such as "-PT15M" or "-P1DT2H"
+func parseICSDuration(s string) (time.Duration, error) {
+	m := icsDuration.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] != "" {
+			n, err := strconv.Atoi(m[i+2])
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(n) * unit
+		}
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// ParseOverride converts a VEVENT with a RECURRENCE-ID into an Override of
+// its master event e. Fields equal to the master's are left empty.
+func ParseOverride(event *ical.VEvent, e models.CalendarEvent) (models.Override, error) {
+	var o models.Override
+
+	recurrenceID := event.GetProperty(ical.ComponentPropertyRecurrenceId)
+	if recurrenceID == nil {
+		return o, fmt.Errorf("event '%s' has no RECURRENCE-ID", e.Name)
+	}
+	original, err := parseICSTimes(recurrenceID)
+	if err != nil || len(original) != 1 {
+		return o, fmt.Errorf("invalid RECURRENCE-ID %q", recurrenceID.Value)
+	}
+
+	occurrence, err := ParseEvent(event)
+	if err != nil {
+		return o, err
+	}
+
+	o.OriginalStart = original[0]
+	o.StartTime = occurrence.StartTime
+	o.EndTime = occurrence.EndTime
+	if occurrence.Location != e.Location {
+		o.Location = occurrence.Location
+	}
+	if occurrence.Description != e.Description {
+		o.Description = occurrence.Description
+	}
+	return o, nil
+}
+
+// parseDateList reads every instance of a date list property such as EXDATE
+func parseDateList(event *ical.VEvent, property ical.ComponentProperty) ([]time.Time, error) {
+	var dates []time.Time
+	for _, p := range event.GetProperties(property) {
+		times, err := parseICSTimes(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", property, err)
+		}
+		dates = append(dates, times...)
+	}
+	return dates, nil
+}
+
+// parseICSTimes parses the comma separated date or date-time values of a
+// property, honouring its TZID parameter
+func parseICSTimes(p *ical.IANAProperty) ([]time.Time, error) {
+	loc := time.Local
+	if tzid, ok := p.ICalParameters["TZID"]; ok && len(tzid) == 1 {
+		var err error
+		if loc, err = time.LoadLocation(tzid[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	var times []time.Time
+	for _, value := range strings.Split(p.Value, ",") {
+		var t time.Time
+		var err error
+		switch {
+		case strings.HasSuffix(value, "Z"):
+			t, err = time.Parse("20060102T150405Z", value)
+		case strings.Contains(value, "T"):
+			t, err = time.ParseInLocation("20060102T150405", value, loc)
+		default:
+			t, err = time.ParseInLocation("20060102", value, loc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// propertyValue returns the value of a property, or "" when it is not set
+func propertyValue(event *ical.VEvent, property ical.ComponentProperty) string {
+	if p := event.GetProperty(property); p != nil {
+		return p.Value
+	}
+	return ""
 }
 
 func generateUID(e models.CalendarEvent) string {
-	// Simple deterministic UID based on content
-	data := fmt.Sprintf("%s-%s-%s", e.Name, e.StartTime.String(), e.Description)
-	hash := sha1.Sum([]byte(data))
-	return hex.EncodeToString(hash[:]) + "@calendar-generator"
+	// Deterministic UID shared with the Google Calendar event key
+	return e.StableKey() + "@calendar-generator"
 }
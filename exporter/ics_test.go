@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	ical "github.com/arran4/golang-ical"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// parseVEvents parses iCalendar data and returns its VEVENTs
+func parseVEvents(t *testing.T, data string) []*ical.VEvent {
+	t.Helper()
+	cal, err := ical.ParseCalendar(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal.Events()
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	start := time.Date(2027, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := []models.CalendarEvent{
+		{
+			Name:        "Lecture",
+			Description: "Week one",
+			Location:    "Hall A",
+			Links:       []string{"https://example.com/notes"},
+			StartTime:   start,
+			EndTime:     start.Add(90 * time.Minute),
+			Recurrence:  &models.RecurrenceRule{Frequency: "WEEKLY", Interval: 1, Count: 10, ByDay: []string{"MO", "WE"}},
+			Exceptions: &models.Exceptions{
+				ExcludeDates: []time.Time{start.AddDate(0, 0, 7)},
+				ExtraDates:   []time.Time{start.AddDate(0, 0, 4)},
+			},
+			Reminders: []models.Reminder{{Method: "popup", Minutes: 10}, {Method: "email", Minutes: 1440}},
+		},
+		{
+			Name:      "Offsite",
+			AllDay:    true,
+			StartTime: time.Date(2027, 3, 10, 0, 0, 0, 0, time.UTC),
+			EndTime:   time.Date(2027, 3, 13, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateICS(events, &buf); err != nil {
+		t.Fatal(err)
+	}
+	vevents := parseVEvents(t, buf.String())
+	if len(vevents) != len(events) {
+		t.Fatalf("got %d VEVENTs, want %d", len(vevents), len(events))
+	}
+
+	for i, vevent := range vevents {
+		got, err := ParseEvent(vevent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vevent.Id() != generateUID(events[i]) {
+			t.Errorf("got UID %s, want %s", vevent.Id(), generateUID(events[i]))
+		}
+
+		// All-day dates are read back in the local timezone
+		if got.AllDay {
+			for _, t := range []*time.Time{&got.StartTime, &got.EndTime} {
+				*t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			}
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(events[i])
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("got %s, want %s", gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestICSOverrideRoundTrip(t *testing.T) {
+	start := time.Date(2027, 3, 1, 9, 0, 0, 0, time.UTC)
+	event := models.CalendarEvent{
+		Name:       "Lecture",
+		Location:   "Hall A",
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Recurrence: &models.RecurrenceRule{Frequency: "DAILY", Count: 5},
+		Exceptions: &models.Exceptions{Overrides: []models.Override{{
+			OriginalStart: start.AddDate(0, 0, 2),
+			StartTime:     start.AddDate(0, 0, 2).Add(3 * time.Hour),
+			EndTime:       start.AddDate(0, 0, 2).Add(4 * time.Hour),
+			Location:      "Hall B",
+		}}},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateICS([]models.CalendarEvent{event}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	vevents := parseVEvents(t, buf.String())
+	if len(vevents) != 2 || vevents[0].Id() != vevents[1].Id() {
+		t.Fatalf("got %d VEVENTs, want the series and its override with one UID", len(vevents))
+	}
+
+	got, err := ParseOverride(vevents[1], event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := event.Exceptions.Overrides[0]; !got.OriginalStart.Equal(want.OriginalStart) || !got.StartTime.Equal(want.StartTime) ||
+		!got.EndTime.Equal(want.EndTime) || got.Location != want.Location || got.Description != "" {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseOverride(vevents[0], event); err == nil {
+		t.Error("event without RECURRENCE-ID: got no error")
+	}
+}
+
+func TestParseEventTZID(t *testing.T) {
+	vevents := parseVEvents(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:talk@example.com",
+		"SUMMARY:Keynote",
+		"DTSTART;TZID=America/New_York:20270313T090000",
+		"DURATION:PT45M",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"EXDATE;TZID=America/New_York:20270314T090000",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:EMAIL",
+		"TRIGGER;VALUE=DATE-TIME:20270313T130000Z",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=END:PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:expo@example.com",
+		"SUMMARY:Expo",
+		"DTSTART;VALUE=DATE:20270320",
+		"DURATION:P3D",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n"))
+
+	e, err := ParseEvent(vevents[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	start := time.Date(2027, 3, 13, 9, 0, 0, 0, newYork)
+	if !e.StartTime.Equal(start) || e.EndTime.Sub(e.StartTime) != 45*time.Minute {
+		t.Errorf("got %s to %s, want %s for 45m", e.StartTime, e.EndTime, start)
+	}
+	if e.Exceptions == nil || len(e.Exceptions.ExcludeDates) != 1 || !e.Exceptions.ExcludeDates[0].Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("got exceptions %+v, want Mar 14, after the clocks change, excluded", e.Exceptions)
+	}
+
+	// Alarms after the start cannot be reminders
+	want := []models.Reminder{{Method: "popup", Minutes: 15}, {Method: "email", Minutes: 60}}
+	if got := e.Reminders; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got reminders %v, want %v", got, want)
+	}
+
+	// DURATION stands in for DTEND, in days for all-day events
+	e, err = ParseEvent(vevents[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.AllDay || e.EndTime.Sub(e.StartTime) != 72*time.Hour {
+		t.Errorf("got %s to %s, want 3 days", e.StartTime, e.EndTime)
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"PT15M":     15 * time.Minute,
+		"-PT15M":    -15 * time.Minute,
+		"+P1DT2H":   26 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"PT1H30M5S": time.Hour + 30*time.Minute + 5*time.Second,
+	} {
+		if got, err := parseICSDuration(in); err != nil || got != want {
+			t.Errorf("%s: got %s, %v, want %s", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"P", "PT", "15M", "P1H", "-PT"} {
+		if _, err := parseICSDuration(in); err == nil {
+			t.Errorf("%s: got no error", in)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package holidays
+
+import "time"
+
+// rule computes the date of a holiday in a given year
+type rule struct {
+	name       string
+	date       func(year int) (time.Month, int)
+	since      int          // First year the holiday is observed, 0 for always
+	substitute substitution // Day off instead when the holiday falls on a weekend
+}
+
+// substitution says which day is taken off instead of a holiday that falls on
+// a weekend. The holiday itself is kept too.
+type substitution int
+
+const (
+	noSubstitute   substitution = iota
+	nearestWeekday              // Saturday's on the Friday before, Sunday's on the Monday after
+	nextWeekday                 // The next weekday that is not a holiday already
+)
+
+// bundled holds national public holidays, with the days observed instead of
+// those falling on a weekend where the country has them (US federal rules;
+// substitute days in GB, IE, CA and AU). Regional holidays are not included;
+// DE, FR and NL do not move holidays.
+var bundled = map[string][]rule{
+	"US": {
+		{name: "New Year's Day", date: fixed(time.January, 1), substitute: nearestWeekday},
+		{name: "Martin Luther King Jr. Day", date: nth(3, time.Monday, time.January)},
+		{name: "Presidents' Day", date: nth(3, time.Monday, time.February)},
+		{name: "Memorial Day", date: nth(-1, time.Monday, time.May)},
+		{name: "Juneteenth", date: fixed(time.June, 19), since: 2021, substitute: nearestWeekday},
+		{name: "Independence Day", date: fixed(time.July, 4), substitute: nearestWeekday},
+		{name: "Labor Day", date: nth(1, time.Monday, time.September)},
+		{name: "Columbus Day", date: nth(2, time.Monday, time.October)},
+		{name: "Veterans Day", date: fixed(time.November, 11), substitute: nearestWeekday},
+		{name: "Thanksgiving Day", date: nth(4, time.Thursday, time.November)},
+		{name: "Christmas Day", date: fixed(time.December, 25), substitute: nearestWeekday},
+	},
+	"CA": {
+		{name: "New Year's Day", date: fixed(time.January, 1), substitute: nextWeekday},
+		{name: "Good Friday", date: easter(-2)},
+		{name: "Victoria Day", date: onOrBefore(time.Monday, time.May, 24)},
+		{name: "Canada Day", date: fixed(time.July, 1), substitute: nextWeekday},
+		{name: "Labour Day", date: nth(1, time.Monday, time.September)},
+		{name: "Thanksgiving", date: nth(2, time.Monday, time.October)},
+		{name: "Christmas Day", date: fixed(time.December, 25), substitute: nextWeekday},
+		{name: "Boxing Day", date: fixed(time.December, 26), substitute: nextWeekday},
+	},
+	"GB": {
+		{name: "New Year's Day", date: fixed(time.January, 1), substitute: nextWeekday},
+		{name: "Good Friday", date: easter(-2)},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "Early May Bank Holiday", date: nth(1, time.Monday, time.May)},
+		{name: "Spring Bank Holiday", date: nth(-1, time.Monday, time.May)},
+		{name: "Summer Bank Holiday", date: nth(-1, time.Monday, time.August)},
+		{name: "Christmas Day", date: fixed(time.December, 25), substitute: nextWeekday},
+		{name: "Boxing Day", date: fixed(time.December, 26), substitute: nextWeekday},
+	},
+	"IE": {
+		{name: "New Year's Day", date: fixed(time.January, 1), substitute: nextWeekday},
+		{name: "St Patrick's Day", date: fixed(time.March, 17), substitute: nextWeekday},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "May Bank Holiday", date: nth(1, time.Monday, time.May)},
+		{name: "June Bank Holiday", date: nth(1, time.Monday, time.June)},
+		{name: "August Bank Holiday", date: nth(1, time.Monday, time.August)},
+		{name: "October Bank Holiday", date: nth(-1, time.Monday, time.October)},
+		{name: "Christmas Day", date: fixed(time.December, 25), substitute: nextWeekday},
+		{name: "St Stephen's Day", date: fixed(time.December, 26), substitute: nextWeekday},
+	},
+	"DE": {
+		{name: "New Year's Day", date: fixed(time.January, 1)},
+		{name: "Good Friday", date: easter(-2)},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "Labour Day", date: fixed(time.May, 1)},
+		{name: "Ascension Day", date: easter(39)},
+		{name: "Whit Monday", date: easter(50)},
+		{name: "German Unity Day", date: fixed(time.October, 3)},
+		{name: "Christmas Day", date: fixed(time.December, 25)},
+		{name: "Second Day of Christmas", date: fixed(time.December, 26)},
+	},
+	"FR": {
+		{name: "New Year's Day", date: fixed(time.January, 1)},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "Labour Day", date: fixed(time.May, 1)},
+		{name: "Victory in Europe Day", date: fixed(time.May, 8)},
+		{name: "Ascension Day", date: easter(39)},
+		{name: "Whit Monday", date: easter(50)},
+		{name: "Bastille Day", date: fixed(time.July, 14)},
+		{name: "Assumption Day", date: fixed(time.August, 15)},
+		{name: "All Saints' Day", date: fixed(time.November, 1)},
+		{name: "Armistice Day", date: fixed(time.November, 11)},
+		{name: "Christmas Day", date: fixed(time.December, 25)},
+	},
+	"NL": {
+		{name: "New Year's Day", date: fixed(time.January, 1)},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "King's Day", date: kingsDay, since: 2014},
+		{name: "Ascension Day", date: easter(39)},
+		{name: "Whit Monday", date: easter(50)},
+		{name: "Christmas Day", date: fixed(time.December, 25)},
+		{name: "Second Day of Christmas", date: fixed(time.December, 26)},
+	},
+	"AU": {
+		{name: "New Year's Day", date: fixed(time.January, 1), substitute: nextWeekday},
+		{name: "Australia Day", date: fixed(time.January, 26), substitute: nextWeekday},
+		{name: "Good Friday", date: easter(-2)},
+		{name: "Easter Monday", date: easter(1)},
+		{name: "Anzac Day", date: fixed(time.April, 25)},
+		{name: "King's Birthday", date: nth(2, time.Monday, time.June)},
+		{name: "Christmas Day", date: fixed(time.December, 25), substitute: nextWeekday},
+		{name: "Boxing Day", date: fixed(time.December, 26), substitute: nextWeekday},
+	},
+}
+
+func init() {
+	bundled["UK"] = bundled["GB"]
+}
+
+// fixed is the same date every year
+func fixed(month time.Month, day int) func(int) (time.Month, int) {
+	return func(int) (time.Month, int) { return month, day }
+}
+
+// nth is the nth weekday of a month, counted from the end when n is negative
+func nth(n int, weekday time.Weekday, month time.Month) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		if n > 0 {
+			first := time.Date(year, month, 1, 12, 0, 0, 0, time.UTC)
+			offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+			return month, 1 + offset + 7*(n-1)
+		}
+		last := time.Date(year, month+1, 0, 12, 0, 0, 0, time.UTC)
+		offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+		return month, last.Day() - offset + 7*(n+1)
+	}
+}
+
+// onOrBefore is the last given weekday on or before a date
+func onOrBefore(weekday time.Weekday, month time.Month, day int) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		t := time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+		t = t.AddDate(0, 0, -((int(t.Weekday()) - int(weekday) + 7) % 7))
+		return t.Month(), t.Day()
+	}
+}
+
+// easter is a number of days after Easter Sunday (Gregorian computus)
+func easter(offset int) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		a := year % 19
+		b, c := year/100, year%100
+		d, e := b/4, b%4
+		f := (b + 8) / 25
+		g := (b - f + 1) / 3
+		h := (19*a + b - d - g + 15) % 30
+		i, k := c/4, c%4
+		l := (32 + 2*e + 2*i - h - k) % 7
+		m := (a + 11*h + 22*l) / 451
+		month := (h + l - 7*m + 114) / 31
+		day := (h+l-7*m+114)%31 + 1
+
+		t := time.Date(year, time.Month(month), day+offset, 12, 0, 0, 0, time.UTC)
+		return t.Month(), t.Day()
+	}
+}
+
+// kingsDay is April 27th, or the 26th when the 27th is a Sunday
+func kingsDay(year int) (time.Month, int) {
+	if time.Date(year, time.April, 27, 12, 0, 0, 0, time.UTC).Weekday() == time.Sunday {
+		return time.April, 26
+	}
+	return time.April, 27
+}
@@ -0,0 +1,170 @@
+// Package holidays provides public holiday calendars, bundled for common
+// countries or read from .ics files, used to keep events off holidays.
+package holidays
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policy says what happens to an event that falls on a holiday
+type Policy string
+
+const (
+	PolicyWarn   Policy = "warn"   // Keep the event and report it
+	PolicySkip   Policy = "skip"   // Drop the event or occurrence
+	PolicyShift  Policy = "shift"  // Move it to the next working day
+	PolicyIgnore Policy = "ignore" // Keep the event silently
+)
+
+// ParsePolicy parses a policy name, defaulting to PolicyWarn when empty
+func ParsePolicy(s string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return PolicyWarn, nil
+	case PolicyWarn, PolicySkip, PolicyShift, PolicyIgnore:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid holiday policy %q (expected skip, shift, warn or ignore)", s)
+}
+
+// Calendar is a set of holidays
+type Calendar struct {
+	Name  string
+	rules []rule
+	dates map[string]string         // Fixed dates (YYYY-MM-DD) -> holiday name
+	years map[int]map[string]string // Dates generated from rules, per year
+}
+
+// Load returns the bundled calendar for a country code such as "US", or reads
+// ref as an .ics file
+func Load(ref string) (*Calendar, error) {
+	if rules, ok := bundled[strings.ToUpper(ref)]; ok && !strings.HasSuffix(strings.ToLower(ref), ".ics") {
+		return &Calendar{Name: strings.ToUpper(ref), rules: rules}, nil
+	}
+
+	if _, err := os.Stat(ref); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("unknown holiday calendar %q: not a bundled country (%s) or an existing .ics file",
+				ref, strings.Join(Bundled(), ", "))
+		}
+		return nil, err
+	}
+	return LoadICS(ref)
+}
+
+// Bundled returns the country codes of the bundled calendars
+func Bundled() []string {
+	codes := make([]string, 0, len(bundled))
+	for code := range bundled {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Lookup returns the name of the holiday on t's date, in t's location
+func (c *Calendar) Lookup(t time.Time) (string, bool) {
+	day := t.Format("2006-01-02")
+	if name, ok := c.dates[day]; ok {
+		return name, true
+	}
+	if len(c.rules) == 0 {
+		return "", false
+	}
+
+	if c.years == nil {
+		c.years = make(map[int]map[string]string)
+	}
+	dates, ok := c.years[t.Year()]
+	if !ok {
+		dates = c.generate(t.Year())
+		c.years[t.Year()] = dates
+	}
+
+	name, ok := dates[day]
+	return name, ok
+}
+
+// generate returns the holidays of a year from the rules, with the days
+// observed instead of those falling on a weekend. The years either side are
+// generated too, as a New Year's Day on a Saturday can be observed on the last
+// day of the year before.
+func (c *Calendar) generate(year int) map[string]string {
+	type holiday struct {
+		day  time.Time
+		rule rule
+	}
+	var all []holiday
+	taken := make(map[string]bool)
+	for y := year - 1; y <= year+1; y++ {
+		for _, r := range c.rules {
+			if y < r.since {
+				continue
+			}
+			month, d := r.date(y)
+			day := time.Date(y, month, d, 12, 0, 0, 0, time.UTC)
+			all = append(all, holiday{day: day, rule: r})
+			taken[day.Format("2006-01-02")] = true
+		}
+	}
+	// Substitute days go to the earlier holiday first, e.g. Christmas before Boxing Day
+	sort.Slice(all, func(i, j int) bool { return all[i].day.Before(all[j].day) })
+
+	dates := make(map[string]string)
+	add := func(day time.Time, name string) {
+		if day.Year() == year {
+			dates[day.Format("2006-01-02")] = name
+		}
+	}
+	for _, h := range all {
+		add(h.day, h.rule.name)
+		if !weekend(h.day) {
+			continue
+		}
+
+		switch h.rule.substitute {
+		case nearestWeekday:
+			observed := h.day.AddDate(0, 0, 1)
+			if h.day.Weekday() == time.Saturday {
+				observed = h.day.AddDate(0, 0, -1)
+			}
+			add(observed, h.rule.name+" (observed)")
+		case nextWeekday:
+			substitute := h.day.AddDate(0, 0, 1)
+			for weekend(substitute) || taken[substitute.Format("2006-01-02")] {
+				substitute = substitute.AddDate(0, 0, 1)
+			}
+			taken[substitute.Format("2006-01-02")] = true
+			add(substitute, h.rule.name+" (substitute day)")
+		}
+	}
+	return dates
+}
+
+// weekend reports whether t falls on a Saturday or Sunday
+func weekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// WorkingDay reports whether t falls on a weekday that is not a holiday
+func (c *Calendar) WorkingDay(t time.Time) bool {
+	if weekend(t) {
+		return false
+	}
+	_, holiday := c.Lookup(t)
+	return !holiday
+}
+
+// NextWorkingDay returns t moved to the first working day after it, keeping
+// its wall clock time
+func (c *Calendar) NextWorkingDay(t time.Time) time.Time {
+	next := t.AddDate(0, 0, 1)
+	for i := 0; i < 366 && !c.WorkingDay(next); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,177 @@
+package holidays
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t.Add(9 * time.Hour)
+}
+
+func TestBundled(t *testing.T) {
+	tests := []struct {
+		country string
+		date    string
+		want    string // Empty for a working day
+	}{
+		{"US", "2027-01-18", "Martin Luther King Jr. Day"},
+		{"US", "2027-05-31", "Memorial Day"},
+		{"US", "2027-11-25", "Thanksgiving Day"},
+		{"US", "2020-06-19", ""}, // Before Juneteenth was a federal holiday
+		{"US", "2026-06-19", "Juneteenth"},
+
+		// US holidays on a weekend are observed on the nearest weekday
+		{"US", "2027-07-04", "Independence Day"},
+		{"US", "2027-07-05", "Independence Day (observed)"},
+		{"US", "2027-12-25", "Christmas Day"},
+		{"US", "2027-12-24", "Christmas Day (observed)"},
+		{"US", "2027-12-31", "New Year's Day (observed)"}, // Jan 1, 2028 is a Saturday
+
+		// GB substitute days go to the next weekday that is not a holiday
+		{"GB", "2027-03-26", "Good Friday"},
+		{"GB", "2027-03-29", "Easter Monday"},
+		{"GB", "2027-12-27", "Christmas Day (substitute day)"},
+		{"GB", "2027-12-28", "Boxing Day (substitute day)"},
+		{"UK", "2027-08-30", "Summer Bank Holiday"},
+
+		{"DE", "2027-05-06", "Ascension Day"},
+		{"DE", "2027-05-17", "Whit Monday"},
+		{"DE", "2027-12-27", ""}, // Germany does not move holidays
+		{"CA", "2027-05-24", "Victoria Day"},
+		{"CA", "2026-05-18", "Victoria Day"},
+		{"NL", "2025-04-26", "King's Day"}, // Apr 27 is a Sunday
+		{"NL", "2027-04-27", "King's Day"},
+		{"FR", "2027-07-14", "Bastille Day"},
+		{"AU", "2027-01-26", "Australia Day"},
+		{"IE", "2027-03-17", "St Patrick's Day"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.country+" "+tt.date, func(t *testing.T) {
+			c, err := Load(tt.country)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := c.Lookup(date(tt.date))
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundledCodes(t *testing.T) {
+	if got, want := strings.Join(Bundled(), ","), "AU,CA,DE,FR,GB,IE,NL,UK,US"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if c, err := Load("us"); err != nil || c.Name != "US" {
+		t.Errorf("lower case code: got %v, %v", c, err)
+	}
+
+	_, err := Load("XX")
+	if err == nil || !strings.Contains(err.Error(), "not a bundled country") {
+		t.Errorf("unknown code: got %v, want a list of the bundled countries", err)
+	}
+}
+
+func TestWorkingDays(t *testing.T) {
+	c, err := Load("GB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for day, want := range map[string]bool{
+		"2027-12-23": true,
+		"2027-12-24": true,
+		"2027-12-25": false, // Saturday and Christmas
+		"2027-12-27": false, // Substitute day
+		"2027-12-29": true,
+	} {
+		if got := c.WorkingDay(date(day)); got != want {
+			t.Errorf("%s: got working day %v, want %v", day, got, want)
+		}
+	}
+
+	// Christmas Eve to the end of the substitute days keeps the time of day
+	next := c.NextWorkingDay(date("2027-12-24"))
+	if want := date("2027-12-29"); !next.Equal(want) {
+		t.Errorf("next working day after Dec 24 is %s, want %s", next, want)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for in, want := range map[string]Policy{
+		"":        PolicyWarn,
+		"skip":    PolicySkip,
+		" Shift ": PolicyShift,
+		"WARN":    PolicyWarn,
+		"ignore":  PolicyIgnore,
+	} {
+		if got, err := ParsePolicy(in); err != nil || got != want {
+			t.Errorf("%q: got %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("move"); err == nil {
+		t.Error("invalid policy: got no error")
+	}
+}
+
+func TestLoadICS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "school.ics")
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:founders@example.com",
+		"SUMMARY:Founders' Day",
+		"DTSTART;VALUE=DATE:20260312",
+		"DTEND;VALUE=DATE:20260313",
+		"RRULE:FREQ=YEARLY;COUNT=3",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:break@example.com",
+		"SUMMARY:Spring break",
+		"DTSTART;VALUE=DATE:20270405",
+		"DTEND;VALUE=DATE:20270408",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "school.ics" {
+		t.Errorf("got name %q, want school.ics", c.Name)
+	}
+
+	for day, want := range map[string]string{
+		"2026-03-12": "Founders' Day",
+		"2028-03-12": "Founders' Day",
+		"2029-03-12": "", // After COUNT
+		"2027-04-04": "",
+		"2027-04-05": "Spring break",
+		"2027-04-07": "Spring break",
+		"2027-04-08": "", // End dates are exclusive
+	} {
+		if got, _ := c.Lookup(date(day)); got != want {
+			t.Errorf("%s: got %q, want %q", day, got, want)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.ics")); err == nil {
+		t.Error("missing file: got no error")
+	}
+}
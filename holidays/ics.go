@@ -0,0 +1,48 @@
+package holidays
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+	"github.com/monil/calendar-event-generator/exporter"
+	"github.com/monil/calendar-event-generator/recurrence"
+)
+
+// LoadICS reads the holidays of an .ics file. Every day an event covers is a
+// holiday; yearly and other recurring events are expanded.
+func LoadICS(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open holiday calendar: %w", err)
+	}
+	defer f.Close()
+
+	cal, err := ical.ParseCalendar(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holiday calendar %s: %w", path, err)
+	}
+
+	c := &Calendar{Name: filepath.Base(path), dates: make(map[string]string)}
+	for _, vevent := range cal.Events() {
+		event, err := exporter.ParseEvent(vevent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday in %s: %w", path, err)
+		}
+
+		occurrences, err := recurrence.Expand(&event, time.Time{}, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday '%s' in %s: %w", event.Name, path, err)
+		}
+		for _, o := range occurrences {
+			// Mark each day the occurrence covers; end dates are exclusive
+			for day := o.Start; day.Before(o.End) || day.Equal(o.Start); day = day.AddDate(0, 0, 1) {
+				c.dates[day.Format("2006-01-02")] = o.Name
+			}
+		}
+	}
+
+	return c, nil
+}
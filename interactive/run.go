@@ -16,11 +16,14 @@ import (
 	"github.com/monil/calendar-event-generator/utils"
 )
 
-// Run starts the interactive CLI mode
-func Run(cfg *config.Config) error {
+// Run starts the interactive CLI mode. Events are added by calling add with
+// the template file and the --source name (empty for the default), so they are
+// checkpointed and recorded for undo as with the add command.
+func Run(cfg *config.Config, add func(inputFile, source string) error) error {
 	var action string
 	var selectedFile string
 	var calendarID string
+	var source string
 	var dryRun bool
 
 	// Styles (No emojis!)
@@ -95,6 +98,10 @@ func Run(cfg *config.Config) error {
 					Title("Calendar ID").
 					Description("Leave empty for 'primary'").
 					Value(&calendarID),
+				huh.NewInput().
+					Title("Source").
+					Description("Name identifying the events of this template. Leave empty for its file path").
+					Value(&source),
 				huh.NewConfirm().
 					Title("Dry Run?").
 					Description("Preview without creating events").
@@ -112,7 +119,7 @@ func Run(cfg *config.Config) error {
 		}
 		cfg.DryRun = dryRun
 
-		return runAdd(cfg, selectedFile)
+		return runAdd(cfg, selectedFile, source, add)
 	} else if action == "validate" {
 		return runValidate(cfg, selectedFile)
 	} else if action == "export" {
@@ -131,7 +138,11 @@ func getExampleFiles() ([]string, error) {
 	}
 
 	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".yaml", ".yml", ".toml", ".csv", ".ics":
 			files = append(files, filepath.Join("examples", e.Name()))
 		}
 	}
@@ -143,12 +154,12 @@ func getExampleFiles() ([]string, error) {
 
 func runListCalendars(cfg *config.Config) error {
 	ctx := context.Background()
-	client, err := calendar.NewClient(ctx, cfg.CredentialsPath, cfg.TokenPath, "primary")
+	client, err := calendar.Open(ctx, cfg, "primary")
 	if err != nil {
 		return fmt.Errorf("failed to create calendar client: %w", err)
 	}
 
-	calendars, err := client.ListCalendars()
+	calendars, err := client.ListCalendars(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list calendars: %w", err)
 	}
@@ -164,27 +175,28 @@ func runListCalendars(cfg *config.Config) error {
 	return nil
 }
 
-func runAdd(cfg *config.Config, inputFile string) error {
-	// Parse template
-	parser, err := templates.NewParser(cfg.Timezone)
-	if err != nil {
-		return fmt.Errorf("failed to create parser: %w", err)
-	}
-
-	// Auto-detect format
-	events, err := parser.ParseFile(inputFile, templates.FormatAuto)
-	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
-	}
+// runAdd adds the events of a template with add. On a dry run the events are
+// listed first and only added if the user confirms.
+func runAdd(cfg *config.Config, inputFile, source string, add func(inputFile, source string) error) error {
+	if cfg.DryRun {
+		parser, err := templates.NewParser(cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("failed to create parser: %w", err)
+		}
 
-	fmt.Printf("\nFound %d events in template\n", len(events))
+		// Auto-detect format
+		events, err := parser.ParseFile(inputFile, templates.FormatAuto)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+		printWarnings(parser)
 
-	if cfg.DryRun {
+		fmt.Printf("\nFound %d events in template\n", len(events))
 		fmt.Println("\n[DRY RUN] - No events will be created")
 		utils.PrintEventSummary(events, cfg.Verbose)
 
 		var confirm bool
-		err := huh.NewConfirm().
+		err = huh.NewConfirm().
 			Title("Do you want to proceed with adding these events?").
 			Value(&confirm).
 			WithTheme(huh.ThemeBase()).
@@ -197,34 +209,11 @@ func runAdd(cfg *config.Config, inputFile string) error {
 			return nil
 		}
 		// Proceed with adding events
+		cfg.DryRun = false
 		fmt.Println()
 	}
 
-	// Create calendar client
-	ctx := context.Background()
-	client, err := calendar.NewClient(ctx, cfg.CredentialsPath, cfg.TokenPath, cfg.CalendarID)
-	if err != nil {
-		return fmt.Errorf("failed to create calendar client: %w", err)
-	}
-
-	fmt.Printf("Adding events to calendar: %s\n\n", client.GetCalendarID())
-
-	// Create events with spinner/progress
-	// Huh doesn't have a progress bar yet, but we can just print simple logs
-	_, err = client.CreateEvents(events, func(current, total int, result *calendar.EventResult) {
-		if result.Success {
-			fmt.Printf("[OK] [%d/%d] %s\n", current, total, result.Event.Name)
-		} else {
-			fmt.Printf("[ERR] [%d/%d] %s: %v\n", current, total, result.Event.Name, result.Error)
-		}
-	})
-
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("\nDone!")
-	return nil
+	return add(inputFile, source)
 }
 
 func runValidate(cfg *config.Config, inputFile string) error {
@@ -237,6 +226,7 @@ func runValidate(cfg *config.Config, inputFile string) error {
 	if err != nil {
 		return fmt.Errorf("Validation failed: %w", err)
 	}
+	printWarnings(parser)
 
 	fmt.Println("Template is valid!")
 	fmt.Printf("Found %d events\n", len(events))
@@ -273,6 +263,7 @@ func runExport(cfg *config.Config, inputFile string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
+	printWarnings(parser)
 
 	f, err := os.Create(outputFile)
 	if err != nil {
@@ -287,3 +278,10 @@ func runExport(cfg *config.Config, inputFile string) error {
 	fmt.Printf("\nSuccessfully exported %d events to %s\n", len(events), outputFile)
 	return nil
 }
+
+// printWarnings reports problems the parser found in the template
+func printWarnings(parser *templates.Parser) {
+	for _, warning := range parser.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,156 @@
+package journal
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/monil/calendar-event-generator/calendar"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// ErrNoCheckpoint is returned when there is no unfinished import to resume
+var ErrNoCheckpoint = errors.New("no checkpoint found")
+
+// Checkpoint records the outcome of each event of a template applied to a
+// calendar, so an import that stopped part way can be resumed
+type Checkpoint struct {
+	CalendarID   string                      `json:"calendar_id"`
+	TemplatePath string                      `json:"template_path"`
+	UpdatedAt    time.Time                   `json:"updated_at"`
+	Events       map[string]*CheckpointEvent `json:"events"` // By stable key
+}
+
+// CheckpointEvent is the last outcome recorded for a template event
+type CheckpointEvent struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	EventID string `json:"event_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewCheckpoint creates an empty checkpoint for a template applied to a calendar
+func NewCheckpoint(calendarID, templatePath string) *Checkpoint {
+	if abs, err := filepath.Abs(templatePath); err == nil {
+		templatePath = abs
+	}
+
+	return &Checkpoint{
+		CalendarID:   calendarID,
+		TemplatePath: templatePath,
+		Events:       make(map[string]*CheckpointEvent),
+	}
+}
+
+// Record stores the outcome of a single event
+func (c *Checkpoint) Record(result *calendar.EventResult) {
+	entry := &CheckpointEvent{
+		Name:    result.Event.Name,
+		Success: result.Success,
+	}
+	if result.Remote != nil {
+		entry.EventID = result.Remote.ID
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+
+	c.Events[result.Event.StableKey()] = entry
+	c.UpdatedAt = time.Now()
+}
+
+// Succeeded reports whether an event was written successfully
+func (c *Checkpoint) Succeeded(event *models.CalendarEvent) bool {
+	entry, ok := c.Events[event.StableKey()]
+	return ok && entry.Success
+}
+
+// Failed reports whether an event was attempted and failed
+func (c *Checkpoint) Failed(event *models.CalendarEvent) bool {
+	entry, ok := c.Events[event.StableKey()]
+	return ok && !entry.Success
+}
+
+// Pending reports whether a resumed import still has to write an event: it
+// was not written successfully and, with failedOnly, it was attempted and failed
+func (c *Checkpoint) Pending(event *models.CalendarEvent, failedOnly bool) bool {
+	return !c.Succeeded(event) && (!failedOnly || c.Failed(event))
+}
+
+// Complete reports whether every recorded event succeeded
+func (c *Checkpoint) Complete() bool {
+	for _, entry := range c.Events {
+		if !entry.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveCheckpoint writes a checkpoint, replacing the previous one for the same
+// template and calendar. The file is replaced atomically so a crash while
+// saving leaves the previous checkpoint intact.
+func (j *Journal) SaveCheckpoint(cp *Checkpoint) error {
+	dir := filepath.Join(j.dir, "checkpoints")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create checkpoint directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := j.checkpointPath(cp.CalendarID, cp.TemplatePath)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads the checkpoint for a template applied to a calendar
+func (j *Journal) LoadCheckpoint(calendarID, templatePath string) (*Checkpoint, error) {
+	data, err := os.ReadFile(j.checkpointPath(calendarID, templatePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoCheckpoint
+		}
+		return nil, fmt.Errorf("unable to read checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("unable to parse checkpoint: %w", err)
+	}
+	if cp.Events == nil {
+		cp.Events = make(map[string]*CheckpointEvent)
+	}
+	return &cp, nil
+}
+
+// RemoveCheckpoint deletes the checkpoint for a template applied to a calendar
+func (j *Journal) RemoveCheckpoint(calendarID, templatePath string) error {
+	err := os.Remove(j.checkpointPath(calendarID, templatePath))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpointPath names checkpoint files after the calendar and absolute template path
+func (j *Journal) checkpointPath(calendarID, templatePath string) string {
+	if abs, err := filepath.Abs(templatePath); err == nil {
+		templatePath = abs
+	}
+
+	sum := sha1.Sum([]byte(calendarID + "\x00" + templatePath))
+	return filepath.Join(j.dir, "checkpoints", fmt.Sprintf("%x.json", sum[:8]))
+}
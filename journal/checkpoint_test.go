@@ -0,0 +1,110 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monil/calendar-event-generator/calendar"
+	"github.com/monil/calendar-event-generator/calendar/fake"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// studyEvents returns three evening events named Study on consecutive days
+func studyEvents() []models.CalendarEvent {
+	events := make([]models.CalendarEvent, 3)
+	for i := range events {
+		start := time.Date(2027, 3, 1+i, 18, 0, 0, 0, time.UTC)
+		events[i] = models.CalendarEvent{Name: "Study", StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	}
+	return events
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(fake.NewService())
+	t.Cleanup(srv.Close)
+	provider, err := calendar.NewGoogleProviderWithEndpoint(ctx, srv.URL+"/calendar/v3/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := calendar.NewClientWithProvider(provider, "primary")
+	client.SetRateLimit(0)
+	client.SetSource("/templates/study.yaml")
+	events := studyEvents()
+
+	// An import that wrote the first event, failed the third and stopped
+	// before the second
+	checkpoint := NewCheckpoint("primary", "study.yaml")
+	plan, err := client.Plan(ctx, events[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := client.ApplyPlan(ctx, plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Record(results[0])
+	checkpoint.Record(&calendar.EventResult{Event: &events[2], Error: errors.New("backend error")})
+
+	j := New(t.TempDir())
+	if err := j.SaveCheckpoint(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err = j.LoadCheckpoint("primary", "study.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Complete() {
+		t.Error("checkpoint with a failure is complete")
+	}
+
+	plan, err = client.Plan(ctx, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		failedOnly bool
+		want       []time.Time
+	}{
+		{false, []time.Time{events[1].StartTime, events[2].StartTime}},
+		{true, []time.Time{events[2].StartTime}},
+	} {
+		filtered := plan.Filter(func(e *models.CalendarEvent) bool {
+			return checkpoint.Pending(e, tt.failedOnly)
+		})
+		var got []time.Time
+		for _, entry := range filtered.Entries {
+			if entry.Action != calendar.PlanCreate {
+				t.Errorf("failedOnly %v: got %s %s, want only creates", tt.failedOnly, entry.Action, entry.Name())
+				continue
+			}
+			got = append(got, entry.Event.StartTime)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && !got[0].Equal(tt.want[0])) {
+			t.Errorf("failedOnly %v: got creates at %v, want %v", tt.failedOnly, got, tt.want)
+		}
+	}
+
+	// Resuming writes the rest, after which the template is in the calendar
+	plan = plan.Filter(func(e *models.CalendarEvent) bool { return checkpoint.Pending(e, false) })
+	results, err = client.ApplyPlan(ctx, plan, func(_, _ int, result *calendar.EventResult) {
+		checkpoint.Record(result)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !checkpoint.Complete() {
+		t.Errorf("got %d results and complete %v, want 2 and true", len(results), checkpoint.Complete())
+	}
+
+	plan, err = client.Plan(ctx, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := plan.Count(calendar.PlanNoop); n != len(events) || len(plan.Entries) != len(events) {
+		t.Errorf("got %d entries with %d unchanged, want %d unchanged", len(plan.Entries), n, len(events))
+	}
+}
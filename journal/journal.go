@@ -0,0 +1,157 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/calendar"
+)
+
+// ErrNoRuns is returned when the journal does not contain any runs to undo
+var ErrNoRuns = errors.New("no runs recorded in journal")
+
+// Run records the events created by a single add or sync invocation
+type Run struct {
+	ID           string     `json:"id"`
+	CalendarID   string     `json:"calendar_id"`
+	TemplatePath string     `json:"template_path"`
+	StartedAt    time.Time  `json:"started_at"`
+	EventIDs     []string   `json:"event_ids"`
+	UndoneAt     *time.Time `json:"undone_at,omitempty"`
+}
+
+// NewRun creates a run record for a template applied to a calendar
+func NewRun(calendarID, templatePath string) *Run {
+	now := time.Now()
+	if abs, err := filepath.Abs(templatePath); err == nil {
+		templatePath = abs
+	}
+
+	return &Run{
+		ID:           now.Format("20060102-150405.000"),
+		CalendarID:   calendarID,
+		TemplatePath: templatePath,
+		StartedAt:    now,
+	}
+}
+
+// AddResults records the IDs of the events that were created, including those
+// whose overrides then failed
+func (r *Run) AddResults(results []*calendar.EventResult) {
+	for _, result := range results {
+		if result.Action == calendar.ActionCreated && result.Remote != nil {
+			r.EventIDs = append(r.EventIDs, result.Remote.ID)
+		}
+	}
+}
+
+// Journal stores run records as JSON files in a directory
+type Journal struct {
+	dir string
+}
+
+// New creates a Journal backed by dir
+func New(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// Save writes a run record, replacing any previous record with the same ID
+func (j *Journal) Save(run *Run) error {
+	if err := checkID(run.ID); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("unable to create journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(j.path(run.ID), data, 0600); err != nil {
+		return fmt.Errorf("unable to write journal: %w", err)
+	}
+	return nil
+}
+
+// Load reads the run record with the given ID
+func (j *Journal) Load(id string) (*Run, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(j.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("run not found: %s", id)
+		}
+		return nil, fmt.Errorf("unable to read journal: %w", err)
+	}
+
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("unable to parse run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+// List returns all recorded runs, newest first
+func (j *Journal) List() ([]*Run, error) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read journal: %w", err)
+	}
+
+	var runs []*Run
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		run, err := j.Load(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(a, b int) bool {
+		return runs[a].StartedAt.After(runs[b].StartedAt)
+	})
+	return runs, nil
+}
+
+// Latest returns the most recent run that has not been undone
+func (j *Journal) Latest() (*Run, error) {
+	runs, err := j.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if run.UndoneAt == nil {
+			return run, nil
+		}
+	}
+	return nil, ErrNoRuns
+}
+
+// checkID rejects run IDs that would name a file outside the journal directory
+func checkID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) || filepath.Base(id) != id {
+		return fmt.Errorf("invalid run ID: %q", id)
+	}
+	return nil
+}
+
+func (j *Journal) path(id string) string {
+	return filepath.Join(j.dir, id+".json")
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/monil/calendar-event-generator/calendar"
+	"github.com/monil/calendar-event-generator/calendar/fake"
 	"github.com/monil/calendar-event-generator/config"
 	"github.com/monil/calendar-event-generator/exporter"
 	"github.com/monil/calendar-event-generator/interactive"
+	"github.com/monil/calendar-event-generator/journal"
+	"github.com/monil/calendar-event-generator/models"
+	"github.com/monil/calendar-event-generator/recurrence"
 	"github.com/monil/calendar-event-generator/templates"
 	"github.com/monil/calendar-event-generator/utils"
 	"github.com/spf13/cobra"
@@ -37,32 +49,58 @@ Supported formats:
   - single:    Simple one-off events
   - recurring: Events with recurrence rules (daily, weekly, monthly)
   - daterange: Multi-day or all-day events
+  - bundle:    Sections of the formats above in one file
 
+Templates can be written in JSON, YAML (.yaml, .yml) or TOML (.toml),
+exported from a spreadsheet as CSV (.csv), or be iCalendar (.ics) files.
 The format is auto-detected by default, or can be specified with --format.`,
 	Version: Version,
+	// Usage is shown for flag errors only, not when a command fails or is
+	// interrupted once it has started
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return interactive.Run(cfg)
+		return interactive.Run(cfg, func(input, source string) error {
+			inputFile, sourceName = input, source
+			return runAdd(cmd, nil)
+		})
 	},
 }
 
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add events from a JSON template to Google Calendar",
-	Long:  `Parse a JSON template file and create events in Google Calendar.`,
-	RunE:  runAdd,
+	Long: `Parse a JSON template file and create events in Google Calendar.
+
+Events are tagged with a stable key, so running add again after editing the
+template updates the events it created earlier instead of duplicating them.
+Unchanged events are skipped.
+
+Progress is checkpointed as events are written. If a run stops part way, use
+--resume to continue with the events it did not write, or --retry-failed to
+re-attempt only the ones that failed.`,
+	RunE: runAdd,
 }
 
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate a JSON template without creating events",
-	Long:  `Parse and validate a JSON template file without making any API calls.`,
-	RunE:  runValidate,
+	Long: `Parse and validate a JSON template file without making any API calls.
+
+Every problem found is reported with its file, line and column, the JSON path
+of the value, and a suggested fix where one is known. Use --output-format json
+or sarif to feed the report to editors and CI.
+
+With --lint, the schedule is also checked for likely mistakes. Rules can be
+switched off with --lint-rule, e.g. --lint-rule past-date=off:`,
+	RunE: runValidate,
 }
 
 var listCalendarsCmd = &cobra.Command{
 	Use:   "list-calendars",
-	Short: "List available Google Calendars",
-	Long:  `Display all calendars available in your Google account.`,
+	Short: "List available calendars",
+	Long:  `Display all calendars available in your Google account or other calendar backend.`,
 	RunE:  runListCalendars,
 }
 
@@ -72,49 +110,237 @@ var exportCmd = &cobra.Command{
 	Long:  `Generate an iCalendar (.ics) file from a JSON template.`,
 	RunE:  runExport,
 }
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what add would change in the calendar",
+	Long: `Compare a JSON template against the events already in the target calendar
+and show which events would be created, updated (with field-level changes), or
+are orphaned: created by an earlier run but no longer in the template.
+
+Only events within the template's time window are compared. No changes are made.`,
+	RunE: runPlan,
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Make the calendar match a JSON template",
+	Long: `Create and update events like add, then treat the template as the source of
+truth for the events it owns: events created from this template that no longer
+appear in it are listed and, with --prune, removed after confirmation.
+
+Events are owned by the template's absolute path, or by --source when given.
+After a template moves, add or sync takes ownership of its events again; see
+the README for events it no longer contains.`,
+	RunE: runSync,
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [run-id]",
+	Short: "Delete the events created by an earlier add or sync run",
+	Long: `Every add and sync run is recorded in a local journal together with the IDs
+of the events it created. undo deletes all events created by the given run, or
+by the most recent run that has not been undone yet. Events that were updated
+rather than created are left untouched.
 
+Use --list to show the recorded runs.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUndo,
+}
+
+var occurrencesCmd = &cobra.Command{
+	Use:   "occurrences",
+	Short: "List the dates on which template events occur",
+	Long: `Expand the events of a template, including their recurrence rules and
+exceptions, into the individual occurrences between --from and --to (inclusive)
+without contacting a calendar.`,
+	RunE: runOccurrences,
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert a template to another format",
+	Long: `Parse a template in any supported format and write its events as another
+template: --to json, yaml or toml picks the single, recurring, daterange or
+bundle format that holds the events; --to single, recurring, daterange or
+bundle forces one, written in the encoding of the --output extension (JSON by default); --to csv
+and --to ics write those files. Details the target format cannot hold are
+reported as warnings.`,
+	RunE: runConvert,
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Write the events of a calendar to a template",
+	Long: `Read the events of the target calendar between --from and --to (inclusive)
+and write them as a template, in the encoding of the --output extension (JSON
+by default). One-off events are written in the single format, or daterange when
+some span several days, recurring events in the recurring format, and a mix of
+both in the bundle format. --format single or recurring pulls only those events.
+
+Events keep their calendar event ID as template ID, so adding the edited
+template again updates them in place.`,
+	RunE: runPull,
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema <format>",
+	Short: "Print the JSON Schema of a template format",
+	Long: `Print the JSON Schema of the weekly, single, recurring, daterange or bundle
+template format, for editors to complete and check templates with. The schema is
+generated from the fields the tool reads, so it matches the running version.
+YAML and TOML templates are checked against the same schema.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"weekly", "single", "recurring", "daterange", "bundle"},
+	RunE:      runSchema,
+}
+
+var fakeServerCmd = &cobra.Command{
+	Use:   "fake-server",
+	Short: "Serve an in-memory Google Calendar API for offline runs",
+	Long: `Start an in-memory stand-in for the Google Calendar API. Point other
+commands at it with --endpoint to try templates end to end without a Google
+account. State is lost when the server stops.`,
+	Hidden: true,
+	RunE:   runFakeServer,
+}
 
 var inputFile string
 var outputFile string
 var formatOverride string
+var sourceName string
+var pruneOrphans bool
+var cancelOrphans bool
+var assumeYes bool
+var listRuns bool
+var resumeImport bool
+var retryFailed bool
+var fakeServerAddr string
+var occurrencesFrom string
+var occurrencesTo string
+var csvColumns map[string]string
+var templateVars map[string]string
+var strictParsing bool
+var convertTo string
+var pullFrom string
+var pullTo string
+var validateOutput string
+var validateLint bool
+var lintRules map[string]string
+var validateSchema bool
 
 func init() {
 	// Global flags
+	rootCmd.PersistentFlags().StringVar(&cfg.Backend, "backend", cfg.Backend, "Calendar backend: google, caldav, ics-dir")
 	rootCmd.PersistentFlags().StringVar(&cfg.CredentialsPath, "credentials", cfg.CredentialsPath, "Path to Google OAuth credentials.json")
 	rootCmd.PersistentFlags().StringVar(&cfg.TokenPath, "token", cfg.TokenPath, "Path to store OAuth token")
+	rootCmd.PersistentFlags().StringVar(&cfg.Endpoint, "endpoint", cfg.Endpoint, "Google Calendar API base URL, e.g. a fake-server; disables OAuth (google backend)")
+	rootCmd.PersistentFlags().StringVar(&cfg.JournalDir, "journal", cfg.JournalDir, "Directory where runs are recorded for undo")
+	rootCmd.PersistentFlags().StringVar(&cfg.CalDAVURL, "caldav-url", cfg.CalDAVURL, "CalDAV calendar or calendar home URL (caldav backend)")
+	rootCmd.PersistentFlags().StringVar(&cfg.CalDAVUsername, "caldav-user", cfg.CalDAVUsername, "CalDAV username (caldav backend)")
+	rootCmd.PersistentFlags().StringVar(&cfg.CalDAVPassword, "caldav-password", cfg.CalDAVPassword, "CalDAV password, defaults to $CALDAV_PASSWORD (caldav backend)")
+	rootCmd.PersistentFlags().StringVar(&cfg.ICSDir, "ics-dir", cfg.ICSDir, "Directory of .ics files to use as the calendar (ics-dir backend)")
 	rootCmd.PersistentFlags().StringVar(&cfg.CalendarID, "calendar", cfg.CalendarID, "Target calendar ID or 'primary'")
+	rootCmd.PersistentFlags().IntVar(&cfg.Workers, "workers", cfg.Workers, "Number of events written to the calendar concurrently")
+	rootCmd.PersistentFlags().IntVar(&cfg.BatchSize, "batch-size", cfg.BatchSize, "Writes grouped into one batch request (google backend, max 50), 0 to disable")
+	rootCmd.PersistentFlags().Float64Var(&cfg.QPS, "qps", cfg.QPS, "Maximum calendar API calls per second, 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&cfg.MaxRetries, "retries", cfg.MaxRetries, "Retries for calls failing with rate limit or server errors")
 	rootCmd.PersistentFlags().StringVar(&cfg.Timezone, "timezone", cfg.Timezone, "Timezone for events (e.g., 'America/New_York', 'local')")
 	rootCmd.PersistentFlags().BoolVarP(&cfg.Verbose, "verbose", "v", cfg.Verbose, "Enable verbose output")
 
 	// Add command flags
-	addCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input JSON template file (required)")
-	addCmd.Flags().StringVarP(&formatOverride, "format", "f", "auto", "Template format: auto, weekly, single, recurring, daterange")
+	addTemplateFlags(addCmd)
+	addCmd.Flags().StringVar(&sourceName, "source", "", "Name identifying the events owned by this template (default: absolute template path)")
 	addCmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "Preview events without creating them")
-	addCmd.MarkFlagRequired("input")
+	addCmd.Flags().BoolVar(&resumeImport, "resume", false, "Continue an earlier add of this template, skipping events it wrote")
+	addCmd.Flags().BoolVar(&retryFailed, "retry-failed", false, "Re-attempt only the events that failed in an earlier add of this template")
+	addCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before replacing edited events")
+	addCmd.MarkFlagsMutuallyExclusive("resume", "retry-failed")
 
 	// Validate command flags
-	validateCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input JSON template file (required)")
-	validateCmd.Flags().StringVarP(&formatOverride, "format", "f", "auto", "Template format: auto, weekly, single, recurring, daterange")
-	validateCmd.MarkFlagRequired("input")
+	addTemplateFlags(validateCmd)
+	validateCmd.Flags().StringVar(&validateOutput, "output-format", "text", "Report format: text, json, sarif")
+	for _, rule := range templates.LintRules {
+		validateCmd.Long += fmt.Sprintf("\n  %-17s %s", rule.Name, rule.Description)
+	}
+	validateCmd.Flags().BoolVar(&validateLint, "lint", false, "Also check the schedule against the lint rules")
+	validateCmd.Flags().StringToStringVar(&lintRules, "lint-rule", nil, "Switch a lint rule on or off, e.g. past-date=off,overlap=on")
+	validateCmd.Flags().BoolVar(&validateSchema, "schema", false, "Check the template against the JSON Schema of its format before converting it")
+
+	// Plan command flags
+	addTemplateFlags(planCmd)
+	planCmd.Flags().StringVar(&sourceName, "source", "", "Name identifying the events owned by this template (default: absolute template path)")
+
+	// Sync command flags
+	addTemplateFlags(syncCmd)
+	syncCmd.Flags().StringVar(&sourceName, "source", "", "Name identifying the events owned by this template (default: absolute template path)")
+	syncCmd.Flags().BoolVar(&pruneOrphans, "prune", false, "Remove events that are no longer in the template")
+	syncCmd.Flags().BoolVar(&cancelOrphans, "cancel", false, "Cancel removed events and notify attendees instead of deleting them")
+	syncCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before replacing or removing events")
+
+	// Undo command flags
+	undoCmd.Flags().BoolVar(&listRuns, "list", false, "List recorded runs instead of undoing one")
+	undoCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before deleting events")
 
 	// Register commands
 	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(undoCmd)
 	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(listCalendarsCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(occurrencesCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(fakeServerCmd)
+
+	// Fake server command flags
+	fakeServerCmd.Flags().StringVar(&fakeServerAddr, "addr", "127.0.0.1:8085", "Address to listen on")
 
 	// Export command flags
-	exportCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input JSON template file (required)")
+	addTemplateFlags(exportCmd)
 	exportCmd.Flags().StringVarP(&outputFile, "output", "o", "events.ics", "Output ICS file path")
-	exportCmd.Flags().StringVarP(&formatOverride, "format", "f", "auto", "Template format: auto, weekly, single, recurring, daterange")
-	exportCmd.MarkFlagRequired("input")
+
+	// Occurrences command flags
+	addTemplateFlags(occurrencesCmd)
+	occurrencesCmd.Flags().StringVar(&occurrencesFrom, "from", "", "First date to list (default: start of each event)")
+	occurrencesCmd.Flags().StringVar(&occurrencesTo, "to", "", "Last date to list (default: end of each series, at most 1000 occurrences)")
+
+	// Convert command flags
+	addTemplateFlags(convertCmd)
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "Output format: json, yaml, toml, single, recurring, daterange, bundle, csv, ics (required)")
+	convertCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path (default: standard output)")
+	convertCmd.MarkFlagRequired("to")
+
+	// Pull command flags
+	pullCmd.Flags().StringVar(&pullFrom, "from", "today", "First date to pull")
+	pullCmd.Flags().StringVar(&pullTo, "to", "", "Last date to pull (default: a year after --from)")
+	pullCmd.Flags().StringVarP(&formatOverride, "format", "f", "auto", "Template format: auto, single, recurring, daterange, bundle")
+	pullCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output template file (default: standard output)")
+	pullCmd.Flags().StringVar(&sourceName, "source", "", "Only pull events added from this template file or --source name")
+
+	// Schema command flags
+	schemaCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path (default: standard output)")
+}
+
+// addTemplateFlags adds the flags of the commands that read a template:
+// --input, which it marks required, --format, --map, --var and --strict
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input template file: JSON, YAML, TOML, CSV or ICS (required)")
+	cmd.Flags().StringVarP(&formatOverride, "format", "f", "auto", "Template format: auto, weekly, single, recurring, daterange, bundle, csv, ics")
+	cmd.Flags().StringToStringVar(&csvColumns, "map", nil, "CSV column for a field when its header is not recognised, e.g. name=Course,date=Day")
+	cmd.Flags().StringToStringVar(&templateVars, "var", nil, "Template variable, overriding the template's vars, e.g. cohort=A")
+	cmd.Flags().BoolVar(&strictParsing, "strict", false, "Reject unknown template fields, suggesting the field probably meant")
+	cmd.MarkFlagRequired("input")
 }
 
 func runAdd(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	ctx, stop := interruptContext()
+	defer stop()
 
 	// Parse template
-	parser, err := templates.NewParser(cfg.Timezone)
+	parser, err := newParser()
 	if err != nil {
 		return fmt.Errorf("failed to create parser: %w", err)
 	}
@@ -124,6 +350,7 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
+	printWarnings(parser)
 
 	fmt.Printf("found %d events in template\n", len(events))
 
@@ -134,50 +361,202 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create calendar client
-	client, err := calendar.NewClient(ctx, cfg.CredentialsPath, cfg.TokenPath, cfg.CalendarID)
+	client, err := calendar.Open(ctx, cfg, cfg.CalendarID)
 	if err != nil {
 		return fmt.Errorf("failed to create calendar client: %w", err)
 	}
 
-	fmt.Printf("Adding events to calendar: %s\n\n", client.GetCalendarID())
+	client.SetSource(templateSource())
 
-	// Create events with progress
-	results, err := client.CreateEvents(events, func(current, total int, result *calendar.EventResult) {
-		if result.Success {
-			fmt.Printf("[OK] [%d/%d] %s\n", current, total, result.Event.Name)
-			if cfg.Verbose && result.Link != "" {
-				fmt.Printf("   └─ %s\n", result.Link)
-			}
-		} else {
-			fmt.Printf("[ERR] [%d/%d] %s: %v\n", current, total, result.Event.Name, result.Error)
+	// Pick up where an earlier run stopped
+	j := journal.New(cfg.JournalDir)
+	checkpoint := journal.NewCheckpoint(client.GetCalendarID(), inputFile)
+	if resumeImport || retryFailed {
+		checkpoint, err = j.LoadCheckpoint(client.GetCalendarID(), inputFile)
+		if err != nil {
+			return fmt.Errorf("cannot resume %s: %w", inputFile, err)
 		}
-	})
+	}
 
+	fmt.Printf("Adding events to calendar: %s\n\n", client.GetCalendarID())
+
+	// The whole template is planned, even when resuming, so the events
+	// written earlier are matched rather than taken for orphans
+	plan, err := client.Plan(ctx, events)
 	if err != nil {
+		return fmt.Errorf("failed to plan changes: %w", err)
+	}
+	confirmReplaced(plan)
+	warnReplaced(plan)
+
+	if resumeImport || retryFailed {
+		plan = plan.Filter(func(e *models.CalendarEvent) bool {
+			return checkpoint.Pending(e, retryFailed)
+		})
+		fmt.Printf("Resuming: %d of %d events left to write\n\n", len(plan.Entries)-plan.Count(calendar.PlanOrphan), len(events))
+	}
+
+	// Create or update events with progress, checkpointing each result
+	var checkpointErr error
+	run := journal.NewRun(client.GetCalendarID(), inputFile)
+	results, err := client.ApplyPlan(ctx, plan, func(current, total int, result *calendar.EventResult) {
+		printResult(current, total, result)
+		checkpoint.Record(result)
+		if saveErr := j.SaveCheckpoint(checkpoint); saveErr != nil && checkpointErr == nil {
+			checkpointErr = saveErr
+			fmt.Fprintf(os.Stderr, "Warning: unable to save checkpoint, this run cannot be resumed: %v\n", saveErr)
+		}
+	})
+	if results == nil {
 		return err
 	}
 
-	// Summary
-	var successCount, failCount int
+	printResultSummary(results)
+	recordRun(run, results)
+
+	if err == nil && checkpoint.Complete() {
+		if err := j.RemoveCheckpoint(client.GetCalendarID(), inputFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	} else if checkpointErr == nil {
+		fmt.Printf("Some events were not written. Continue with: add -i %s --resume (or --retry-failed for failures only)\n", inputFile)
+	}
+
+	return interrupted(err)
+}
+
+// confirmReplaced lists the calendar events that edited template events would
+// replace, and leaves them alone unless the user agrees
+func confirmReplaced(plan *calendar.Plan) {
+	n := plan.Count(calendar.PlanReplace)
+	if n == 0 {
+		return
+	}
+
+	fmt.Printf("%d calendar events look like earlier versions of edited template events:\n", n)
+	for _, entry := range plan.Entries {
+		if entry.Action == calendar.PlanReplace {
+			fmt.Printf("  %s -> %s (%s)\n", entry.Remote.Event.Name, entry.Event.Name, entry.Event.StartTime.Format("Mon, Jan 2 2006 3:04 PM"))
+		}
+	}
+	if !assumeYes && !confirm(fmt.Sprintf("Update these %d events in place?", n)) {
+		fmt.Println("The edited events will be created as new events.")
+		plan.Unpair()
+	}
+	fmt.Println()
+}
+
+// warnReplaced warns when adding a template would leave the old copies of
+// edited events in the calendar
+func warnReplaced(plan *calendar.Plan) {
+	if plan.Replaces() {
+		fmt.Fprintf(os.Stderr, "Warning: %d events of this template are no longer in it and %d are new. "+
+			"If they were edited, the old copies stay in the calendar: give events an id to keep them matched, "+
+			"or remove the old copies with sync --prune.\n\n", plan.Count(calendar.PlanOrphan), plan.Count(calendar.PlanCreate))
+	}
+}
+
+// interruptContext returns a context that is cancelled on the first Ctrl-C so
+// in-flight calls can finish and be recorded; a second Ctrl-C exits at once
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	context.AfterFunc(ctx, stop)
+	return ctx, stop
+}
+
+// interrupted explains a cancellation caused by Ctrl-C
+func interrupted(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("interrupted, remaining events were not written")
+	}
+	return err
+}
+
+// printResult prints the progress line for a single event result
+func printResult(current, total int, result *calendar.EventResult) {
+	if !result.Success {
+		fmt.Printf("[ERR] [%d/%d] %s: %v\n", current, total, result.Event.Name, result.Error)
+		return
+	}
+
+	switch result.Action {
+	case calendar.ActionUpdated:
+		fmt.Printf("[UPD] [%d/%d] %s\n", current, total, result.Event.Name)
+	case calendar.ActionUnchanged:
+		fmt.Printf("[SKIP] [%d/%d] %s (unchanged)\n", current, total, result.Event.Name)
+	case calendar.ActionDeleted:
+		fmt.Printf("[DEL] [%d/%d] %s\n", current, total, result.Event.Name)
+	case calendar.ActionCancelled:
+		fmt.Printf("[CXL] [%d/%d] %s\n", current, total, result.Event.Name)
+	default:
+		fmt.Printf("[OK] [%d/%d] %s\n", current, total, result.Event.Name)
+	}
+	if cfg.Verbose && result.Link != "" {
+		fmt.Printf("   └─ %s\n", result.Link)
+	}
+}
+
+// printResultSummary prints the totals for a set of event results
+func printResultSummary(results []*calendar.EventResult) {
+	counts := make(map[calendar.EventAction]int)
+	var failCount int
 	for _, r := range results {
 		if r.Success {
-			successCount++
+			counts[r.Action]++
 		} else {
 			failCount++
 		}
 	}
 
-	fmt.Printf("\nDone! Created %d events", successCount)
+	fmt.Printf("\nDone! Created %d, updated %d, unchanged %d events",
+		counts[calendar.ActionCreated], counts[calendar.ActionUpdated], counts[calendar.ActionUnchanged])
+	if removed := counts[calendar.ActionDeleted] + counts[calendar.ActionCancelled]; removed > 0 {
+		fmt.Printf(", removed %d", removed)
+	}
 	if failCount > 0 {
 		fmt.Printf(" (%d failed)", failCount)
 	}
 	fmt.Println()
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	parser, err := newParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	format := templates.TemplateFormat(strings.ToLower(formatOverride))
+	events, err := parser.ParseFile(inputFile, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	printWarnings(parser)
+
+	client, err := calendar.Open(ctx, cfg, cfg.CalendarID)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar client: %w", err)
+	}
+
+	client.SetSource(templateSource())
+	plan, err := client.Plan(ctx, events)
+	if err != nil {
+		return fmt.Errorf("failed to plan changes: %w", err)
+	}
+
+	fmt.Printf("Comparing %d template events with calendar: %s\n\n", len(events), client.GetCalendarID())
+	printPlan(plan, cfg.Verbose)
 
 	return nil
 }
 
-func runValidate(cmd *cobra.Command, args []string) error {
-	parser, err := templates.NewParser(cfg.Timezone)
+func runSync(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	parser, err := newParser()
 	if err != nil {
 		return fmt.Errorf("failed to create parser: %w", err)
 	}
@@ -185,7 +564,273 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	format := templates.TemplateFormat(strings.ToLower(formatOverride))
 	events, err := parser.ParseFile(inputFile, format)
 	if err != nil {
-		return fmt.Errorf("Validation failed: %w", err)
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	printWarnings(parser)
+
+	client, err := calendar.Open(ctx, cfg, cfg.CalendarID)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar client: %w", err)
+	}
+
+	client.SetSource(templateSource())
+
+	plan, err := client.Plan(ctx, events)
+	if err != nil {
+		return fmt.Errorf("failed to plan changes: %w", err)
+	}
+	confirmReplaced(plan)
+
+	fmt.Printf("Syncing %d events to calendar: %s\n\n", len(events), client.GetCalendarID())
+
+	run := journal.NewRun(client.GetCalendarID(), inputFile)
+	results, err := client.ApplyPlan(ctx, plan, printResult)
+	recordRun(run, results)
+	if err != nil {
+		printResultSummary(results)
+		return interrupted(err)
+	}
+
+	if orphans := plan.Count(calendar.PlanOrphan); orphans > 0 {
+		fmt.Printf("\n%d events from %s are no longer in the template:\n", orphans, templateSource())
+		for _, entry := range plan.Entries {
+			if entry.Action == calendar.PlanOrphan {
+				fmt.Printf("  - %s (%s)\n", entry.Name(), entry.Remote.Event.StartTime.Format("Mon, Jan 2 2006 3:04 PM"))
+			}
+		}
+
+		switch {
+		case !pruneOrphans:
+			fmt.Println("\nRe-run with --prune to remove them.")
+		case !assumeYes && !confirm(fmt.Sprintf("Remove these %d events?", orphans)):
+			fmt.Println("Skipped removing events.")
+		default:
+			fmt.Println()
+			results = append(results, client.RemoveOrphans(ctx, plan, cancelOrphans, printResult)...)
+		}
+	}
+
+	printResultSummary(results)
+
+	return nil
+}
+
+// templateSource returns the source owning the events of the input template:
+// --source if given, else the template's path
+func templateSource() string {
+	if sourceName != "" {
+		return sourceName
+	}
+	return calendar.SourceName(inputFile)
+}
+
+// recordRun saves the events created by a run to the journal so it can be undone
+func recordRun(run *journal.Run, results []*calendar.EventResult) {
+	run.AddResults(results)
+	if len(run.EventIDs) == 0 {
+		return
+	}
+
+	if err := journal.New(cfg.JournalDir).Save(run); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to record run for undo: %v\n", err)
+		return
+	}
+	fmt.Printf("Run recorded as %s (revert with: undo %s)\n", run.ID, run.ID)
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+	j := journal.New(cfg.JournalDir)
+
+	if listRuns {
+		runs, err := j.List()
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Println("No runs recorded.")
+		}
+		for _, run := range runs {
+			status := ""
+			if run.UndoneAt != nil {
+				status = " (undone)"
+			}
+			fmt.Printf("  %s  %d events  %s -> %s%s\n", run.ID, len(run.EventIDs), run.TemplatePath, run.CalendarID, status)
+		}
+		return nil
+	}
+
+	var run *journal.Run
+	var err error
+	if len(args) > 0 {
+		run, err = j.Load(args[0])
+	} else {
+		run, err = j.Latest()
+	}
+	if err != nil {
+		return err
+	}
+
+	if run.UndoneAt != nil {
+		return fmt.Errorf("run %s was already undone on %s", run.ID, run.UndoneAt.Format("Jan 2, 2006 3:04 PM"))
+	}
+
+	fmt.Printf("Run %s created %d events in %s from %s\n", run.ID, len(run.EventIDs), run.CalendarID, run.TemplatePath)
+	if !assumeYes && !confirm(fmt.Sprintf("Delete these %d events?", len(run.EventIDs))) {
+		fmt.Println("Nothing deleted.")
+		return nil
+	}
+
+	client, err := calendar.Open(ctx, cfg, run.CalendarID)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar client: %w", err)
+	}
+
+	var failCount int
+	client.DeleteEvents(ctx, run.EventIDs, func(current, total int, result *calendar.EventResult) {
+		if result.Error != nil && !calendar.IsNotFound(result.Error) {
+			fmt.Printf("[ERR] [%d/%d] %s: %v\n", current, total, result.Remote.ID, result.Error)
+			failCount++
+			return
+		}
+		fmt.Printf("[DEL] [%d/%d] %s\n", current, total, result.Remote.ID)
+	})
+
+	if failCount > 0 {
+		return fmt.Errorf("failed to delete %d events, run undo %s again to retry", failCount, run.ID)
+	}
+
+	now := time.Now()
+	run.UndoneAt = &now
+	if err := j.Save(run); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nDone! Reverted run %s\n", run.ID)
+	return nil
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("\n%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printPlan prints a plan in a diff-like format
+func printPlan(plan *calendar.Plan, verbose bool) {
+	for _, entry := range plan.Entries {
+		switch entry.Action {
+		case calendar.PlanCreate:
+			fmt.Printf("+ create  %s (%s)\n", entry.Name(), entry.Event.StartTime.Format("Mon, Jan 2 2006 3:04 PM"))
+		case calendar.PlanUpdate:
+			fmt.Printf("~ update  %s\n", entry.Name())
+			for _, change := range entry.Changes {
+				fmt.Printf("      %s: %q -> %q\n", change.Field, change.Old, change.New)
+			}
+		case calendar.PlanReplace:
+			fmt.Printf("~ replace %s (replaces %s, asks before updating it)\n", entry.Name(), entry.Remote.Event.Name)
+			for _, change := range entry.Changes {
+				fmt.Printf("      %s: %q -> %q\n", change.Field, change.Old, change.New)
+			}
+		case calendar.PlanOrphan:
+			fmt.Printf("- orphan  %s\n", entry.Name())
+		case calendar.PlanNoop:
+			if verbose {
+				fmt.Printf("  same    %s\n", entry.Name())
+			}
+		}
+	}
+
+	fmt.Printf("\nPlan: %d to create, %d to update, %d to replace, %d orphaned, %d unchanged.\n",
+		plan.Count(calendar.PlanCreate),
+		plan.Count(calendar.PlanUpdate),
+		plan.Count(calendar.PlanReplace),
+		plan.Count(calendar.PlanOrphan),
+		plan.Count(calendar.PlanNoop))
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	parser, err := newParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	format := templates.TemplateFormat(strings.ToLower(formatOverride))
+	output := strings.ToLower(validateOutput)
+	switch output {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("unknown --output-format: %s (expected text, json or sarif)", validateOutput)
+	}
+
+	if validateLint {
+		for name, value := range lintRules {
+			enabled, err := parseSwitch(value)
+			if err != nil {
+				return fmt.Errorf("invalid --lint-rule %s: %w", name, err)
+			}
+			if cfg.LintRules == nil {
+				cfg.LintRules = make(map[string]bool)
+			}
+			cfg.LintRules[name] = enabled
+		}
+		if parser.Lint, err = templates.LintRulesFor(cfg.LintRules); err != nil {
+			return err
+		}
+	}
+
+	parser.CheckSchema = validateSchema
+
+	// Every problem, including the one that stopped parsing, is in the diagnostics
+	events, _ := parser.ParseFile(inputFile, format)
+
+	errorCount := 0
+	for _, d := range parser.Diagnostics {
+		if d.Severity == templates.SeverityError {
+			errorCount++
+		}
+	}
+
+	switch output {
+	case "json":
+		diagnostics := parser.Diagnostics
+		if diagnostics == nil {
+			diagnostics = []templates.Diagnostic{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			Valid       bool                   `json:"valid"`
+			Events      int                    `json:"events"`
+			Diagnostics []templates.Diagnostic `json:"diagnostics"`
+		}{errorCount == 0, len(events), diagnostics})
+	case "sarif":
+		err = templates.WriteSARIF(os.Stdout, parser.Diagnostics, "calendar-event-generator", Version)
+	default:
+		for _, d := range parser.Diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if errorCount > 0 {
+		if errorCount == 1 {
+			return fmt.Errorf("Validation failed: 1 error")
+		}
+		return fmt.Errorf("Validation failed: %d errors", errorCount)
+	}
+	if output != "text" {
+		return nil
 	}
 
 	fmt.Printf("Template is valid!\n")
@@ -196,15 +841,232 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runOccurrences(cmd *cobra.Command, args []string) error {
+	parser, err := newParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	var from, to time.Time
+	if occurrencesFrom != "" {
+		if from, err = parser.TimeParser.ParseDate(occurrencesFrom); err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if occurrencesTo != "" {
+		if to, err = parser.TimeParser.ParseDate(occurrencesTo); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+		// Include the whole last day
+		to = to.AddDate(0, 0, 1)
+	}
+
+	format := templates.TemplateFormat(strings.ToLower(formatOverride))
+	events, err := parser.ParseFile(inputFile, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	printWarnings(parser)
+
+	total := 0
+	for i := range events {
+		event := &events[i]
+		occurrences, err := recurrence.Expand(event, from, to)
+		if err != nil {
+			return fmt.Errorf("failed to expand '%s': %w", event.Name, err)
+		}
+		if len(occurrences) == 0 {
+			continue
+		}
+
+		fmt.Printf("%s (%d)\n", event.Name, len(occurrences))
+		for _, o := range occurrences {
+			line := o.Start.Format("Mon, Jan 2 2006 3:04 PM") + " - " + o.End.Format("3:04 PM")
+			if event.AllDay {
+				line = o.Start.Format("Mon, Jan 2 2006") + " (All day)"
+			}
+			if note := o.Describe(); note != "" {
+				line += " [" + note + "]"
+			}
+			if o.Location != event.Location && o.Location != "" {
+				line += " @ " + o.Location
+			}
+			fmt.Printf("  %s\n", line)
+		}
+		fmt.Println()
+		total += len(occurrences)
+	}
+
+	fmt.Printf("%d occurrences of %d events\n", total, len(events))
+	return nil
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	var to templates.TemplateFormat
+	enc := templates.EncodingFor(outputFile)
+	switch target := strings.ToLower(convertTo); target {
+	case "json", "yaml", "toml":
+		to, enc = templates.FormatAuto, templates.Encoding(target)
+	case "single", "recurring", "daterange", "bundle", "csv", "ics":
+		to = templates.TemplateFormat(target)
+	default:
+		return fmt.Errorf("unknown --to format: %s (expected json, yaml, toml, single, recurring, daterange, bundle, csv or ics)", convertTo)
+	}
+
+	parser, err := newParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	format := templates.TemplateFormat(strings.ToLower(formatOverride))
+	events, err := parser.ParseFile(inputFile, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	printWarnings(parser)
+
+	var buf bytes.Buffer
+	warnings, err := templates.Write(&buf, events, to, enc)
+	if err != nil {
+		return fmt.Errorf("failed to convert template: %w", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if outputFile == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Converted %d events to %s\n", len(events), outputFile)
+	return nil
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	schema, err := templates.SchemaFor(templates.TemplateFormat(strings.ToLower(args[0])))
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %w", err)
+	}
+	data = append(data, '\n')
+
+	if outputFile == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+func runPull(cmd *cobra.Command, args []string) error {
+	parser, err := newParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	var opts calendar.ListOptions
+	if strings.EqualFold(pullFrom, "today") {
+		now := time.Now().In(parser.TimeParser.Location)
+		opts.TimeMin = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	} else if opts.TimeMin, err = parser.TimeParser.ParseDate(pullFrom); err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	opts.TimeMax = opts.TimeMin.AddDate(1, 0, 0)
+	if pullTo != "" {
+		if opts.TimeMax, err = parser.TimeParser.ParseDate(pullTo); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+		// Include the whole last day
+		opts.TimeMax = opts.TimeMax.AddDate(0, 0, 1)
+	}
+
+	format := templates.TemplateFormat(strings.ToLower(formatOverride))
+	switch format {
+	case templates.FormatAuto, templates.FormatSingle, templates.FormatRecurring, templates.FormatDateRange, templates.FormatBundle:
+	default:
+		return fmt.Errorf("cannot pull into %s templates (expected auto, single, recurring, daterange or bundle)", formatOverride)
+	}
+
+	ctx := context.Background()
+	client, err := calendar.Open(ctx, cfg, cfg.CalendarID)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar client: %w", err)
+	}
+	// Templates own their events by path; a --source naming one picks its events
+	source := sourceName
+	if info, err := os.Stat(source); err == nil && !info.IsDir() {
+		source = calendar.SourceName(source)
+	}
+	client.SetSource(source)
+
+	pulled, err := client.Pull(ctx, opts, parser.TimeParser.Location)
+	if err != nil {
+		return fmt.Errorf("failed to pull events: %w", err)
+	}
+
+	// Keep the events the format can hold
+	var events []models.CalendarEvent
+	skipped := 0
+	for _, e := range pulled {
+		recurring := e.Recurrence != nil
+		if format == templates.FormatAuto || format == templates.FormatBundle || recurring == (format == templates.FormatRecurring) {
+			events = append(events, e)
+		} else {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d events that the %s format cannot hold\n", skipped, format)
+	}
+
+	var buf bytes.Buffer
+	warnings, err := templates.Write(&buf, events, format, templates.EncodingFor(outputFile))
+	if err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if outputFile == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Pulled %d events from %s to %s\n", len(events), client.GetCalendarID(), outputFile)
+	return nil
+}
+
+func runFakeServer(cmd *cobra.Command, args []string) error {
+	listener, err := net.Listen("tcp", fakeServerAddr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Fake Google Calendar API listening on http://%s/calendar/v3/\n", listener.Addr())
+	fmt.Printf("Use --endpoint http://%s/calendar/v3/ to point other commands at it\n", listener.Addr())
+	return http.Serve(listener, fake.NewService())
+}
+
 func runListCalendars(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
-	client, err := calendar.NewClient(ctx, cfg.CredentialsPath, cfg.TokenPath, "primary")
+	client, err := calendar.Open(ctx, cfg, "primary")
 	if err != nil {
 		return fmt.Errorf("failed to create calendar client: %w", err)
 	}
 
-	calendars, err := client.ListCalendars()
+	calendars, err := client.ListCalendars(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list calendars: %w", err)
 	}
@@ -218,7 +1080,7 @@ func runListCalendars(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Printf("  * %s%s\n", cal.Summary, primary)
 		if cfg.Verbose {
-			fmt.Printf("    ID: %s\n", cal.Id)
+			fmt.Printf("    ID: %s\n", cal.ID)
 		}
 	}
 
@@ -226,7 +1088,7 @@ func runListCalendars(cmd *cobra.Command, args []string) error {
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
-	parser, err := templates.NewParser(cfg.Timezone)
+	parser, err := newParser()
 	if err != nil {
 		return fmt.Errorf("failed to create parser: %w", err)
 	}
@@ -236,6 +1098,7 @@ func runExport(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
+	printWarnings(parser)
 
 	fmt.Printf("Found %d events in template\n", len(events))
 
@@ -253,3 +1116,32 @@ func runExport(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// newParser creates a template parser for the configured timezone, CSV columns, variables and strictness
+func newParser() (*templates.Parser, error) {
+	parser, err := templates.NewParser(cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	parser.CSVColumns = csvColumns
+	parser.Vars = templateVars
+	parser.Strict = strictParsing
+	return parser, nil
+}
+
+// parseSwitch reads on/off flag values such as on, off, true or false
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %q", value)
+}
+
+// printWarnings reports problems the parser found in the template
+func printWarnings(parser *templates.Parser) {
+	for _, warning := range parser.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
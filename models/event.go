@@ -1,36 +1,68 @@
 package models
 
-import "time"
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // CalendarEvent represents a unified calendar event structure
 // that can be created from any supported template format
 type CalendarEvent struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description,omitempty"`
-	StartTime   time.Time      `json:"start_time"`
-	EndTime     time.Time      `json:"end_time"`
-	Location    string         `json:"location,omitempty"`
-	Links       []string       `json:"links,omitempty"`
-	AllDay      bool           `json:"all_day,omitempty"`
-	Recurrence  *RecurrenceRule `json:"recurrence,omitempty"`
-	Reminders   []Reminder     `json:"reminders,omitempty"`
-	ColorID     string         `json:"color_id,omitempty"`
+	ID          string            `json:"id,omitempty"` // Optional template-supplied identifier
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	StartTime   time.Time         `json:"start_time"`
+	EndTime     time.Time         `json:"end_time"`
+	Location    string            `json:"location,omitempty"`
+	Links       []string          `json:"links,omitempty"`
+	AllDay      bool              `json:"all_day,omitempty"`
+	Recurrence  *RecurrenceRule   `json:"recurrence,omitempty"`
+	Exceptions  *Exceptions       `json:"exceptions,omitempty"`
+	Reminders   []Reminder        `json:"reminders,omitempty"`
+	ColorID     string            `json:"color_id,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
-// RecurrenceRule defines how an event should repeat
+// RecurrenceRule defines how an event should repeat, following RFC 5545
 type RecurrenceRule struct {
 	Frequency       string     `json:"frequency"` // DAILY, WEEKLY, MONTHLY, YEARLY
 	Interval        int        `json:"interval"`  // Every N frequency units
 	Until           *time.Time `json:"until,omitempty"`
-	Count           int        `json:"count,omitempty"`    // Number of occurrences
-	ByDay           []string   `json:"by_day,omitempty"`   // MO, TU, WE, TH, FR, SA, SU
+	Count           int        `json:"count,omitempty"`        // Number of occurrences
+	ByDay           []string   `json:"by_day,omitempty"`       // MO..SU, optionally with an ordinal: 2TU, -1FR
+	ByMonthDay      []int      `json:"by_month_day,omitempty"` // 1 to 31, or -31 to -1 counting from the end
+	ByMonth         []int      `json:"by_month,omitempty"`     // 1 to 12
+	BySetPos        []int      `json:"by_set_pos,omitempty"`   // Picks the Nth occurrence within each period
+	ByYearDay       []int      `json:"by_year_day,omitempty"`  // 1 to 366, or -366 to -1
+	ByWeekNo        []int      `json:"by_week_no,omitempty"`   // ISO week 1 to 53, or -53 to -1
+	WeekStart       string     `json:"week_start,omitempty"`   // WKST: MO..SU
 	ExcludeWeekends bool       `json:"exclude_weekends,omitempty"`
 }
 
+// Exceptions lists the occurrences of a recurring event that differ from its rule
+type Exceptions struct {
+	ExcludeDates []time.Time `json:"exclude_dates,omitempty"` // Occurrence starts to skip (EXDATE)
+	ExtraDates   []time.Time `json:"extra_dates,omitempty"`   // Extra occurrence starts (RDATE)
+	Overrides    []Override  `json:"overrides,omitempty"`
+}
+
+// Override changes a single occurrence of a recurring event
+type Override struct {
+	OriginalStart time.Time `json:"original_start"` // Start of the occurrence as generated by the rule
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Location      string    `json:"location,omitempty"`    // Empty keeps the series location
+	Description   string    `json:"description,omitempty"` // Empty keeps the series description
+}
+
 // Reminder defines when to remind the user about an event
 type Reminder struct {
-	Method  string `json:"method"` // "email" or "popup"
+	Method  string `json:"method"`  // "email" or "popup"
 	Minutes int    `json:"minutes"` // Minutes before event
 }
 
@@ -43,30 +75,232 @@ func (r *RecurrenceRule) ToRRuleString() string {
 	rule := "RRULE:FREQ=" + r.Frequency
 
 	if r.Interval > 1 {
-		rule += ";INTERVAL=" + string(rune(r.Interval+'0'))
+		rule += fmt.Sprintf(";INTERVAL=%d", r.Interval)
 	}
 
 	if r.Until != nil {
-		rule += ";UNTIL=" + r.Until.Format("20060102T150405Z")
+		rule += ";UNTIL=" + r.Until.UTC().Format("20060102T150405Z")
 	}
 
 	if r.Count > 0 {
-		rule += ";COUNT=" + string(rune(r.Count+'0'))
+		rule += fmt.Sprintf(";COUNT=%d", r.Count)
 	}
 
 	if len(r.ByDay) > 0 {
-		rule += ";BYDAY="
-		for i, day := range r.ByDay {
-			if i > 0 {
-				rule += ","
-			}
-			rule += day
-		}
+		rule += ";BYDAY=" + strings.Join(r.ByDay, ",")
+	}
+
+	rule += formatIntList("BYMONTHDAY", r.ByMonthDay)
+	rule += formatIntList("BYMONTH", r.ByMonth)
+	rule += formatIntList("BYYEARDAY", r.ByYearDay)
+	rule += formatIntList("BYWEEKNO", r.ByWeekNo)
+	rule += formatIntList("BYSETPOS", r.BySetPos)
+
+	if r.WeekStart != "" {
+		rule += ";WKST=" + r.WeekStart
 	}
 
 	return rule
 }
 
+// formatIntList formats a numeric RRULE part, or nothing if values is empty
+func formatIntList(name string, values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return ";" + name + "=" + strings.Join(parts, ",")
+}
+
+// weekdays are the RRULE day codes
+var weekdays = map[string]bool{"MO": true, "TU": true, "WE": true, "TH": true, "FR": true, "SA": true, "SU": true}
+
+// ParseWeekday splits a BYDAY value such as "-1FR" into its ordinal (0 if
+// there is none) and day code
+func ParseWeekday(value string) (int, string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 || !weekdays[value[len(value)-2:]] {
+		return 0, "", fmt.Errorf("invalid weekday: %s", value)
+	}
+
+	day := value[len(value)-2:]
+	if len(value) == 2 {
+		return 0, day, nil
+	}
+
+	ordinal, err := strconv.Atoi(value[:len(value)-2])
+	if err != nil || ordinal == 0 {
+		return 0, "", fmt.Errorf("invalid weekday: %s", value)
+	}
+	return ordinal, day, nil
+}
+
+// Validate checks the rule against the constraints of RFC 5545
+func (r *RecurrenceRule) Validate() error {
+	switch r.Frequency {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return fmt.Errorf("invalid frequency: %s", r.Frequency)
+	}
+
+	if r.Interval < 0 {
+		return fmt.Errorf("interval must be positive: %d", r.Interval)
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("count must be positive: %d", r.Count)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return fmt.Errorf("count and until cannot both be set")
+	}
+
+	for _, value := range r.ByDay {
+		ordinal, _, err := ParseWeekday(value)
+		if err != nil {
+			return err
+		}
+		if ordinal == 0 {
+			continue
+		}
+		switch {
+		case r.Frequency != "MONTHLY" && r.Frequency != "YEARLY":
+			return fmt.Errorf("by_day %s: ordinal weekdays need a MONTHLY or YEARLY frequency", value)
+		case r.Frequency == "YEARLY" && len(r.ByWeekNo) > 0:
+			return fmt.Errorf("by_day %s: ordinal weekdays cannot be combined with by_week_no", value)
+		case r.Frequency == "MONTHLY" && (ordinal < -5 || ordinal > 5):
+			return fmt.Errorf("by_day %s: a month has at most 5 of each weekday", value)
+		case ordinal < -53 || ordinal > 53:
+			return fmt.Errorf("by_day %s: a year has at most 53 of each weekday", value)
+		}
+	}
+
+	if err := checkRange("by_month_day", r.ByMonthDay, 31, true); err != nil {
+		return err
+	}
+	if len(r.ByMonthDay) > 0 && r.Frequency == "WEEKLY" {
+		return fmt.Errorf("by_month_day cannot be used with a WEEKLY frequency")
+	}
+
+	if err := checkRange("by_month", r.ByMonth, 12, false); err != nil {
+		return err
+	}
+
+	if err := checkRange("by_year_day", r.ByYearDay, 366, true); err != nil {
+		return err
+	}
+	if len(r.ByYearDay) > 0 && r.Frequency != "YEARLY" {
+		return fmt.Errorf("by_year_day can only be used with a YEARLY frequency")
+	}
+
+	if err := checkRange("by_week_no", r.ByWeekNo, 53, true); err != nil {
+		return err
+	}
+	if len(r.ByWeekNo) > 0 && r.Frequency != "YEARLY" {
+		return fmt.Errorf("by_week_no can only be used with a YEARLY frequency")
+	}
+
+	if err := checkRange("by_set_pos", r.BySetPos, 366, true); err != nil {
+		return err
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByMonth)+len(r.ByYearDay)+len(r.ByWeekNo) == 0 {
+		return fmt.Errorf("by_set_pos needs another by_* rule to select from")
+	}
+
+	if r.WeekStart != "" && !weekdays[r.WeekStart] {
+		return fmt.Errorf("invalid week_start: %s", r.WeekStart)
+	}
+
+	return nil
+}
+
+// checkRange checks that every value is within 1..limit, or -limit..-1 when
+// negative values are allowed
+func checkRange(name string, values []int, limit int, negative bool) error {
+	for _, v := range values {
+		if v == 0 || v > limit || v < -limit || (v < 0 && !negative) {
+			return fmt.Errorf("%s value out of range: %d", name, v)
+		}
+	}
+	return nil
+}
+
+// ParseRRule parses an iCalendar RRULE line (with or without the "RRULE:"
+// prefix) back into a RecurrenceRule
+func ParseRRule(s string) (*RecurrenceRule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	rule := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part: %s", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Frequency = strings.ToUpper(value)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseRRuleTime(value)
+			rule.Until = &until
+		case "BYDAY":
+			rule.ByDay = strings.Split(strings.ToUpper(value), ",")
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(value)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(value)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(value)
+		case "BYYEARDAY":
+			rule.ByYearDay, err = parseIntList(value)
+		case "BYWEEKNO":
+			rule.ByWeekNo, err = parseIntList(value)
+		case "WKST":
+			rule.WeekStart = strings.ToUpper(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %s: %w", name, err)
+		}
+	}
+
+	if rule.Frequency == "" {
+		return nil, fmt.Errorf("RRULE is missing FREQ: %s", s)
+	}
+
+	return rule, nil
+}
+
+// parseIntList parses a comma separated list of integers
+func parseIntList(value string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parseRRuleTime parses the UTC, floating and date-only forms allowed in UNTIL
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", value)
+}
+
 // FormatDescription creates a formatted event description with links
 func (e *CalendarEvent) FormatDescription() string {
 	desc := e.Description
@@ -83,3 +317,60 @@ func (e *CalendarEvent) FormatDescription() string {
 
 	return desc
 }
+
+// StableKey returns a deterministic identifier for the event that stays the
+// same across runs. It is derived from the name and start time, so editing
+// any other field keeps the event matched. Events with an explicit ID are
+// keyed on it, so they keep matching even after their name or start is edited.
+func (e *CalendarEvent) StableKey() string {
+	data := fmt.Sprintf("%s-%s", e.Name, e.StartTime.String())
+	if e.ID != "" {
+		data = "id:" + e.ID
+	}
+	hash := sha1.Sum([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// LegacyStableKey returns the key events without an ID were tagged with before
+// StableKey stopped including the description, so those events still match
+func (e *CalendarEvent) LegacyStableKey() string {
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s-%s-%s", e.Name, e.StartTime.String(), e.Description)))
+	return hex.EncodeToString(hash[:])
+}
+
+// ContentHash returns a hash of every field of the event, used to detect
+// whether a previously created event needs to be updated
+func (e *CalendarEvent) ContentHash() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		// Fall back to the stable key so callers always get a usable value
+		return e.StableKey()
+	}
+	hash := sha1.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// ParseDescription splits a description produced by FormatDescription back
+// into the plain description and its links
+func ParseDescription(desc string) (string, []string) {
+	const header = "Useful Links:\n"
+
+	idx := strings.LastIndex(desc, header)
+	if idx < 0 || (idx > 0 && !strings.HasSuffix(desc[:idx], "\n\n")) {
+		return desc, nil
+	}
+
+	var links []string
+	for _, line := range strings.Split(desc[idx+len(header):], "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "- ") {
+			// Not a list we generated, keep the description intact
+			return desc, nil
+		}
+		links = append(links, strings.TrimPrefix(line, "- "))
+	}
+
+	return strings.TrimSuffix(desc[:idx], "\n\n"), links
+}
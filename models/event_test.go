@@ -0,0 +1,120 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRRuleRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // Empty if the same as in
+	}{
+		{in: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{in: "RRULE:FREQ=DAILY;INTERVAL=2;UNTIL=20270331T235959Z"},
+		{in: "RRULE:FREQ=MONTHLY;COUNT=6;BYDAY=2TU"},
+		{in: "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{in: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{in: "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15,-31"},
+		{in: "RRULE:FREQ=YEARLY;BYDAY=-1FR;BYMONTH=3,9"},
+		{in: "RRULE:FREQ=YEARLY;BYYEARDAY=1,100,-1"},
+		{in: "RRULE:FREQ=YEARLY;BYDAY=MO;BYWEEKNO=20,-1;WKST=SU"},
+		{in: "RRULE:FREQ=YEARLY;BYDAY=53MO"},
+		{in: "RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=TU", want: "RRULE:FREQ=WEEKLY;BYDAY=TU"},
+		{in: "freq=weekly;byday=mo,fr;wkst=su", want: "RRULE:FREQ=WEEKLY;BYDAY=MO,FR;WKST=SU"},
+		{in: "FREQ=YEARLY;BYSETPOS=2;BYMONTH=6;BYDAY=SA,SU", want: "RRULE:FREQ=YEARLY;BYDAY=SA,SU;BYMONTH=6;BYSETPOS=2"},
+		{in: "RRULE:FREQ=DAILY;UNTIL=20270331", want: "RRULE:FREQ=DAILY;UNTIL=20270331T000000Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			rule, err := ParseRRule(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rule.Validate(); err != nil {
+				t.Fatalf("valid rule rejected: %v", err)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = tt.in
+			}
+			if got := rule.ToRRuleString(); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, in := range []string{
+		"BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY",
+		"FREQ=DAILY;COUNT=ten",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=MONTHLY;BYMONTHDAY=1,last",
+	} {
+		if _, err := ParseRRule(in); err == nil {
+			t.Errorf("%s: got no error", in)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{"FREQ=HOURLY", "invalid frequency"},
+		{"FREQ=DAILY;INTERVAL=-1", "interval"},
+		{"FREQ=DAILY;COUNT=-3", "count"},
+		{"FREQ=DAILY;COUNT=5;UNTIL=20270331T235959Z", "count and until"},
+
+		{"FREQ=WEEKLY;BYDAY=XX", "invalid weekday"},
+		{"FREQ=WEEKLY;BYDAY=0MO", "invalid weekday"},
+		{"FREQ=WEEKLY;BYDAY=2TU", "MONTHLY or YEARLY"},
+		{"FREQ=MONTHLY;BYDAY=6MO", "at most 5"},
+		{"FREQ=YEARLY;BYDAY=54MO", "at most 53"},
+		{"FREQ=YEARLY;BYDAY=1MO;BYWEEKNO=10", "by_week_no"},
+
+		{"FREQ=MONTHLY;BYMONTHDAY=0", "by_month_day"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "by_month_day"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-32", "by_month_day"},
+		{"FREQ=WEEKLY;BYMONTHDAY=-1", "WEEKLY"},
+
+		{"FREQ=YEARLY;BYMONTH=13", "by_month"},
+		{"FREQ=YEARLY;BYMONTH=-1", "by_month"},
+
+		{"FREQ=YEARLY;BYYEARDAY=0", "by_year_day"},
+		{"FREQ=YEARLY;BYYEARDAY=-367", "by_year_day"},
+		{"FREQ=MONTHLY;BYYEARDAY=100", "YEARLY"},
+
+		{"FREQ=YEARLY;BYWEEKNO=54", "by_week_no"},
+		{"FREQ=YEARLY;BYWEEKNO=0", "by_week_no"},
+		{"FREQ=MONTHLY;BYWEEKNO=1", "YEARLY"},
+
+		{"FREQ=MONTHLY;BYSETPOS=1", "another by_* rule"},
+		{"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=0", "by_set_pos"},
+		{"FREQ=YEARLY;BYDAY=MO;BYSETPOS=367", "by_set_pos"},
+
+		{"FREQ=WEEKLY;WKST=XX", "week_start"},
+		{"FREQ=WEEKLY;WKST=MONDAY", "week_start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = rule.Validate()
+			if err == nil {
+				t.Fatalf("got no error, want one about %s", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %q, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+// Package recurrence expands recurring events into their concrete occurrences
+// following RFC 5545, so rules can be previewed, checked and flattened locally.
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// Limit caps the number of occurrences generated for a rule without COUNT or
+// UNTIL when no end of the window is given
+const Limit = 1000
+
+// maxEmptyPeriods stops rules that can never match, such as February 30th
+const maxEmptyPeriods = 10000
+
+// Occurrence is a single instance of an event
+type Occurrence struct {
+	Start         time.Time
+	End           time.Time
+	OriginalStart time.Time // Start generated by the rule; differs from Start for moved occurrences
+	Name          string
+	Location      string
+	Description   string
+	Overridden    bool // Changed by an override
+	Extra         bool // Added by extra_dates rather than the rule
+}
+
+// Expand returns the occurrences of an event that overlap [from, to), in start
+// order. A zero from or to leaves that side of the window open. Events without
+// a recurrence rule have a single occurrence.
+func Expand(event *models.CalendarEvent, from, to time.Time) ([]Occurrence, error) {
+	duration := event.EndTime.Sub(event.StartTime)
+
+	starts := []time.Time{event.StartTime}
+	if event.Recurrence != nil {
+		var err error
+		if starts, err = Starts(event.Recurrence, event.StartTime, event.AllDay, to); err != nil {
+			return nil, err
+		}
+	}
+
+	occurrences := make([]Occurrence, 0, len(starts))
+	for _, start := range starts {
+		occurrences = append(occurrences, Occurrence{
+			Start:         start,
+			End:           start.Add(duration),
+			OriginalStart: start,
+			Name:          event.Name,
+			Location:      event.Location,
+			Description:   event.Description,
+		})
+	}
+
+	if x := event.Exceptions; x != nil {
+		occurrences = applyExceptions(occurrences, x, event, duration)
+	}
+
+	var result []Occurrence
+	for _, o := range occurrences {
+		if (from.IsZero() || o.End.After(from) || o.Start.Equal(from)) && (to.IsZero() || o.Start.Before(to)) {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+// applyExceptions removes excluded occurrences, adds extra ones and applies overrides
+func applyExceptions(occurrences []Occurrence, x *models.Exceptions, event *models.CalendarEvent, duration time.Duration) []Occurrence {
+	same := func(a, b time.Time) bool {
+		if event.AllDay {
+			return a.Format("2006-01-02") == b.Format("2006-01-02")
+		}
+		return a.Equal(b)
+	}
+
+	var kept []Occurrence
+	for _, o := range occurrences {
+		excluded := false
+		for _, t := range x.ExcludeDates {
+			if same(o.Start, t) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, o)
+		}
+	}
+
+	for _, t := range x.ExtraDates {
+		kept = append(kept, Occurrence{
+			Start:         t,
+			End:           t.Add(duration),
+			OriginalStart: t,
+			Name:          event.Name,
+			Location:      event.Location,
+			Description:   event.Description,
+			Extra:         true,
+		})
+	}
+
+	for _, override := range x.Overrides {
+		for i := range kept {
+			if !same(kept[i].OriginalStart, override.OriginalStart) {
+				continue
+			}
+			kept[i].Start = override.StartTime
+			kept[i].End = override.EndTime
+			if override.Location != "" {
+				kept[i].Location = override.Location
+			}
+			if override.Description != "" {
+				kept[i].Description = override.Description
+			}
+			kept[i].Overridden = true
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Start.Before(kept[j].Start) })
+	return kept
+}
+
+// Starts returns the start times generated by a rule anchored at dtstart, which
+// is always the first occurrence. Generation stops at COUNT, UNTIL, the first
+// start at or after stop if stop is not zero, or after Limit occurrences when
+// the rule and stop leave it unbounded. Times keep dtstart's wall clock time in
+// its location, so occurrences stay at the same local time across DST changes.
+func Starts(rule *models.RecurrenceRule, dtstart time.Time, allDay bool, stop time.Time) ([]time.Time, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	r, err := newExpander(rule, dtstart)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Time{}
+	if rule.Until != nil {
+		until = *rule.Until
+		if allDay {
+			// A date-only UNTIL includes the whole day
+			y, m, d := until.Date()
+			until = time.Date(y, m, d, 23, 59, 59, 0, dtstart.Location())
+		}
+	}
+
+	starts := []time.Time{dtstart}
+	done := func(t time.Time) bool {
+		switch {
+		case rule.Count > 0 && len(starts) >= rule.Count:
+			return true
+		case !until.IsZero() && t.After(until):
+			return true
+		case !stop.IsZero() && !t.Before(stop):
+			return true
+		case rule.Count == 0 && until.IsZero() && stop.IsZero() && len(starts) >= Limit:
+			return true
+		}
+		return false
+	}
+	empty := 0
+	for period := 0; empty < maxEmptyPeriods; period++ {
+		days := r.period(period)
+		if len(days) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+
+		for _, day := range days {
+			t := time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+			if !t.After(dtstart) {
+				continue
+			}
+			if done(t) {
+				return starts, nil
+			}
+			starts = append(starts, t)
+		}
+	}
+
+	return starts, nil
+}
+
+// expander generates the candidate days of each period of a rule
+type expander struct {
+	rule      *models.RecurrenceRule
+	dtstart   time.Time
+	weekStart time.Weekday
+	byDay     []weekday
+	monthDays []int
+	months    []int
+}
+
+// weekday is a BYDAY entry; n is its ordinal, 0 for every such day
+type weekday struct {
+	n   int
+	day time.Weekday
+}
+
+var dayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func newExpander(rule *models.RecurrenceRule, dtstart time.Time) (*expander, error) {
+	r := &expander{
+		rule:      rule,
+		dtstart:   dtstart,
+		weekStart: time.Monday,
+		monthDays: rule.ByMonthDay,
+		months:    rule.ByMonth,
+	}
+	if rule.WeekStart != "" {
+		r.weekStart = dayCodes[rule.WeekStart]
+	}
+
+	for _, value := range rule.ByDay {
+		n, code, err := models.ParseWeekday(value)
+		if err != nil {
+			return nil, err
+		}
+		r.byDay = append(r.byDay, weekday{n: n, day: dayCodes[code]})
+	}
+
+	// Without any day selection the rule repeats on the day of dtstart
+	if len(r.byDay)+len(rule.ByMonthDay)+len(rule.ByYearDay)+len(rule.ByWeekNo) == 0 {
+		switch rule.Frequency {
+		case "WEEKLY":
+			r.byDay = []weekday{{day: dtstart.Weekday()}}
+		case "MONTHLY":
+			r.monthDays = []int{dtstart.Day()}
+		case "YEARLY":
+			r.monthDays = []int{dtstart.Day()}
+			if len(r.months) == 0 {
+				r.months = []int{int(dtstart.Month())}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// period returns the matching days of the nth period after the one holding
+// dtstart, counted in steps of INTERVAL
+func (r *expander) period(n int) []time.Time {
+	interval := max(1, r.rule.Interval)
+	y, m, d := r.dtstart.Date()
+	loc := r.dtstart.Location()
+
+	var first, last time.Time
+	switch r.rule.Frequency {
+	case "DAILY":
+		first = time.Date(y, m, d+n*interval, 12, 0, 0, 0, loc)
+		last = first
+	case "WEEKLY":
+		offset := (int(r.dtstart.Weekday()) - int(r.weekStart) + 7) % 7
+		first = time.Date(y, m, d-offset+7*n*interval, 12, 0, 0, 0, loc)
+		last = first.AddDate(0, 0, 6)
+	case "MONTHLY":
+		first = time.Date(y, m+time.Month(n*interval), 1, 12, 0, 0, 0, loc)
+		last = first.AddDate(0, 1, -1)
+	default: // YEARLY
+		first = time.Date(y+n*interval, 1, 1, 12, 0, 0, 0, loc)
+		last = time.Date(y+n*interval, 12, 31, 12, 0, 0, 0, loc)
+	}
+
+	var days []time.Time
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if r.matches(day) {
+			days = append(days, day)
+		}
+	}
+
+	return r.setPos(days)
+}
+
+// matches reports whether a day satisfies every BYxxx part of the rule
+func (r *expander) matches(day time.Time) bool {
+	if len(r.months) > 0 && !contains(r.months, int(day.Month())) {
+		return false
+	}
+
+	if len(r.rule.ByWeekNo) > 0 {
+		week, weeks := weekNumber(day, r.weekStart)
+		if !matchesIndex(r.rule.ByWeekNo, week, weeks) {
+			return false
+		}
+	}
+
+	if len(r.rule.ByYearDay) > 0 {
+		days := time.Date(day.Year(), 12, 31, 12, 0, 0, 0, day.Location()).YearDay()
+		if !matchesIndex(r.rule.ByYearDay, day.YearDay(), days) {
+			return false
+		}
+	}
+
+	if len(r.monthDays) > 0 {
+		days := time.Date(day.Year(), day.Month()+1, 0, 12, 0, 0, 0, day.Location()).Day()
+		if !matchesIndex(r.monthDays, day.Day(), days) {
+			return false
+		}
+	}
+
+	if len(r.byDay) > 0 && !r.matchesWeekday(day) {
+		return false
+	}
+
+	return true
+}
+
+// matchesWeekday checks BYDAY. Ordinals count within the month for MONTHLY
+// rules and YEARLY rules with BYMONTH, and within the year otherwise.
+func (r *expander) matchesWeekday(day time.Time) bool {
+	for _, wd := range r.byDay {
+		if wd.day != day.Weekday() {
+			continue
+		}
+		if wd.n == 0 {
+			return true
+		}
+
+		var nth, total int
+		if r.rule.Frequency == "MONTHLY" || len(r.months) > 0 {
+			days := time.Date(day.Year(), day.Month()+1, 0, 12, 0, 0, 0, day.Location()).Day()
+			nth = (day.Day()-1)/7 + 1
+			total = nth + (days-day.Day())/7
+		} else {
+			days := time.Date(day.Year(), 12, 31, 12, 0, 0, 0, day.Location()).YearDay()
+			nth = (day.YearDay()-1)/7 + 1
+			total = nth + (days-day.YearDay())/7
+		}
+
+		if wd.n == nth || (wd.n < 0 && total+wd.n+1 == nth) {
+			return true
+		}
+	}
+	return false
+}
+
+// setPos applies BYSETPOS to the sorted days of a period
+func (r *expander) setPos(days []time.Time) []time.Time {
+	if len(r.rule.BySetPos) == 0 {
+		return days
+	}
+
+	var picked []time.Time
+	for i, day := range days {
+		if matchesIndex(r.rule.BySetPos, i+1, len(days)) {
+			picked = append(picked, day)
+		}
+	}
+	return picked
+}
+
+// weekNumber returns the RFC 5545 week number of a day, where week 1 is the
+// first week with at least four days in the year, and the number of weeks in
+// the year the week belongs to
+func weekNumber(day time.Time, weekStart time.Weekday) (int, int) {
+	year := day.Year()
+	start := firstWeek(year, weekStart, day.Location())
+	if day.Before(start) {
+		year--
+		start = firstWeek(year, weekStart, day.Location())
+	} else if next := firstWeek(year+1, weekStart, day.Location()); !day.Before(next) {
+		year++
+		start = next
+	}
+
+	weeks := int(firstWeek(year+1, weekStart, day.Location()).Sub(start).Hours()/24+0.5) / 7
+	week := int(day.Sub(start).Hours()/24+0.5)/7 + 1
+	return week, weeks
+}
+
+// firstWeek returns noon on the first day of week 1 of a year
+func firstWeek(year int, weekStart time.Weekday, loc *time.Location) time.Time {
+	jan1 := time.Date(year, 1, 1, 12, 0, 0, 0, loc)
+	offset := (int(jan1.Weekday()) - int(weekStart) + 7) % 7
+	if offset <= 3 {
+		return jan1.AddDate(0, 0, -offset)
+	}
+	return jan1.AddDate(0, 0, 7-offset)
+}
+
+// matchesIndex reports whether a 1-based index, out of total, is listed in
+// values, where negative values count from the end
+func matchesIndex(values []int, index, total int) bool {
+	for _, v := range values {
+		if v == index || (v < 0 && total+v+1 == index) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe summarises an occurrence's relation to its rule for display
+func (o Occurrence) Describe() string {
+	switch {
+	case o.Extra:
+		return "extra"
+	case o.Overridden && !o.Start.Equal(o.OriginalStart):
+		return fmt.Sprintf("moved from %s", o.OriginalStart.Format("Jan 2 3:04 PM"))
+	case o.Overridden:
+		return "changed"
+	}
+	return ""
+}
@@ -0,0 +1,332 @@
+package recurrence
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// days formats the dates of times as YYYYMMDD
+func days(times []time.Time) []string {
+	out := make([]string, len(times))
+	for i, t := range times {
+		out[i] = t.Format("20060102")
+	}
+	return out
+}
+
+// TestStartsRFC5545 checks the recurrence examples of RFC 5545 section
+// 3.8.5.3, all at 9:00 AM in New York. Rules without an end are compared on
+// their first occurrences.
+func TestStartsRFC5545(t *testing.T) {
+	ny := newYork(t)
+	tests := []struct {
+		name    string
+		dtstart string
+		rule    string
+		want    []string
+	}{
+		{"daily for 10 occurrences", "19970902", "FREQ=DAILY;COUNT=10",
+			[]string{"19970902", "19970903", "19970904", "19970905", "19970906", "19970907", "19970908", "19970909", "19970910", "19970911"}},
+		{"every other day", "19970902", "FREQ=DAILY;INTERVAL=2",
+			[]string{"19970902", "19970904", "19970906", "19970908"}},
+		{"every 10 days, 5 occurrences", "19970902", "FREQ=DAILY;INTERVAL=10;COUNT=5",
+			[]string{"19970902", "19970912", "19970922", "19971002", "19971012"}},
+		{"weekly for 10 occurrences", "19970902", "FREQ=WEEKLY;COUNT=10",
+			[]string{"19970902", "19970909", "19970916", "19970923", "19970930", "19971007", "19971014", "19971021", "19971028", "19971104"}},
+		{"weekly on Tuesday and Thursday for five weeks", "19970902", "FREQ=WEEKLY;UNTIL=19971007T000000Z;WKST=SU;BYDAY=TU,TH",
+			[]string{"19970902", "19970904", "19970909", "19970911", "19970916", "19970918", "19970923", "19970925", "19970930", "19971002"}},
+		{"every other week on Monday, Wednesday and Friday", "19970901", "FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;WKST=SU;BYDAY=MO,WE,FR",
+			[]string{"19970901", "19970903", "19970905", "19970915", "19970917", "19970919", "19970929",
+				"19971001", "19971003", "19971013", "19971015", "19971017", "19971027", "19971029", "19971031",
+				"19971110", "19971112", "19971114", "19971124", "19971126", "19971128",
+				"19971208", "19971210", "19971212", "19971222"}},
+		{"monthly on the first Friday", "19970905", "FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			[]string{"19970905", "19971003", "19971107", "19971205", "19980102", "19980206", "19980306", "19980403", "19980501", "19980605"}},
+		{"every other month on the first and last Sunday", "19970907", "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			[]string{"19970907", "19970928", "19971102", "19971130", "19980104", "19980125", "19980301", "19980329", "19980503", "19980531"}},
+		{"monthly on the second-to-last Monday", "19970922", "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			[]string{"19970922", "19971020", "19971117", "19971222", "19980119", "19980216"}},
+		{"monthly on the third-to-the-last day", "19970928", "FREQ=MONTHLY;BYMONTHDAY=-3",
+			[]string{"19970928", "19971029", "19971128", "19971229", "19980129", "19980226"}},
+		{"monthly on the 2nd and 15th", "19970902", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=2,15",
+			[]string{"19970902", "19970915", "19971002", "19971015", "19971102", "19971115", "19971202", "19971215", "19980102", "19980115"}},
+		{"yearly in June and July", "19970610", "FREQ=YEARLY;COUNT=10;BYMONTH=6,7",
+			[]string{"19970610", "19970710", "19980610", "19980710", "19990610", "19990710", "20000610", "20000710", "20010610", "20010710"}},
+		{"every third year on the 1st, 100th and 200th day", "19970101", "FREQ=YEARLY;INTERVAL=3;COUNT=10;BYYEARDAY=1,100,200",
+			[]string{"19970101", "19970410", "19970719", "20000101", "20000409", "20000718", "20030101", "20030410", "20030719", "20060101"}},
+		{"every 20th Monday of the year", "19970519", "FREQ=YEARLY;BYDAY=20MO",
+			[]string{"19970519", "19980518", "19990517"}},
+		{"Monday of week 20", "19970512", "FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO",
+			[]string{"19970512", "19980511", "19990517"}},
+		{"every Thursday in March", "19970313", "FREQ=YEARLY;BYMONTH=3;BYDAY=TH",
+			[]string{"19970313", "19970320", "19970327", "19980305", "19980312", "19980319", "19980326", "19990304"}},
+		{"third Tuesday, Wednesday or Thursday of the month", "19970904", "FREQ=MONTHLY;COUNT=3;BYDAY=TU,WE,TH;BYSETPOS=3",
+			[]string{"19970904", "19971007", "19971106"}},
+		{"second-to-last weekday of the month", "19970929", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-2",
+			[]string{"19970929", "19971030", "19971127", "19971230", "19980129", "19980226", "19980330"}},
+		{"week starting on Monday", "19970805", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			[]string{"19970805", "19970810", "19970819", "19970824"}},
+		{"week starting on Sunday", "19970805", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			[]string{"19970805", "19970817", "19970819", "19970831"}},
+		{"invalid dates are skipped", "20070115", "FREQ=MONTHLY;BYMONTHDAY=15,30;COUNT=5",
+			[]string{"20070115", "20070130", "20070215", "20070315", "20070330"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := models.ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			day, err := time.ParseInLocation("20060102", tt.dtstart, ny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dtstart := day.Add(9 * time.Hour)
+
+			starts, err := Starts(rule, dtstart, false, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rule.Count == 0 && rule.Until == nil && len(starts) > len(tt.want) {
+				starts = starts[:len(tt.want)]
+			}
+			if got := days(starts); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got  %v\nwant %v", got, tt.want)
+			}
+			for _, s := range starts {
+				if s.Hour() != 9 || s.Minute() != 0 || s.Location() != ny {
+					t.Errorf("%s is not at 9:00 AM in New York", s)
+				}
+			}
+		})
+	}
+}
+
+func TestStartsAcrossDST(t *testing.T) {
+	ny := newYork(t)
+
+	// Daily at 9:00 AM until Dec 24, 1997 spans the end of daylight saving
+	// time on Oct 26 without moving the local time
+	rule, err := models.ParseRRule("FREQ=DAILY;UNTIL=19971224T000000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	starts, err := Starts(rule, time.Date(1997, 9, 2, 9, 0, 0, 0, ny), false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 113 {
+		t.Fatalf("got %d occurrences, want 113", len(starts))
+	}
+	if last := starts[len(starts)-1]; last.Format("20060102") != "19971223" {
+		t.Errorf("last occurrence on %s, want Dec 23", last.Format("Jan 2"))
+	}
+
+	for i := 1; i < len(starts); i++ {
+		if starts[i].Hour() != 9 {
+			t.Errorf("%s is not at 9:00 AM", starts[i])
+		}
+		gap := starts[i].Sub(starts[i-1])
+		want := 24 * time.Hour
+		if starts[i].Format("0102") == "1026" {
+			want = 25 * time.Hour // Clocks go back early on Oct 26
+		}
+		if gap != want {
+			t.Errorf("%s is %s after the previous occurrence, want %s", starts[i].Format("Jan 2"), gap, want)
+		}
+	}
+
+	// An event's occurrences keep its length in either offset
+	event := &models.CalendarEvent{
+		Name:       "Standup",
+		StartTime:  time.Date(2027, 3, 13, 9, 0, 0, 0, ny),
+		EndTime:    time.Date(2027, 3, 13, 9, 15, 0, 0, ny),
+		Recurrence: &models.RecurrenceRule{Frequency: "DAILY", Interval: 1, Count: 3},
+	}
+	occurrences, err := Expand(event, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range occurrences {
+		if o.Start.Hour() != 9 || o.End.Sub(o.Start) != 15*time.Minute {
+			t.Errorf("occurrence %s to %s, want 9:00 to 9:15", o.Start, o.End)
+		}
+	}
+	if _, offset := occurrences[2].Start.Zone(); offset != -4*60*60 {
+		t.Errorf("occurrence after the start of daylight saving time has offset %d, want -4h", offset)
+	}
+}
+
+func TestStartsLimit(t *testing.T) {
+	dtstart := time.Date(2027, 1, 1, 9, 0, 0, 0, time.UTC)
+	rule := &models.RecurrenceRule{Frequency: "DAILY", Interval: 1}
+
+	// Without an end or a stop, a rule yields Limit occurrences
+	starts, err := Starts(rule, dtstart, false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != Limit {
+		t.Errorf("got %d occurrences, want %d", len(starts), Limit)
+	}
+
+	// A stop bounds it instead, however many occurrences that is
+	stop := dtstart.AddDate(0, 0, 1500)
+	starts, err = Starts(rule, dtstart, false, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 1500 {
+		t.Errorf("with a stop: got %d occurrences, want 1500", len(starts))
+	}
+
+	// COUNT and UNTIL are not capped
+	rule.Count = 1200
+	starts, err = Starts(rule, dtstart, false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 1200 {
+		t.Errorf("with COUNT=1200: got %d occurrences, want 1200", len(starts))
+	}
+}
+
+func TestStartsEmptyPeriods(t *testing.T) {
+	dtstart := time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)
+
+	// February 30th never comes; generation gives up after maxEmptyPeriods
+	never := &models.RecurrenceRule{Frequency: "YEARLY", Interval: 1, ByMonth: []int{2}, ByMonthDay: []int{30}}
+	starts, err := Starts(never, dtstart, false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 1 || !starts[0].Equal(dtstart) {
+		t.Errorf("got %v, want only the start", starts)
+	}
+
+	// Rare matches are found across empty periods
+	leap := &models.RecurrenceRule{Frequency: "YEARLY", Interval: 1, Count: 3, ByMonth: []int{2}, ByMonthDay: []int{29}}
+	starts, err = Starts(leap, dtstart, false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := days(starts), []string{"20240229", "20280229", "20320229"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpandExceptions(t *testing.T) {
+	ny := newYork(t)
+
+	// Every Friday the 13th, with the start excluded as in RFC 5545
+	start := time.Date(1997, 9, 2, 9, 0, 0, 0, ny)
+	event := &models.CalendarEvent{
+		Name:       "Friday the 13th",
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Recurrence: &models.RecurrenceRule{Frequency: "MONTHLY", Interval: 1, ByDay: []string{"FR"}, ByMonthDay: []int{13}},
+		Exceptions: &models.Exceptions{ExcludeDates: []time.Time{start}},
+	}
+	occurrences, err := Expand(event, time.Time{}, time.Date(2001, 1, 1, 0, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	for _, o := range occurrences {
+		got = append(got, o.Start)
+	}
+	if want := []string{"19980213", "19980313", "19981113", "19990813", "20001013"}; fmt.Sprint(days(got)) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", days(got), want)
+	}
+
+	// Excluded, extra and moved occurrences of a weekly series, in start order
+	start = time.Date(2027, 3, 1, 18, 0, 0, 0, ny)
+	week := func(n int) time.Time { return start.AddDate(0, 0, 7*n) }
+	event = &models.CalendarEvent{
+		Name:       "Study",
+		StartTime:  start,
+		EndTime:    start.Add(2 * time.Hour),
+		Location:   "Library",
+		Recurrence: &models.RecurrenceRule{Frequency: "WEEKLY", Interval: 1, Count: 4},
+		Exceptions: &models.Exceptions{
+			ExcludeDates: []time.Time{week(1)},
+			ExtraDates:   []time.Time{week(1).AddDate(0, 0, 2)},
+			Overrides: []models.Override{{
+				OriginalStart: week(2),
+				StartTime:     week(3).Add(-time.Hour),
+				EndTime:       week(3).Add(time.Hour),
+				Location:      "Room 5",
+			}},
+		},
+	}
+	occurrences, err = Expand(event, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		start    string
+		location string
+		describe string
+	}
+	var gotSummary []summary
+	for _, o := range occurrences {
+		gotSummary = append(gotSummary, summary{o.Start.Format("Jan 2 15:04"), o.Location, o.Describe()})
+	}
+	wantSummary := []summary{
+		{"Mar 1 18:00", "Library", ""},
+		{"Mar 10 18:00", "Library", "extra"},
+		{"Mar 22 17:00", "Room 5", "moved from Mar 15 6:00 PM"},
+		{"Mar 22 18:00", "Library", ""},
+	}
+	if fmt.Sprint(gotSummary) != fmt.Sprint(wantSummary) {
+		t.Errorf("got  %v\nwant %v", gotSummary, wantSummary)
+	}
+
+	// The window applies to the occurrences as changed
+	occurrences, err = Expand(event, week(2), week(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 1 || !occurrences[0].Overridden {
+		t.Errorf("got %d occurrences in the third week, want the moved one", len(occurrences))
+	}
+}
+
+func TestExpandAllDayExclusions(t *testing.T) {
+	// All-day exclusions match on the date, whatever their time of day
+	start := time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+	event := &models.CalendarEvent{
+		Name:       "Holiday camp",
+		StartTime:  start,
+		EndTime:    start.AddDate(0, 0, 1),
+		AllDay:     true,
+		Recurrence: &models.RecurrenceRule{Frequency: "DAILY", Interval: 1, Count: 5},
+		Exceptions: &models.Exceptions{ExcludeDates: []time.Time{start.AddDate(0, 0, 2).Add(15 * time.Hour)}},
+	}
+	occurrences, err := Expand(event, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	for _, o := range occurrences {
+		got = append(got, o.Start)
+	}
+	if want := []string{"20270601", "20270602", "20270604", "20270605"}; fmt.Sprint(days(got)) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", days(got), want)
+	}
+}
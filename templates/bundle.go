@@ -0,0 +1,183 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// BundleTemplate holds events of several formats in one file. Events in the
+// events list are read in the format their fields call for.
+type BundleTemplate struct {
+	Format    string                   `json:"format"`
+	Holidays  *HolidayInput            `json:"holidays,omitempty"`
+	Defaults  *DefaultsInput           `json:"defaults,omitempty"`
+	Vars      VarsInput                `json:"vars,omitempty"`
+	Extends   string                   `json:"extends,omitempty"`
+	Include   []string                 `json:"include,omitempty"`
+	Single    []SingleEventInput       `json:"single,omitempty"`
+	Recurring []RecurringEventInput    `json:"recurring,omitempty"`
+	DateRange []DateRangeEventInput    `json:"daterange,omitempty"`
+	Weeks     map[string][]WeeklyEvent `json:"weeks,omitempty"`
+	Events    []json.RawMessage        `json:"events,omitempty"`
+}
+
+// bundleSections are the keys that mark a template as a bundle
+var bundleSections = []string{"single", "recurring", "daterange", "weeks"}
+
+// eventFormat returns the format an event's fields call for: recurring with a
+// recurrence, daterange with an end date, weekly with an event_name, single otherwise
+func eventFormat(event map[string]json.RawMessage) TemplateFormat {
+	switch {
+	case event["recurrence"] != nil:
+		return FormatRecurring
+	case event["end_date"] != nil:
+		return FormatDateRange
+	case event["event_name"] != nil:
+		return FormatWeekly
+	}
+	return FormatSingle
+}
+
+// eventFormats returns the format of each event in an events list
+func eventFormats(data []byte) []TemplateFormat {
+	var template struct {
+		Events []map[string]json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil
+	}
+
+	formats := make([]TemplateFormat, len(template.Events))
+	for i, event := range template.Events {
+		formats[i] = eventFormat(event)
+	}
+	return formats
+}
+
+// checkEventFormats rejects events with fields the template's format would ignore
+func checkEventFormats(data []byte, format TemplateFormat) error {
+	var template struct {
+		Events []map[string]json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil
+	}
+
+	for i, event := range template.Events {
+		if f := eventFormat(event); f != format && f != FormatSingle {
+			var name string
+			json.Unmarshal(event["name"], &name)
+			return inField(fmt.Sprintf("events[%d]", i), fmt.Errorf("event %d ('%s') looks like a %s event, which the %s format does not read; remove \"format\" or use the bundle format", i+1, name, f, format))
+		}
+	}
+	return nil
+}
+
+// parseBundle parses the bundle format
+func (p *Parser) parseBundle(data []byte) ([]models.CalendarEvent, error) {
+	var template BundleTemplate
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle JSON: %w", err)
+	}
+
+	holidays, err := p.loadHolidays(template.Holidays)
+	if err != nil {
+		return nil, err
+	}
+	values := p.loadValues(template.Defaults, template.Vars)
+
+	var events []models.CalendarEvent
+	add := func(path, name string, event models.CalendarEvent, err error, policy string) {
+		if err == nil {
+			err = values.apply(&event)
+		}
+		keep := false
+		if err == nil {
+			keep, err = p.applyHolidays(holidays, &event, policy)
+		}
+		if err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", name, err))
+		} else if keep {
+			p.track(path, event)
+			events = append(events, event)
+		}
+	}
+
+	for i, se := range template.Single {
+		event, err := p.convertSingleEvent(se)
+		add(fmt.Sprintf("$.single[%d]", i), se.Name, event, err, se.HolidayPolicy)
+	}
+	for i, re := range template.Recurring {
+		event, err := p.convertRecurringEvent(re)
+		add(fmt.Sprintf("$.recurring[%d]", i), re.Name, event, err, re.HolidayPolicy)
+	}
+	for i, dr := range template.DateRange {
+		event, err := p.convertDateRangeEvent(dr)
+		add(fmt.Sprintf("$.daterange[%d]", i), dr.Name, event, err, dr.HolidayPolicy)
+	}
+
+	// Process weeks in order, as in the weekly format
+	weeks := make([]string, 0, len(template.Weeks))
+	for week := range template.Weeks {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	for _, week := range weeks {
+		for i, we := range template.Weeks[week] {
+			event, err := p.convertWeeklyEvent(we)
+			add(fmt.Sprintf("$.weeks.%s[%d]", week, i), we.EventName, event, err, we.HolidayPolicy)
+		}
+	}
+
+	for i, raw := range template.Events {
+		path := fmt.Sprintf("$.events[%d]", i)
+		if err := p.parseBundleEvent(path, raw, add); err != nil {
+			p.fail(path, fmt.Errorf("event %d: %w", i+1, err))
+		}
+	}
+
+	return events, nil
+}
+
+// parseBundleEvent converts an entry of a bundle's events list in the format its fields call for
+func (p *Parser) parseBundleEvent(path string, raw json.RawMessage, add func(string, string, models.CalendarEvent, error, string)) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	switch eventFormat(fields) {
+	case FormatRecurring:
+		var re RecurringEventInput
+		if err := json.Unmarshal(raw, &re); err != nil {
+			return fmt.Errorf("failed to parse recurring event: %w", err)
+		}
+		event, err := p.convertRecurringEvent(re)
+		add(path, re.Name, event, err, re.HolidayPolicy)
+	case FormatDateRange:
+		var dr DateRangeEventInput
+		if err := json.Unmarshal(raw, &dr); err != nil {
+			return fmt.Errorf("failed to parse date range event: %w", err)
+		}
+		event, err := p.convertDateRangeEvent(dr)
+		add(path, dr.Name, event, err, dr.HolidayPolicy)
+	case FormatWeekly:
+		var we WeeklyEvent
+		if err := json.Unmarshal(raw, &we); err != nil {
+			return fmt.Errorf("failed to parse weekly event: %w", err)
+		}
+		event, err := p.convertWeeklyEvent(we)
+		add(path, we.EventName, event, err, we.HolidayPolicy)
+	default:
+		var se SingleEventInput
+		if err := json.Unmarshal(raw, &se); err != nil {
+			return fmt.Errorf("failed to parse single event: %w", err)
+		}
+		event, err := p.convertSingleEvent(se)
+		add(path, se.Name, event, err, se.HolidayPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	recurring := `{"name": "B", "start_time": "09:00", "recurrence": {"frequency": "DAILY"}}`
+	single := `{"name": "A", "date": "2027-03-01", "start_time": "09:00"}`
+	dateRange := `{"name": "C", "start_date": "2027-03-01", "end_date": "2027-03-02"}`
+
+	tests := []struct {
+		data string
+		want TemplateFormat
+	}{
+		{`{"format": "Recurring", "events": [` + single + `]}`, FormatRecurring},
+		{`{"single": [` + single + `]}`, FormatBundle},
+		{`{"weeks": {"week_1": []}}`, FormatBundle},
+		{`{"week_1": [], "week_2": []}`, FormatWeekly},
+		{`{"Week 1": []}`, FormatWeekly},
+		{`{"events": [` + single + `]}`, FormatSingle},
+		{`{"events": [` + recurring + `, ` + recurring + `]}`, FormatRecurring},
+		{`{"events": [` + dateRange + `]}`, FormatDateRange},
+
+		// Detection looks at every event, not only the first
+		{`{"events": [` + single + `, ` + recurring + `]}`, FormatBundle},
+		{`{"events": [` + recurring + `, ` + dateRange + `]}`, FormatBundle},
+
+		{`{"events": []}`, FormatSingle},
+		{`not json`, FormatSingle},
+		{"BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", FormatICS},
+	}
+
+	p := newTestParser(t)
+	for _, tt := range tests {
+		if got := p.detectFormat([]byte(tt.data)); got != tt.want {
+			t.Errorf("got %s, want %s for %s", got, tt.want, tt.data)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	p := newTestParser(t)
+	events := parse(t, p, `{
+		"format": "bundle",
+		"defaults": {"location": "Campus"},
+		"single": [{"name": "Kickoff", "date": "2027-03-01", "start_time": "09:00"}],
+		"recurring": [{"name": "Lecture", "start_date": "2027-03-01", "start_time": "10:00", "recurrence": {"frequency": "WEEKLY", "count": 4}}],
+		"daterange": [{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "all_day": true}],
+		"weeks": {
+			"week_2": [{"event_name": "Lab 2", "date": "2027-03-09", "time": "2:00pm – 3:00pm", "topic_details": ""}],
+			"week_1": [{"event_name": "Lab 1", "date": "2027-03-02", "time": "2:00pm – 3:00pm", "topic_details": ""}]
+		},
+		"events": [
+			{"name": "Guest talk", "date": "2027-03-04", "start_time": "16:00"},
+			{"name": "Standup", "start_date": "2027-03-01", "start_time": "09:30", "recurrence": {"frequency": "DAILY", "count": 5}}
+		]
+	}`)
+
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s/%v/%s", e.Name, e.Recurrence != nil, e.Location))
+	}
+	want := "[Kickoff/false/Campus Lecture/true/Campus Offsite/false/Campus Lab 1/false/Campus Lab 2/false/Campus Guest talk/false/Campus Standup/true/Campus]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestBundleErrors(t *testing.T) {
+	p := newTestParser(t)
+	_, err := p.Parse([]byte(`{
+		"single": [{"name": "Kickoff", "date": "someday", "start_time": "09:00"}],
+		"recurring": [{"name": "Lecture", "start_time": "10:00", "recurrence": {"frequency": "HOURLY"}}],
+		"events": [{"name": "Guest talk", "date": "2027-03-04", "start_time": "16:00"}, "not an event"]
+	}`), FormatAuto)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	// Each section's problems are reported at their event
+	var got []string
+	for _, d := range p.Diagnostics {
+		got = append(got, strings.SplitN(d.Path, ".", 3)[1])
+	}
+	if want := "[single[0] recurring[0] events[1]]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestFormatRejectsOtherEvents(t *testing.T) {
+	tests := []struct {
+		data string
+		want string // Empty when the format reads the event
+	}{
+		{
+			`{"format": "single", "events": [
+				{"name": "Kickoff", "date": "2027-03-01", "start_time": "09:00"},
+				{"name": "Lecture", "date": "2027-03-01", "start_time": "10:00", "recurrence": {"frequency": "WEEKLY"}}
+			]}`,
+			`event 2 ('Lecture') looks like a recurring event, which the single format does not read`,
+		},
+		{
+			`{"format": "recurring", "events": [
+				{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "start_time": "09:00", "recurrence": {"frequency": "DAILY"}}
+			]}`,
+			"",
+		},
+		{
+			`{"format": "daterange", "events": [
+				{"name": "Lab", "event_name": "Lab", "start_date": "2027-03-10", "end_date": "2027-03-12"}
+			]}`,
+			"",
+		},
+		{
+			`{"format": "daterange", "events": [
+				{"name": "Lecture", "start_date": "2027-03-10", "start_time": "10:00", "recurrence": {"frequency": "WEEKLY"}}
+			]}`,
+			`event 1 ('Lecture') looks like a recurring event, which the daterange format does not read; remove "format" or use the bundle format`,
+		},
+	}
+
+	for _, tt := range tests {
+		p := newTestParser(t)
+		_, err := p.Parse([]byte(tt.data), FormatAuto)
+		if tt.want == "" {
+			if err != nil && strings.Contains(err.Error(), "looks like") {
+				t.Errorf("got %v, want the event read by the format", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("got %v, want %q", err, tt.want)
+		}
+	}
+}
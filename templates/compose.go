@@ -0,0 +1,150 @@
+package templates
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// compositionInput holds the keys that pull other template files into a template
+type compositionInput struct {
+	Extends  string         `json:"extends,omitempty"`  // Base template whose events this one overrides
+	Include  []string       `json:"include,omitempty"`  // Templates whose events are added
+	Vars     VarsInput      `json:"vars,omitempty"`     // Passed down to the base and included templates
+	Defaults *DefaultsInput `json:"defaults,omitempty"` // Passed down likewise
+}
+
+// compose parses the templates a template extends and includes, and merges
+// their events with its own: the base template's events come first, with
+// those of the same ID, or the same name when the event has no ID, replaced by
+// the template's own event; included events follow, then the template's
+// remaining events.
+func (p *Parser) compose(filename string, data []byte, own func() ([]models.CalendarEvent, error)) ([]models.CalendarEvent, error) {
+	var comp compositionInput
+	if err := json.Unmarshal(data, &comp); err != nil || (comp.Extends == "" && len(comp.Include) == 0) {
+		return own()
+	}
+
+	// Defaults that cannot apply are reported here rather than at every
+	// event of the files they would pass down to
+	var failed error
+	if comp.Defaults != nil {
+		if _, err := comp.Defaults.reminders(); err != nil {
+			p.fail("$.defaults.reminders", err)
+			failed = err
+			comp.Defaults = nil
+		}
+	}
+
+	// Vars and defaults of the template take precedence over those of the
+	// files it pulls in
+	inheritedDefaults := p.inheritedDefaults
+	p.inheritedDefaults = mergeDefaults(comp.Defaults, inheritedDefaults)
+	inherited := p.inherited
+	p.inherited = make(VarsInput, len(comp.Vars)+len(inherited))
+	for name, value := range comp.Vars {
+		p.inherited[name] = value
+	}
+	for name, value := range inherited {
+		p.inherited[name] = value
+	}
+
+	// Parse every template, so that the problems of all of them are
+	// reported; those found before a template could be read are reported at
+	// its reference
+	related := func(path, ref string) []models.CalendarEvent {
+		since := len(p.Diagnostics)
+		events, err := p.parseRelated(filename, ref)
+		if err != nil {
+			if p.errorSince(since) == nil {
+				p.fail(path, err)
+			}
+			failed = cmp.Or(failed, err)
+		}
+		return events
+	}
+
+	var base, included []models.CalendarEvent
+	if comp.Extends != "" {
+		base = related("$.extends", comp.Extends)
+	}
+	for i, include := range comp.Include {
+		included = append(included, related(fmt.Sprintf("$.include[%d]", i), include)...)
+	}
+	p.inherited = inherited
+	p.inheritedDefaults = inheritedDefaults
+
+	events, err := own()
+	if failed = cmp.Or(failed, err); failed != nil {
+		return nil, failed
+	}
+
+	var added []models.CalendarEvent
+	for _, event := range events {
+		i, err := overridden(base, event)
+		if err != nil {
+			return nil, fmt.Errorf("event '%s' overrides %s: %w", event.Name, comp.Extends, err)
+		}
+		if i < 0 {
+			added = append(added, event)
+		} else {
+			base[i] = event
+		}
+	}
+
+	return append(append(base, included...), added...), nil
+}
+
+// overridden returns the index of the base event that event replaces, or -1
+func overridden(base []models.CalendarEvent, event models.CalendarEvent) (int, error) {
+	match := -1
+	for i, b := range base {
+		same := b.Name == event.Name
+		if event.ID != "" {
+			same = b.ID == event.ID
+		}
+		if !same {
+			continue
+		}
+		if match >= 0 {
+			return -1, fmt.Errorf("several events are named '%s'; give the one to replace an id", event.Name)
+		}
+		match = i
+	}
+	return match, nil
+}
+
+// parseRelated parses a template extended or included by filename, with its
+// path relative to filename. Errors name the file they come from.
+func (p *Parser) parseRelated(filename, ref string) ([]models.CalendarEvent, error) {
+	path := ref
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), path)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if i := slices.Index(p.files, abs); i >= 0 {
+		cycle := append(slices.Clone(p.files[i:]), abs)
+		for j := range cycle {
+			cycle[j] = filepath.Base(cycle[j])
+		}
+		return nil, fmt.Errorf("template cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	baseDir := p.baseDir
+	defer func() { p.baseDir = baseDir }()
+
+	events, err := p.parseFile(path, FormatAuto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return events, nil
+}
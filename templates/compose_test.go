@@ -0,0 +1,113 @@
+package templates
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompose(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/semester.json": `{"format": "single", "vars": {"room": "1", "term": "spring"}, "events": [
+			{"name": "Kickoff", "date": "2027-03-01", "start_time": "09:00", "location": "Room {{room}}"},
+			{"id": "exam", "name": "Exam", "date": "2027-06-01", "start_time": "09:00"},
+			{"name": "Party {{term}}", "date": "2027-06-10", "start_time": "18:00"}
+		]}`,
+		"course/labs.yaml": "format: recurring\nevents:\n" +
+			"  - {name: Lab, start_date: 2027-03-02, start_time: \"14:00\", location: \"Room {{room}}\", recurrence: {frequency: WEEKLY, count: 2}}\n",
+		"course/guests.csv": "name,date,start\nGuest talk,2027-04-01,16:00\n",
+		"course.json": `{"format": "single", "extends": "base/semester.json",
+			"include": ["course/labs.yaml", "course/guests.csv"],
+			"vars": {"room": "42"},
+			"events": [
+				{"name": "Kickoff", "date": "2027-03-01", "start_time": "10:00", "location": "Hall {{room}}"},
+				{"id": "exam", "name": "Final exam", "date": "2027-06-02", "start_time": "09:00"},
+				{"name": "Review", "date": "2027-05-20", "start_time": "09:00"}
+			]}`,
+	})
+
+	events, err := newTestParser(t).ParseFile(filepath.Join(dir, "course.json"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Base events in order with the replaced ones in place, then the
+	// included events, then the template's own new events; the template's
+	// vars apply to every file
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s %s %s", e.Name, e.StartTime.Format("Jan 2 15:04"), e.Location))
+	}
+	want := []string{
+		"Kickoff Mar 1 10:00 Hall 42",
+		"Final exam Jun 2 09:00 ",
+		"Party spring Jun 10 18:00 ",
+		"Lab Mar 2 14:00 Room 42",
+		"Guest talk Apr 1 16:00 ",
+		"Review May 20 09:00 ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestComposeCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.json":     `{"format": "single", "extends": "b.yaml", "events": []}`,
+		"b.yaml":     "format: single\ninclude: [sub/c.json]\nevents: []\n",
+		"sub/c.json": `{"format": "single", "extends": "../a.json", "events": []}`,
+		"self.json":  `{"format": "single", "include": ["./self.json"], "events": []}`,
+	})
+
+	for file, want := range map[string]string{
+		"a.json":    "template cycle: a.json -> b.yaml -> c.json -> a.json",
+		"self.json": "template cycle: self.json -> self.json",
+	} {
+		p := newTestParser(t)
+		_, err := p.ParseFile(filepath.Join(dir, file), FormatAuto)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", file, err, want)
+			continue
+		}
+
+		// The cycle is reported once, at the reference that closes it
+		if len(p.Diagnostics) != 1 {
+			t.Fatalf("%s: got %d diagnostics, want 1", file, len(p.Diagnostics))
+		}
+		if d := p.Diagnostics[0]; !strings.HasSuffix(d.File, map[string]string{"a.json": "c.json", "self.json": "self.json"}[file]) {
+			t.Errorf("%s: got the cycle reported in %s", file, d.File)
+		}
+	}
+}
+
+func TestComposeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.json": `{"format": "single", "events": [
+			{"name": "Lab", "date": "2027-03-01", "start_time": "09:00"},
+			{"name": "Lab", "date": "2027-03-02", "start_time": "09:00"}
+		]}`,
+		"broken.json": `{"format": "single", "events": [{"name": "Lab", "date": "2027-03-01", "start_time": "9am-ish"}]}`,
+		"twice.json":  `{"format": "single", "extends": "base.json", "events": [{"name": "Lab", "date": "2027-03-03", "start_time": "09:00"}]}`,
+		"missing.json": `{"format": "single", "extends": "nowhere.json", "include": ["broken.json"],
+			"events": [{"name": "Lab", "date": "2027-03-01", "start_time": "noon-ish"}]}`,
+	})
+
+	_, err := newTestParser(t).ParseFile(filepath.Join(dir, "twice.json"), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), "several events are named 'Lab'; give the one to replace an id") {
+		t.Errorf("got %v, want an error about the ambiguous name", err)
+	}
+
+	// Every file's problems are reported, each in its own file
+	p := newTestParser(t)
+	if _, err := p.ParseFile(filepath.Join(dir, "missing.json"), FormatAuto); err == nil {
+		t.Fatal("got no error")
+	}
+	var got []string
+	for _, d := range p.Diagnostics {
+		got = append(got, filepath.Base(d.File)+" "+d.Path)
+	}
+	if want := "[missing.json $.extends missing.json $.events[0].start_time broken.json $.events[0].start_time]"; fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// csvFields maps each field of a CSV row to the column headers recognised for
+// it by default. Headers are compared lowercased, with spaces and dashes read
+// as underscores.
+var csvFields = map[string][]string{
+	"id":          {"id", "uid"},
+	"name":        {"name", "title", "summary", "subject", "event", "event_name"},
+	"date":        {"date", "start_date", "day"},
+	"end_date":    {"end_date"},
+	"start":       {"start", "start_time", "time", "from"},
+	"end":         {"end", "end_time", "to", "until_time"},
+	"duration":    {"duration", "length"},
+	"all_day":     {"all_day", "allday"},
+	"location":    {"location", "place", "room", "where"},
+	"description": {"description", "notes", "details", "topic_details"},
+	"links":       {"links", "link", "url", "urls", "useful_links"},
+	"color":       {"color", "color_id", "colour"},
+	"recurrence":  {"recurrence", "rrule", "repeat"},
+}
+
+// CSVFields returns the names of the fields a CSV column can be mapped to
+func CSVFields() []string {
+	fields := make([]string, 0, len(csvFields))
+	for field := range csvFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// csvUntilDate matches an RRULE UNTIL given as a date only
+var csvUntilDate = regexp.MustCompile(`(?i)UNTIL=\d{8}(;|$)`)
+
+// parseCSV parses a spreadsheet export with a header row. Each row is a single
+// event, a date range when it has an end date, and recurring when it has a
+// recurrence. Columns are matched to fields by header name, or by p.CSVColumns.
+// Variables from p.Vars are interpolated as in other templates.
+func (p *Parser) parseCSV(data []byte) ([]models.CalendarEvent, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("failed to parse CSV: missing header row")
+		}
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	columns, err := p.csvColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV has no name column (headers: %s); use --map name=<column>", strings.Join(header, ", "))
+	}
+	if _, ok := columns["date"]; !ok {
+		return nil, fmt.Errorf("CSV has no date column (headers: %s); use --map date=<column>", strings.Join(header, ", "))
+	}
+
+	values := p.loadValues(nil, nil)
+	var events []models.CalendarEvent
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := make(map[string]string, len(columns))
+		for field, index := range columns {
+			if index < len(record) {
+				row[field] = strings.TrimSpace(record[index])
+			}
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		if p.Lint[RuleEndBeforeStart] && row["end_date"] == "" && row["start"] != "" && row["end"] != "" && p.rollsOver(row["start"], row["end"]) {
+			p.reportRollover(Diagnostic{Line: line, Column: 1}, row["end"], row["start"])
+		}
+
+		event, err := p.convertCSVRow(row)
+		if err != nil {
+			p.failLine(line, fmt.Errorf("failed to convert event '%s': %w", row["name"], err))
+			continue
+		}
+		if err := values.apply(&event); err != nil {
+			p.failLine(line, fmt.Errorf("failed to convert event '%s': %w", row["name"], err))
+			continue
+		}
+		p.trackLine(line, event)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// csvColumns maps fields to column indexes, applying p.CSVColumns first.
+// Columns that match no field are reported as warnings.
+func (p *Parser) csvColumns(header []string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[normalizeHeader(h)] = i
+	}
+
+	columns := make(map[string]int)
+	used := make(map[int]bool)
+	for field, column := range p.CSVColumns {
+		field = normalizeHeader(field)
+		if _, ok := csvFields[field]; !ok {
+			return nil, fmt.Errorf("unknown CSV field %q in column mapping (expected one of: %s)", field, strings.Join(CSVFields(), ", "))
+		}
+		i, ok := index[normalizeHeader(column)]
+		if !ok {
+			return nil, fmt.Errorf("column %q mapped to %s is not in the CSV header", column, field)
+		}
+		columns[field] = i
+		used[i] = true
+	}
+
+	for field, aliases := range csvFields {
+		if _, ok := columns[field]; ok {
+			continue
+		}
+		for _, alias := range aliases {
+			if i, ok := index[alias]; ok && !used[i] {
+				columns[field] = i
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	for i, h := range header {
+		if !used[i] && strings.TrimSpace(h) != "" {
+			p.warnf("CSV column %q is not used", h)
+		}
+	}
+	return columns, nil
+}
+
+// normalizeHeader lowercases a header and turns spaces and dashes into underscores
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+}
+
+// convertCSVRow converts a CSV row, keyed by field, to a CalendarEvent
+func (p *Parser) convertCSVRow(row map[string]string) (models.CalendarEvent, error) {
+	if row["name"] == "" {
+		return models.CalendarEvent{}, fmt.Errorf("missing name")
+	}
+
+	allDay := false
+	switch strings.ToLower(row["all_day"]) {
+	case "", "false", "no", "n", "0":
+	case "true", "yes", "y", "1", "x":
+		allDay = true
+	default:
+		return models.CalendarEvent{}, fmt.Errorf("invalid all_day value: %s", row["all_day"])
+	}
+
+	var links []string
+	if row["links"] != "" {
+		links = strings.FieldsFunc(row["links"], func(r rune) bool {
+			return r == ' ' || r == '\n' || r == ';' || r == '|'
+		})
+	}
+
+	var event models.CalendarEvent
+	var err error
+	if row["end_date"] != "" {
+		event, err = p.convertDateRangeEvent(DateRangeEventInput{
+			ID:          row["id"],
+			Name:        row["name"],
+			StartDate:   row["date"],
+			EndDate:     row["end_date"],
+			StartTime:   row["start"],
+			EndTime:     row["end"],
+			AllDay:      allDay,
+			Description: row["description"],
+			Location:    row["location"],
+			Links:       links,
+			ColorID:     row["color"],
+		})
+	} else {
+		event, err = p.convertSingleEvent(SingleEventInput{
+			ID:          row["id"],
+			Name:        row["name"],
+			Date:        row["date"],
+			StartTime:   row["start"],
+			EndTime:     row["end"],
+			Duration:    row["duration"],
+			Description: row["description"],
+			Location:    row["location"],
+			Links:       links,
+			AllDay:      allDay || row["start"] == "",
+			ColorID:     row["color"],
+		})
+	}
+	if err != nil {
+		return models.CalendarEvent{}, err
+	}
+
+	if row["recurrence"] != "" {
+		if event.Recurrence, err = p.parseCSVRecurrence(row["recurrence"]); err != nil {
+			return models.CalendarEvent{}, fmt.Errorf("failed to parse recurrence rule: %w", err)
+		}
+	}
+
+	return event, nil
+}
+
+// parseCSVRecurrence parses an RRULE such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10",
+// or just a frequency such as "weekly". A date-only UNTIL includes that whole
+// day, as in JSON templates.
+func (p *Parser) parseCSVRecurrence(value string) (*models.RecurrenceRule, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "=") {
+		value = "FREQ=" + value
+	}
+
+	rule, err := models.ParseRRule(strings.ToUpper(value))
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.Until != nil && csvUntilDate.MatchString(value) {
+		until := time.Date(rule.Until.Year(), rule.Until.Month(), rule.Until.Day(), 23, 59, 59, 0, p.TimeParser.Location)
+		rule.Until = &until
+	}
+
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSV(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"schedule.csv": "\xef\xbb\xbf" + strings.Join([]string{
+		"Title,Day,End Date,From,To,Length,Room,Notes,Links,Colour,Repeat",
+		"Kickoff,2027-03-01,,09:00,10:30,,Hall A,First day,https://example.com/a; https://example.com/b,5,",
+		"Reading week,2027-03-08,,,,,,,,,",
+		"",
+		"Retreat,2027-03-10,2027-03-12,09:00,17:00,,,,,,",
+		"Standup,2027-03-15,,09:30,,15m,,,,,\"FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20270331\"",
+		"Review,2027-03-19,,14:00,,1h,,,,,weekly",
+	}, "\n")})
+
+	p := newTestParser(t)
+	events, err := p.ParseFile(filepath.Join(dir, "schedule.csv"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5", len(events))
+	}
+
+	kickoff := events[0]
+	if kickoff.Name != "Kickoff" || kickoff.Location != "Hall A" || kickoff.Description != "First day" || kickoff.ColorID != "5" {
+		t.Errorf("got %+v, want the Kickoff fields", kickoff)
+	}
+	if got := kickoff.EndTime.Sub(kickoff.StartTime); got != 90*time.Minute || kickoff.StartTime.Hour() != 9 {
+		t.Errorf("got %s for %s, want 09:00 for 1h30m", kickoff.StartTime, got)
+	}
+	if got, want := strings.Join(kickoff.Links, " "), "https://example.com/a https://example.com/b"; got != want {
+		t.Errorf("got links %s, want %s", got, want)
+	}
+
+	// A row without a start time is all day, and one with an end date a range
+	if !events[1].AllDay {
+		t.Errorf("got %+v, want an all-day event", events[1])
+	}
+	if retreat := events[2]; retreat.EndTime.Day() != 12 || retreat.StartTime.Day() != 10 {
+		t.Errorf("got %s to %s, want Mar 10 to 12", retreat.StartTime, retreat.EndTime)
+	}
+
+	// A date-only UNTIL includes the whole day, and a frequency alone is a rule
+	if got, want := events[3].Recurrence.ToRRuleString(), "RRULE:FREQ=WEEKLY;UNTIL=20270331T235959Z;BYDAY=MO,WE"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := events[4].Recurrence.ToRRuleString(), "RRULE:FREQ=WEEKLY"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(p.Warnings) != 0 {
+		t.Errorf("got warnings %q, want none", p.Warnings)
+	}
+}
+
+func TestCSVColumnMapping(t *testing.T) {
+	data := []byte("Session,When,Start,Owner\nLab,2027-03-01,10:00,Sam\n")
+
+	p := newTestParser(t)
+	if _, err := p.Parse(data, FormatCSV); err == nil || !strings.Contains(err.Error(), "--map name=<column>") {
+		t.Errorf("got %v, want a hint to map the name column", err)
+	}
+
+	p.CSVColumns = map[string]string{"name": "session", "Date": "When"}
+	events, err := p.Parse(data, FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Name != "Lab" || events[0].StartTime.Day() != 1 {
+		t.Errorf("got %+v, want the Lab on Mar 1", events)
+	}
+	if got, want := strings.Join(p.Warnings, "\n"), `CSV column "Owner" is not used`; got != want {
+		t.Errorf("got warnings %q, want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		columns map[string]string
+		want    string
+	}{
+		{map[string]string{"owner": "Owner"}, `unknown CSV field "owner"`},
+		{map[string]string{"name": "Speaker"}, `column "Speaker" mapped to name is not in the CSV header`},
+	} {
+		p.CSVColumns = tt.columns
+		if _, err := p.Parse(data, FormatCSV); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want %q", tt.columns, err, tt.want)
+		}
+	}
+}
+
+func TestCSVRowErrors(t *testing.T) {
+	p := newTestParser(t)
+	_, err := p.Parse([]byte("name,date,start,all_day,rrule\n"+
+		"Lab,2027-03-01,10:00,,\n"+
+		",2027-03-02,10:00,,\n"+
+		"Lab,2027-03-03,10:00,maybe,\n"+
+		"Lab,2027-03-04,10:00,,FREQ=FORTNIGHTLY\n"), FormatCSV)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	var got []string
+	for _, d := range p.Diagnostics {
+		got = append(got, d.Message)
+		if d.Line < 3 || d.Column != 1 {
+			t.Errorf("%s: got line %d column %d, want the row's line", d.Message, d.Line, d.Column)
+		}
+	}
+	for i, want := range []string{"missing name", "invalid all_day value: maybe", "failed to parse recurrence rule"} {
+		if i >= len(got) || !strings.Contains(got[i], want) {
+			t.Errorf("got %q, want error %d about %s", got, i, want)
+		}
+	}
+}
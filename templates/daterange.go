@@ -10,9 +10,10 @@ import (
 
 // DateRangeEventInput represents a multi-day event
 type DateRangeEventInput struct {
+	ID          string   `json:"id,omitempty"`
 	Name        string   `json:"name"`
-	StartDate   string   `json:"start_date"`
-	EndDate     string   `json:"end_date"`
+	StartDate   string   `json:"start_date" schema:"required"`
+	EndDate     string   `json:"end_date" schema:"required"`
 	StartTime   string   `json:"start_time,omitempty"` // Optional for non-all-day
 	EndTime     string   `json:"end_time,omitempty"`
 	AllDay      bool     `json:"all_day,omitempty"`
@@ -20,12 +21,19 @@ type DateRangeEventInput struct {
 	Location    string   `json:"location,omitempty"`
 	Links       []string `json:"links,omitempty"`
 	ColorID     string   `json:"color_id,omitempty"`
+
+	HolidayPolicy string `json:"holiday_policy,omitempty"` // Overrides the template's holiday policy
 }
 
 // DateRangeTemplate represents the date range template format
 type DateRangeTemplate struct {
-	Format string                `json:"format"`
-	Events []DateRangeEventInput `json:"events"`
+	Format   string                `json:"format"`
+	Holidays *HolidayInput         `json:"holidays,omitempty"`
+	Defaults *DefaultsInput        `json:"defaults,omitempty"`
+	Vars     VarsInput             `json:"vars,omitempty"`
+	Extends  string                `json:"extends,omitempty"`
+	Include  []string              `json:"include,omitempty"`
+	Events   []DateRangeEventInput `json:"events"`
 }
 
 // parseDateRange parses the date range format
@@ -34,14 +42,37 @@ func (p *Parser) parseDateRange(data []byte) ([]models.CalendarEvent, error) {
 	if err := json.Unmarshal(data, &template); err != nil {
 		return nil, fmt.Errorf("failed to parse date range events JSON: %w", err)
 	}
+	if err := checkEventFormats(data, FormatDateRange); err != nil {
+		return nil, err
+	}
+
+	holidays, err := p.loadHolidays(template.Holidays)
+	if err != nil {
+		return nil, err
+	}
+	values := p.loadValues(template.Defaults, template.Vars)
 
 	var events []models.CalendarEvent
-	for _, dr := range template.Events {
+	for i, dr := range template.Events {
+		path := fmt.Sprintf("$.events[%d]", i)
 		event, err := p.convertDateRangeEvent(dr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert event '%s': %w", dr.Name, err)
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", dr.Name, err))
+			continue
+		}
+		if err := values.apply(&event); err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", dr.Name, err))
+			continue
+		}
+		keep, err := p.applyHolidays(holidays, &event, dr.HolidayPolicy)
+		if err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", dr.Name, err))
+			continue
+		}
+		if keep {
+			p.track(path, event)
+			events = append(events, event)
 		}
-		events = append(events, event)
 	}
 
 	return events, nil
@@ -51,12 +82,12 @@ func (p *Parser) parseDateRange(data []byte) ([]models.CalendarEvent, error) {
 func (p *Parser) convertDateRangeEvent(dr DateRangeEventInput) (models.CalendarEvent, error) {
 	startDate, err := p.TimeParser.ParseDate(dr.StartDate)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse start date: %w", err)
+		return models.CalendarEvent{}, inField("start_date", badValue(fmt.Errorf("failed to parse start date: %w", err)))
 	}
 
 	endDate, err := p.TimeParser.ParseDate(dr.EndDate)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse end date: %w", err)
+		return models.CalendarEvent{}, inField("end_date", badValue(fmt.Errorf("failed to parse end date: %w", err)))
 	}
 
 	var startTime, endTime time.Time
@@ -73,7 +104,7 @@ func (p *Parser) convertDateRangeEvent(dr DateRangeEventInput) (models.CalendarE
 		if dr.StartTime != "" {
 			startHour, startMin, err := p.TimeParser.ParseTime(dr.StartTime)
 			if err != nil {
-				return models.CalendarEvent{}, fmt.Errorf("failed to parse start time: %w", err)
+				return models.CalendarEvent{}, inField("start_time", badValue(fmt.Errorf("failed to parse start time: %w", err)))
 			}
 			startTime = p.TimeParser.CombineDateTime(startDate, startHour, startMin)
 		} else {
@@ -84,7 +115,7 @@ func (p *Parser) convertDateRangeEvent(dr DateRangeEventInput) (models.CalendarE
 		if dr.EndTime != "" {
 			endHour, endMin, err := p.TimeParser.ParseTime(dr.EndTime)
 			if err != nil {
-				return models.CalendarEvent{}, fmt.Errorf("failed to parse end time: %w", err)
+				return models.CalendarEvent{}, inField("end_time", badValue(fmt.Errorf("failed to parse end time: %w", err)))
 			}
 			endTime = p.TimeParser.CombineDateTime(endDate, endHour, endMin)
 		} else {
@@ -94,6 +125,7 @@ func (p *Parser) convertDateRangeEvent(dr DateRangeEventInput) (models.CalendarE
 	}
 
 	return models.CalendarEvent{
+		ID:          dr.ID,
 		Name:        dr.Name,
 		Description: dr.Description,
 		StartTime:   startTime,
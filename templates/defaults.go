@@ -0,0 +1,179 @@
+package templates
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// DefaultsInput holds values given to every event of a template that does not set its own
+type DefaultsInput struct {
+	Description string          `json:"description,omitempty"`
+	Location    string          `json:"location,omitempty"`
+	Links       []string        `json:"links,omitempty"`
+	ColorID     string          `json:"color_id,omitempty"`
+	Reminders   []ReminderInput `json:"reminders,omitempty"`
+}
+
+// ReminderInput is a reminder set in a template's defaults
+type ReminderInput struct {
+	Method  string `json:"method" schema:"required"`  // email or popup
+	Minutes int    `json:"minutes" schema:"required"` // Before the event
+}
+
+// mergeDefaults fills the fields outer leaves empty from inner. Either may be nil.
+func mergeDefaults(inner, outer *DefaultsInput) *DefaultsInput {
+	if inner == nil || outer == nil {
+		return cmp.Or(outer, inner)
+	}
+
+	merged := *outer
+	merged.Description = cmp.Or(merged.Description, inner.Description)
+	merged.Location = cmp.Or(merged.Location, inner.Location)
+	merged.ColorID = cmp.Or(merged.ColorID, inner.ColorID)
+	if len(merged.Links) == 0 {
+		merged.Links = inner.Links
+	}
+	if len(merged.Reminders) == 0 {
+		merged.Reminders = inner.Reminders
+	}
+	return &merged
+}
+
+// reminders converts the default reminders
+func (d *DefaultsInput) reminders() ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	for _, r := range d.Reminders {
+		if r.Method != "email" && r.Method != "popup" {
+			return nil, fmt.Errorf("invalid reminder method %q (expected email or popup)", r.Method)
+		}
+		if r.Minutes < 0 {
+			return nil, fmt.Errorf("invalid reminder: %d minutes before the event", r.Minutes)
+		}
+		reminders = append(reminders, models.Reminder{Method: r.Method, Minutes: r.Minutes})
+	}
+	return reminders, nil
+}
+
+// VarsInput maps template variable names to their values. Numbers and
+// booleans are accepted as values, as YAML and TOML write them unquoted.
+type VarsInput map[string]string
+
+// UnmarshalJSON reads the variables, formatting scalar values as strings
+func (v *VarsInput) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]any
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	*v = make(VarsInput, len(raw))
+	for name, value := range raw {
+		switch value.(type) {
+		case string, json.Number, bool:
+			(*v)[name] = fmt.Sprint(value)
+		default:
+			return fmt.Errorf("variable %q must be a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+// templateVar matches a {{name}} reference to a template variable
+var templateVar = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// templateValues are the defaults of a template and its variables, with the
+// parser's variables taking precedence
+type templateValues struct {
+	defaults *DefaultsInput
+	vars     map[string]string
+}
+
+// loadValues combines a template's defaults and variables with those of the
+// templates extending or including it and p.Vars
+func (p *Parser) loadValues(defaults *DefaultsInput, vars VarsInput) *templateValues {
+	v := &templateValues{defaults: mergeDefaults(defaults, p.inheritedDefaults), vars: make(map[string]string)}
+	for name, value := range vars {
+		v.vars[name] = value
+	}
+	for name, value := range p.inherited {
+		v.vars[name] = value
+	}
+	for name, value := range p.Vars {
+		v.vars[name] = value
+	}
+	return v
+}
+
+// apply fills the fields an event leaves empty from the defaults, then
+// interpolates variables in its name, description, location and links
+func (v *templateValues) apply(event *models.CalendarEvent) error {
+	if d := v.defaults; d != nil {
+		if event.Description == "" {
+			event.Description = d.Description
+		}
+		if event.Location == "" {
+			event.Location = d.Location
+		}
+		if len(event.Links) == 0 {
+			event.Links = d.Links
+		}
+		if event.ColorID == "" {
+			event.ColorID = d.ColorID
+		}
+		if len(event.Reminders) == 0 {
+			reminders, err := d.reminders()
+			if err != nil {
+				return err
+			}
+			event.Reminders = reminders
+		}
+	}
+
+	var err error
+	for _, field := range []*string{&event.Name, &event.Description, &event.Location} {
+		if *field, err = v.interpolate(*field); err != nil {
+			return err
+		}
+	}
+	if len(event.Links) > 0 {
+		links := make([]string, len(event.Links)) // Defaults share their links
+		for i, link := range event.Links {
+			if links[i], err = v.interpolate(link); err != nil {
+				return err
+			}
+		}
+		event.Links = links
+	}
+	if x := event.Exceptions; x != nil {
+		for i := range x.Overrides {
+			o := &x.Overrides[i]
+			if o.Description, err = v.interpolate(o.Description); err != nil {
+				return err
+			}
+			if o.Location, err = v.interpolate(o.Location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// interpolate replaces {{name}} references with the values of the variables
+func (v *templateValues) interpolate(s string) (string, error) {
+	var err error
+	s = templateVar.ReplaceAllStringFunc(s, func(ref string) string {
+		name := templateVar.FindStringSubmatch(ref)[1]
+		value, ok := v.vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %q; define it in vars or with --var %s=...", name, name)
+		}
+		return value
+	})
+	return s, err
+}
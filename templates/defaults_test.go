@@ -0,0 +1,126 @@
+package templates
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	defaults := `"defaults": {"location": "Room {{room}}", "links": ["https://example.com/{{cohort}}"], "color_id": "7",
+		"reminders": [{"method": "popup", "minutes": 10}]},
+		"vars": {"cohort": "spring", "room": 101}`
+
+	// Every format takes the same defaults
+	for _, source := range []string{
+		`{"format": "single", ` + defaults + `, "events": [{"name": "Lab", "date": "2027-03-01", "start_time": "09:00"}]}`,
+		`{"format": "recurring", ` + defaults + `, "events": [{"name": "Lab", "start_date": "2027-03-01", "start_time": "09:00",
+			"recurrence": {"frequency": "DAILY", "count": 2}}]}`,
+		`{"format": "daterange", ` + defaults + `, "events": [{"name": "Lab", "start_date": "2027-03-01", "end_date": "2027-03-02", "all_day": true}]}`,
+		`{` + defaults + `, "week_1": [{"event_name": "Lab", "date": "2027-03-01", "time": "9:00am – 10:00am", "topic_details": ""}]}`,
+		`{"format": "bundle", ` + defaults + `, "single": [{"name": "Lab", "date": "2027-03-01", "start_time": "09:00"}]}`,
+	} {
+		events := parse(t, newTestParser(t), source)
+		e := events[0]
+		got := fmt.Sprint(e.Location, " ", e.Links, " ", e.ColorID, " ", e.Reminders)
+		if want := "Room 101 [https://example.com/spring] 7 [{popup 10}]"; got != want {
+			t.Errorf("got %s, want %s for %s", got, want, source)
+		}
+	}
+}
+
+func TestDefaultsYieldToEvents(t *testing.T) {
+	events := parse(t, newTestParser(t), `{"format": "single",
+		"defaults": {"description": "Bring a laptop", "location": "Room 1", "links": ["https://example.com/{{n}}"], "color_id": "7"},
+		"vars": {"n": "one"},
+		"events": [
+			{"name": "Lab {{n}}", "date": "2027-03-01", "start_time": "09:00", "location": "Hall", "color_id": "3", "links": ["https://example.com/own"]},
+			{"name": "Lab", "date": "2027-03-02", "start_time": "09:00"}
+		]}`)
+
+	if e := events[0]; e.Name != "Lab one" || e.Location != "Hall" || e.ColorID != "3" || e.Links[0] != "https://example.com/own" || e.Description != "Bring a laptop" {
+		t.Errorf("got %+v, want the event's own values and the default description", e)
+	}
+
+	// Interpolating the defaults' links leaves them for the next event
+	events = parse(t, newTestParser(t), `{"format": "single",
+		"defaults": {"links": ["https://example.com/{{n}}"]}, "vars": {"n": "one"},
+		"events": [{"name": "A", "date": "2027-03-01", "start_time": "09:00"}, {"name": "B", "date": "2027-03-02", "start_time": "09:00"}]}`)
+	events[0].Links[0] = "changed"
+	if events[1].Links[0] != "https://example.com/one" {
+		t.Errorf("got %s, want the links of each event to be their own", events[1].Links[0])
+	}
+}
+
+func TestVars(t *testing.T) {
+	source := `{"format": "recurring", "vars": {"cohort": "A", "teacher": "Sam"},
+		"events": [{"name": "{{ cohort }} lecture", "description": "With {{teacher}}", "start_date": "2027-03-01", "start_time": "09:00",
+			"recurrence": {"frequency": "DAILY", "count": 3},
+			"overrides": [{"date": "2027-03-02", "location": "Room {{cohort}}", "description": "{{teacher}} is away"}]}]}`
+
+	p := newTestParser(t)
+	events := parse(t, p, source)
+	if e := events[0]; e.Name != "A lecture" || e.Description != "With Sam" {
+		t.Errorf("got %q, %q, want the template's values", e.Name, e.Description)
+	}
+
+	// --var takes precedence over the template
+	p.Vars = map[string]string{"cohort": "B"}
+	events = parse(t, p, source)
+	e := events[0]
+	if e.Name != "B lecture" || e.Description != "With Sam" {
+		t.Errorf("got %q, %q, want cohort B", e.Name, e.Description)
+	}
+	if o := e.Exceptions.Overrides[0]; o.Location != "Room B" || o.Description != "Sam is away" {
+		t.Errorf("got override %+v, want its variables interpolated", o)
+	}
+
+	// CSV files have no vars block but take --var
+	p.Vars = map[string]string{"room": "12"}
+	events, err := p.Parse([]byte("name,date,location\nLab,2027-03-01,Room {{room}}\n"), FormatCSV)
+	if err != nil || events[0].Location != "Room 12" {
+		t.Errorf("got %v, %v, want Room 12", events, err)
+	}
+
+	_, err = newTestParser(t).Parse([]byte(`{"format": "single",
+		"events": [{"name": "{{cohort}} lab", "date": "2027-03-01", "start_time": "09:00"}]}`), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), `undefined variable "cohort"; define it in vars or with --var cohort=...`) {
+		t.Errorf("got %v, want an undefined variable error", err)
+	}
+}
+
+func TestVarsFromMarkup(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cohort.yaml": "format: single\nvars:\n  year: 2027\n  remote: true\nevents:\n" +
+			"  - {name: \"Class of {{year}}\", description: \"Remote: {{remote}}\", date: 2027-03-01, start_time: \"09:00\"}\n",
+		"nested.json": `{"format": "single", "vars": {"room": {"number": 1}}, "events": []}`,
+	})
+
+	p := newTestParser(t)
+	events, err := p.ParseFile(filepath.Join(dir, "cohort.yaml"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := events[0]; e.Name != "Class of 2027" || e.Description != "Remote: true" {
+		t.Errorf("got %q, %q, want the unquoted values as text", e.Name, e.Description)
+	}
+
+	_, err = p.ParseFile(filepath.Join(dir, "nested.json"), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), `variable "room" must be a string, number or boolean`) {
+		t.Errorf("got %v, want an error about the nested value", err)
+	}
+}
+
+func TestDefaultReminders(t *testing.T) {
+	for reminder, want := range map[string]string{
+		`{"method": "sms", "minutes": 10}`:   `invalid reminder method "sms"`,
+		`{"method": "popup", "minutes": -5}`: "-5 minutes before the event",
+	} {
+		_, err := newTestParser(t).Parse([]byte(`{"format": "single", "defaults": {"reminders": [`+reminder+`]},
+			"events": [{"name": "Lab", "date": "2027-03-01", "start_time": "09:00"}]}`), FormatAuto)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", reminder, err, want)
+		}
+	}
+}
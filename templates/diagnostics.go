@@ -0,0 +1,406 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how serious a diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a template
+type Diagnostic struct {
+	Severity   Severity  `json:"severity"`
+	Rule       string    `json:"rule,omitempty"` // Lint rule that found the problem
+	File       string    `json:"file,omitempty"`
+	Path       string    `json:"path,omitempty"` // JSON path of the value, e.g. $.events[2].date
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	Message    string    `json:"message"`
+	Suggestion string    `json:"suggestion,omitempty"`
+	Related    *Location `json:"related,omitempty"` // The other event of a problem between two, e.g. an overlap
+
+	err error // Error the diagnostic was made from
+}
+
+// Location is a place in a template file
+type Location struct {
+	File   string `json:"file,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// String formats the location as file:line:col (path)
+func (l Location) String() string {
+	var b strings.Builder
+	if l.File != "" {
+		b.WriteString(l.File)
+		if l.Line > 0 {
+			fmt.Fprintf(&b, ":%d", l.Line)
+		}
+		if l.Column > 0 {
+			fmt.Fprintf(&b, ":%d", l.Column)
+		}
+	}
+	if l.Path != "" {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "(%s)", l.Path)
+	}
+	return b.String()
+}
+
+// String formats the diagnostic like a compiler message: file:line:col: severity: message
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d", d.Line)
+		}
+		if d.Column > 0 {
+			fmt.Fprintf(&b, ":%d", d.Column)
+		}
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%s: %s", d.Severity, d.Message)
+	if d.Path != "" {
+		fmt.Fprintf(&b, " (%s)", d.Path)
+	}
+	if d.Rule != "" {
+		fmt.Fprintf(&b, " [%s]", d.Rule)
+	}
+	if d.Related != nil {
+		fmt.Fprintf(&b, "\n  related: %s", d.Related)
+	}
+	if d.Suggestion != "" {
+		fmt.Fprintf(&b, "\n  suggestion: %s", d.Suggestion)
+	}
+	return b.String()
+}
+
+// fieldError is an error in one field of a template value. Nested field
+// errors make up the path of the field within the value.
+type fieldError struct {
+	field string // JSON key, or an index such as [2]; empty if only bad is set
+	bad   bool   // Whether the value at the end of the path could not be read
+	err   error
+}
+
+func (e *fieldError) Error() string { return e.err.Error() }
+func (e *fieldError) Unwrap() error { return e.err }
+
+// inField marks err as an error in the given field
+func inField(field string, err error) error {
+	return &fieldError{field: field, err: err}
+}
+
+// badValue marks err as an error reading the value of a field, so that the
+// diagnostic says how to write the value
+func badValue(err error) error {
+	return &fieldError{bad: true, err: err}
+}
+
+// fieldPath returns the path of the field an error is in, e.g.
+// recurrence.by_day[1], and how to write its value if it could not be read
+func fieldPath(err error) (path, hint string) {
+	bad := false
+	for ; err != nil; err = errors.Unwrap(err) {
+		if fe, ok := err.(*fieldError); ok {
+			if fe.field != "" {
+				path = joinPath(path, fe.field)
+			}
+			bad = bad || fe.bad
+		}
+	}
+	if !bad {
+		return path, ""
+	}
+
+	field := path[strings.LastIndex(path, ".")+1:]
+	if i := strings.Index(field, "["); i >= 0 {
+		field = field[:i]
+	}
+	return path, fieldHints[field]
+}
+
+// joinPath appends a key or index to a JSON path
+func joinPath(path, field string) string {
+	if path == "" || field == "" || strings.HasPrefix(field, "[") {
+		return path + field
+	}
+	return path + "." + field
+}
+
+// fieldHints say how to write the values of fields
+var fieldHints = map[string]string{
+	"date":           "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"start_date":     "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"end_date":       "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"until":          "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"exclude_dates":  "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"extra_dates":    "write dates as YYYY-MM-DD, e.g. 2026-03-02",
+	"start_time":     "write times as HH:MM, e.g. 09:30 or 2:30pm",
+	"end_time":       "write times as HH:MM, e.g. 09:30 or 2:30pm",
+	"time":           "write time ranges as HH:MM-HH:MM, e.g. 10:00-11:30",
+	"duration":       "write durations like 45m, 2h or 1h30m",
+	"by_day":         "use MO, TU, WE, TH, FR, SA or SU, optionally with an ordinal such as 2TU or -1FR",
+	"week_start":     "use MO, TU, WE, TH, FR, SA or SU",
+	"holiday_policy": "use skip, shift, warn or ignore",
+	"policy":         "use skip, shift, warn or ignore",
+	"method":         "use email or popup",
+	"minutes":        "write the minutes before the event as a number, e.g. 10",
+}
+
+// report records a diagnostic for the template being parsed, locating its path in the file
+func (p *Parser) report(d Diagnostic) {
+	if p.src != nil {
+		if d.File == "" {
+			d.File = p.src.name
+		}
+		if d.Line == 0 && d.Path != "" {
+			d.Line, d.Column = p.src.locate(d.Path)
+		}
+	}
+	p.Diagnostics = append(p.Diagnostics, d)
+}
+
+// sortDiagnostics orders the diagnostics by position: files in the order
+// they were first reported on, then by line and column. Diagnostics without
+// a line come first in their file.
+func (p *Parser) sortDiagnostics() {
+	files := make(map[string]int)
+	for _, d := range p.Diagnostics {
+		if _, ok := files[d.File]; !ok {
+			files[d.File] = len(files)
+		}
+	}
+	slices.SortStableFunc(p.Diagnostics, func(a, b Diagnostic) int {
+		if files[a.File] != files[b.File] {
+			return files[a.File] - files[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+}
+
+// fail records err, found in the value at path, as an error diagnostic so
+// that parsing can go on and report further problems
+func (p *Parser) fail(path string, err error) {
+	field, hint := fieldPath(err)
+	d := Diagnostic{
+		Severity:   SeverityError,
+		Path:       joinPath(path, field),
+		Message:    err.Error(),
+		Suggestion: hint,
+		err:        err,
+	}
+
+	// Type errors name the field they are in; syntax errors carry the
+	// position they were found at
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var tomlErr toml.ParseError
+	var csvErr *csv.ParseError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		for _, key := range strings.Split(typeErr.Field, ".") {
+			if _, err := strconv.Atoi(key); err == nil {
+				key = "[" + key + "]"
+			}
+			d.Path = joinPath(d.Path, key)
+		}
+	case errors.As(err, &syntaxErr) && p.src != nil && p.src.raw != nil:
+		d.Path = ""
+		// The offset counts the bytes read, up to and including the bad one
+		d.Line, d.Column = lineColumn(p.src.raw, max(syntaxErr.Offset-1, 0))
+	case errors.As(err, &csvErr):
+		d.Path = ""
+		d.Line, d.Column = csvErr.Line, csvErr.Column
+	case errors.As(err, &tomlErr):
+		d.Path = ""
+		d.Line, d.Column = tomlErr.Position.Line, tomlErr.Position.Col
+	default:
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			d.Path = ""
+			d.Line, _ = strconv.Atoi(m[1])
+		}
+	}
+	p.report(d)
+}
+
+// failLine records err, found in a line of a file without JSON paths such as
+// a CSV file, as an error diagnostic. The error returned to callers names the
+// line, which the diagnostic gives as its position.
+func (p *Parser) failLine(line int, err error) {
+	_, hint := fieldPath(err)
+	p.report(Diagnostic{
+		Severity:   SeverityError,
+		Line:       line,
+		Column:     1,
+		Message:    err.Error(),
+		Suggestion: hint,
+		err:        fmt.Errorf("line %d: %w", line, err),
+	})
+}
+
+// yamlLine finds the line in the messages of YAML syntax errors
+var yamlLine = regexp.MustCompile(`yaml: line (\d+):`)
+
+// errorSince returns an error for the error diagnostics recorded from index i
+// on, or nil if there are none
+func (p *Parser) errorSince(i int) error {
+	var first error
+	count := 0
+	for _, d := range p.Diagnostics[i:] {
+		if d.Severity != SeverityError {
+			continue
+		}
+		if first == nil {
+			first = d.err
+		}
+		count++
+	}
+
+	switch {
+	case count == 0:
+		return nil
+	case count == 1:
+		return first
+	}
+	return fmt.Errorf("%w (and %d more)", first, count-1)
+}
+
+// source is a template file being parsed, with the position of each value by JSON path
+type source struct {
+	name      string
+	raw       []byte // Content of JSON files, for the offsets of JSON errors
+	positions map[string][2]int
+}
+
+// newSource indexes the positions of the values in a JSON or YAML file.
+// Positions are not available for other files.
+func newSource(name string, data []byte) *source {
+	s := &source{name: name, positions: make(map[string][2]int)}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if yaml.Unmarshal(data, &doc) == nil && len(doc.Content) > 0 {
+			s.indexYAML("$", doc.Content[0])
+		}
+	case ".toml", ".csv", ".ics":
+	default:
+		s.raw = data
+		s.indexJSON(data)
+	}
+	return s
+}
+
+// locate returns the line and column of the value at path, or of the closest
+// enclosing value that was found
+func (s *source) locate(path string) (line, column int) {
+	for path != "" {
+		if pos, ok := s.positions[path]; ok {
+			return pos[0], pos[1]
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0, 0
+}
+
+// indexYAML records the positions of a YAML node and its children
+func (s *source) indexYAML(path string, node *yaml.Node) {
+	s.positions[path] = [2]int{node.Line, node.Column}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			s.indexYAML(joinPath(path, node.Content[i].Value), node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			s.indexYAML(fmt.Sprintf("%s[%d]", path, i), child)
+		}
+	}
+}
+
+// indexJSON records the positions of the values of a JSON document
+func (s *source) indexJSON(data []byte) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	// start returns the offset of the next token
+	start := func() int64 {
+		offset := decoder.InputOffset()
+		for offset < int64(len(data)) && strings.IndexByte(" \t\r\n:,", data[offset]) >= 0 {
+			offset++
+		}
+		return offset
+	}
+
+	var value func(path string) error
+	value = func(path string) error {
+		line, column := lineColumn(data, start())
+		s.positions[path] = [2]int{line, column}
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if err := value(joinPath(path, fmt.Sprint(key))); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := value(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	value("$")
+}
+
+// lineColumn converts a byte offset in data to a line and column, both starting at 1
+func lineColumn(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
@@ -0,0 +1,176 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// positions formats the diagnostics as "line:column path" for comparison
+func positions(diagnostics []Diagnostic) string {
+	var list []string
+	for _, d := range diagnostics {
+		list = append(list, strings.TrimSpace(Location{Line: d.Line, Column: d.Column, Path: d.Path, File: "f"}.String()[1:]))
+	}
+	return strings.Join(list, "\n")
+}
+
+func TestDiagnosticPositions(t *testing.T) {
+	tests := []struct {
+		file string
+		data string
+		want string // Positions, as formatted by positions
+	}{
+		{
+			"schedule.json",
+			`{
+  "format": "recurring",
+  "events": [
+    {"name": "Lecture", "start_time": "10:00", "recurrence": {"frequency": "HOURLY"}},
+    {
+      "name": "Lab",
+      "start_time": "14:00",
+      "recurrence": {"frequency": "WEEKLY", "by_day": ["MO", "XX"]}
+    }
+  ]
+}`,
+			":4:62 ($.events[0].recurrence)\n:8:62 ($.events[1].recurrence.by_day[1])",
+		},
+		{
+			// Values of the wrong type stop the template from being read
+			"types.json",
+			`{"format": "recurring", "events": [
+  {"name": "Lecture", "start_time": "10:00", "recurrence": {"frequency": "DAILY"}},
+  {"name": "Seminar", "start_time": 9, "recurrence": {"frequency": "DAILY"}}
+]}`,
+			":3:37 ($.events[1].start_time)",
+		},
+		{
+			"schedule.yaml",
+			"format: single\nevents:\n  - name: Lab\n    date: 2027-03-01\n    start_time: \"09:00\"\n  - name: Exam\n    date: someday\n    start_time: \"09:00\"\n",
+			":7:11 ($.events[1].date)",
+		},
+		{"syntax.json", "{\n  \"format\": \"single\",\n  \"events\": [\n    {\"name\": \"Lab\",}\n  ]\n}", ":4:20"},
+		{"syntax.yaml", "format: single\nevents:\n  - name: Lab\n    date: : x\n", ":4"},
+		{"syntax.toml", "format = \"single\"\n\n[[events]]\nname = Lab\n", ":4:8"},
+		{"rows.csv", "name,date,start\nLab,2027-03-01,09:00\nExam,someday,09:00\n", ":3:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{tt.file: tt.data})
+			p := newTestParser(t)
+			if _, err := p.ParseFile(filepath.Join(dir, tt.file), FormatAuto); err == nil {
+				t.Fatal("got no error")
+			}
+			for _, d := range p.Diagnostics {
+				if d.File != filepath.Join(dir, tt.file) || d.Severity != SeverityError {
+					t.Errorf("got %s in %s, want an error in %s", d.Severity, d.File, tt.file)
+				}
+			}
+			if got := positions(p.Diagnostics); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsCollectAll(t *testing.T) {
+	p := newTestParser(t)
+	_, err := p.Parse([]byte(`{"format": "single", "events": [
+		{"name": "A", "date": "2027-02-30", "start_time": "09:00"},
+		{"name": "B", "date": "2027-03-01", "start_time": "09:00"},
+		{"name": "C", "date": "2027-03-01", "start_time": "9 o'clock"},
+		{"name": "D", "date": "2027-03-01", "start_time": "09:00", "duration": "forever"}
+	]}`), FormatAuto)
+
+	// The error names the first problem and counts the rest
+	if err == nil || !strings.Contains(err.Error(), "failed to convert event 'A'") || !strings.HasSuffix(err.Error(), "(and 2 more)") {
+		t.Errorf("got %v, want the first of three errors", err)
+	}
+
+	want := []struct{ path, suggestion string }{
+		{"$.events[0].date", "write dates as YYYY-MM-DD, e.g. 2026-03-02"},
+		{"$.events[2].start_time", "write times as HH:MM, e.g. 09:30 or 2:30pm"},
+		{"$.events[3].duration", "write durations like 45m, 2h or 1h30m"},
+	}
+	if len(p.Diagnostics) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(p.Diagnostics), len(want), p.Diagnostics)
+	}
+	for i, d := range p.Diagnostics {
+		if d.Path != want[i].path || d.Suggestion != want[i].suggestion {
+			t.Errorf("got %s with %q, want %s with %q", d.Path, d.Suggestion, want[i].path, want[i].suggestion)
+		}
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{
+		Severity:   SeverityWarning,
+		Rule:       RuleOverlap,
+		File:       "course.json",
+		Path:       "$.events[1]",
+		Line:       12,
+		Column:     5,
+		Message:    "'Lab' overlaps 'Lecture'",
+		Suggestion: "move one of the events",
+		Related:    &Location{File: "course.json", Line: 4, Column: 5, Path: "$.events[0]"},
+	}
+	want := "course.json:12:5: warning: 'Lab' overlaps 'Lecture' ($.events[1]) [overlap]\n" +
+		"  related: course.json:4:5 ($.events[0])\n" +
+		"  suggestion: move one of the events"
+	if got := d.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got, want := (Diagnostic{Severity: SeverityError, Message: "no events"}).String(), "error: no events"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Severity: SeverityError, File: `dir/course.json`, Path: "$.events[0].date", Line: 3, Column: 14, Message: "invalid date", Suggestion: "write dates as YYYY-MM-DD"},
+		{Severity: SeverityWarning, Rule: RuleOverlap, File: "course.json", Line: 8, Message: "overlap", Related: &Location{File: "base.json", Line: 2}},
+		{Severity: SeverityError, Message: "no file"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, diagnostics, "ceg", "1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Version != "1.2.3" {
+		t.Fatalf("got %s, want one run of version 1.2.3 in a 2.1.0 log", buf.String())
+	}
+	if rules := log.Runs[0].Tool.Driver.Rules; len(rules) != len(LintRules) {
+		t.Errorf("got %d rules, want one per lint rule", len(rules))
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	first := results[0]
+	if first.Level != "error" || first.Message.Text != "invalid date; write dates as YYYY-MM-DD" {
+		t.Errorf("got %s %q, want the error with its suggestion", first.Level, first.Message.Text)
+	}
+	loc := first.Locations[0]
+	if loc.PhysicalLocation.ArtifactLocation.URI != "dir/course.json" || *loc.PhysicalLocation.Region != (sarifRegion{StartLine: 3, StartColumn: 14}) ||
+		loc.LogicalLocations[0].FullyQualifiedName != "$.events[0].date" {
+		t.Errorf("got location %+v, want course.json:3:14 at its path", loc)
+	}
+
+	if second := results[1]; second.RuleID != RuleOverlap || len(second.RelatedLocations) != 1 || second.RelatedLocations[0].ID != 1 {
+		t.Errorf("got %+v, want the overlap rule with a related location", second)
+	}
+	if third := results[2]; third.Locations != nil {
+		t.Errorf("got locations %+v for a diagnostic without a position", third.Locations)
+	}
+}
@@ -0,0 +1,158 @@
+package templates
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/monil/calendar-event-generator/holidays"
+	"github.com/monil/calendar-event-generator/models"
+	"github.com/monil/calendar-event-generator/recurrence"
+)
+
+// HolidayInput selects the holidays a template's events should avoid
+type HolidayInput struct {
+	Calendar string `json:"calendar" schema:"required"` // Bundled country code, e.g. "US", or path to an .ics file
+	Policy   string `json:"policy,omitempty"`           // skip, shift, warn (default) or ignore
+}
+
+// holidayRules is a loaded holiday calendar with the template's default policy
+type holidayRules struct {
+	calendar *holidays.Calendar
+	policy   holidays.Policy
+}
+
+// loadHolidays loads the holiday calendar of a template, if it has one.
+// Relative .ics paths are resolved against the template's directory.
+func (p *Parser) loadHolidays(in *HolidayInput) (*holidayRules, error) {
+	if in == nil || in.Calendar == "" {
+		return nil, nil
+	}
+
+	policy, err := holidays.ParsePolicy(in.Policy)
+	if err != nil {
+		return nil, inField("holidays", inField("policy", badValue(err)))
+	}
+
+	ref := in.Calendar
+	if filepath.Ext(ref) != "" && !filepath.IsAbs(ref) && p.baseDir != "" {
+		ref = filepath.Join(p.baseDir, ref)
+	}
+	calendar, err := holidays.Load(ref)
+	if err != nil {
+		return nil, inField("holidays", inField("calendar", err))
+	}
+
+	return &holidayRules{calendar: calendar, policy: policy}, nil
+}
+
+// applyHolidays applies the holiday policy, or the event's own policy when
+// given, to an event. It returns false when the event should be dropped.
+func (p *Parser) applyHolidays(h *holidayRules, event *models.CalendarEvent, eventPolicy string) (bool, error) {
+	if h == nil {
+		if eventPolicy != "" {
+			return false, inField("holiday_policy", fmt.Errorf("holiday_policy is set but the template has no holidays calendar"))
+		}
+		return true, nil
+	}
+
+	policy := h.policy
+	if eventPolicy != "" {
+		var err error
+		if policy, err = holidays.ParsePolicy(eventPolicy); err != nil {
+			return false, inField("holiday_policy", badValue(err))
+		}
+	}
+	if policy == holidays.PolicyIgnore {
+		return true, nil
+	}
+
+	if event.Recurrence != nil || event.Exceptions != nil {
+		return true, p.applySeriesHolidays(h.calendar, policy, event)
+	}
+
+	name, ok := h.calendar.Lookup(event.StartTime)
+	if !ok {
+		return true, nil
+	}
+
+	switch policy {
+	case holidays.PolicySkip:
+		p.warnf("'%s' on %s skipped: %s", event.Name, event.StartTime.Format("Jan 2, 2006"), name)
+		return false, nil
+	case holidays.PolicyShift:
+		shifted := h.calendar.NextWorkingDay(event.StartTime)
+		p.warnf("'%s' moved from %s to %s: %s", event.Name,
+			event.StartTime.Format("Jan 2, 2006"), shifted.Format("Jan 2, 2006"), name)
+		event.EndTime = shifted.Add(event.EndTime.Sub(event.StartTime))
+		event.StartTime = shifted
+	default:
+		p.warnf("'%s' on %s falls on a holiday: %s", event.Name, event.StartTime.Format("Jan 2, 2006"), name)
+	}
+	return true, nil
+}
+
+// applySeriesHolidays excludes or moves the occurrences of a recurring event
+// that fall on holidays. Occurrences changed by an override are left alone.
+func (p *Parser) applySeriesHolidays(calendar *holidays.Calendar, policy holidays.Policy, event *models.CalendarEvent) error {
+	occurrences, err := recurrence.Expand(event, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	duration := event.EndTime.Sub(event.StartTime)
+	exceptions := event.Exceptions
+	if exceptions == nil {
+		exceptions = &models.Exceptions{}
+	}
+
+	for _, o := range occurrences {
+		name, ok := calendar.Lookup(o.Start)
+		if !ok || o.Overridden {
+			continue
+		}
+		date := o.Start.Format("Jan 2, 2006")
+
+		switch {
+		case policy == holidays.PolicyWarn:
+			p.warnf("'%s' on %s falls on a holiday: %s", event.Name, date, name)
+		case o.Extra:
+			exceptions.ExtraDates = removeTime(exceptions.ExtraDates, o.Start)
+			if policy == holidays.PolicyShift {
+				exceptions.ExtraDates = append(exceptions.ExtraDates, calendar.NextWorkingDay(o.Start))
+			}
+		case policy == holidays.PolicySkip:
+			exceptions.ExcludeDates = append(exceptions.ExcludeDates, o.Start)
+		case policy == holidays.PolicyShift:
+			shifted := calendar.NextWorkingDay(o.Start)
+			exceptions.Overrides = append(exceptions.Overrides, models.Override{
+				OriginalStart: o.Start,
+				StartTime:     shifted,
+				EndTime:       shifted.Add(duration),
+			})
+		}
+
+		switch policy {
+		case holidays.PolicySkip:
+			p.warnf("'%s' on %s skipped: %s", event.Name, date, name)
+		case holidays.PolicyShift:
+			p.warnf("'%s' on %s moved to %s: %s", event.Name, date, calendar.NextWorkingDay(o.Start).Format("Jan 2, 2006"), name)
+		}
+	}
+
+	if event.Exceptions == nil && len(exceptions.ExcludeDates)+len(exceptions.ExtraDates)+len(exceptions.Overrides) > 0 {
+		event.Exceptions = exceptions
+	}
+	return nil
+}
+
+// removeTime removes t from times
+func removeTime(times []time.Time, t time.Time) []time.Time {
+	var kept []time.Time
+	for _, x := range times {
+		if !x.Equal(t) {
+			kept = append(kept, x)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,190 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// newTestParser returns a parser reading times in UTC
+func newTestParser(t *testing.T) *Parser {
+	t.Helper()
+	p, err := NewParser("UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// writeFiles writes files, by name, to a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// parse parses a template, detecting its format, and fails the test on errors
+func parse(t *testing.T, p *Parser, data string) []models.CalendarEvent {
+	t.Helper()
+	events, err := p.Parse([]byte(data), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// eventDays formats the start of each event as "Name Jan 2"
+func eventDays(events []models.CalendarEvent) []string {
+	var days []string
+	for _, e := range events {
+		days = append(days, e.Name+" "+e.StartTime.Format("Jan 2"))
+	}
+	return days
+}
+
+func TestHolidayPolicies(t *testing.T) {
+	// Independence Day 2027 is a Sunday, observed on Monday Jul 5
+	tests := []struct {
+		policy  string
+		want    string
+		warning string
+	}{
+		{"skip", "[Review Jul 2 Planning Jul 6]", "'Review' on Jul 5, 2027 skipped: Independence Day (observed)"},
+		{"shift", "[Review Jul 2 Review Jul 6 Planning Jul 6]", "'Review' moved from Jul 5, 2027 to Jul 6, 2027: Independence Day (observed)"},
+		{"warn", "[Review Jul 2 Review Jul 5 Planning Jul 6]", "'Review' on Jul 5, 2027 falls on a holiday: Independence Day (observed)"},
+		{"ignore", "[Review Jul 2 Review Jul 5 Planning Jul 6]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			p := newTestParser(t)
+			events := parse(t, p, `{
+				"format": "single",
+				"holidays": {"calendar": "US", "policy": "`+tt.policy+`"},
+				"events": [
+					{"name": "Review", "date": "2027-07-02", "start_time": "10:00", "duration": "1h"},
+					{"name": "Review", "date": "2027-07-05", "start_time": "10:00", "duration": "1h"},
+					{"name": "Planning", "date": "2027-07-06", "start_time": "10:00", "duration": "1h"}
+				]
+			}`)
+
+			if got := strings.Join(eventDays(events), " "); "["+got+"]" != tt.want {
+				t.Errorf("got [%s], want %s", got, tt.want)
+			}
+			for _, e := range events {
+				if e.EndTime.Sub(e.StartTime) != time.Hour || e.StartTime.Hour() != 10 {
+					t.Errorf("%s: got %s to %s, want 10:00 for an hour", e.Name, e.StartTime, e.EndTime)
+				}
+			}
+			if got := strings.Join(p.Warnings, "\n"); got != tt.warning {
+				t.Errorf("got warnings %q, want %q", got, tt.warning)
+			}
+		})
+	}
+}
+
+func TestHolidayEventPolicy(t *testing.T) {
+	p := newTestParser(t)
+	events := parse(t, p, `{
+		"format": "single",
+		"holidays": {"calendar": "US", "policy": "skip"},
+		"events": [
+			{"name": "Review", "date": "2027-07-05", "start_time": "10:00", "holiday_policy": "ignore"},
+			{"name": "Planning", "date": "2027-07-05", "start_time": "11:00"}
+		]
+	}`)
+	if got := eventDays(events); len(got) != 1 || got[0] != "Review Jul 5" {
+		t.Errorf("got %v, want only the Review on Jul 5", got)
+	}
+
+	// An event policy needs a calendar to apply to
+	_, err := p.Parse([]byte(`{"format": "single", "events": [
+		{"name": "Review", "date": "2027-07-05", "start_time": "10:00", "holiday_policy": "skip"}
+	]}`), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), "no holidays calendar") {
+		t.Errorf("got %v, want an error about the missing calendar", err)
+	}
+
+	_, err = p.Parse([]byte(`{"format": "single", "holidays": {"calendar": "US", "policy": "later"}, "events": [
+		{"name": "Review", "date": "2027-07-05", "start_time": "10:00"}
+	]}`), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), "invalid holiday policy") {
+		t.Errorf("got %v, want an invalid policy error", err)
+	}
+}
+
+func TestHolidaySeries(t *testing.T) {
+	// Mondays from Jun 28, 2027; Jul 5 is Independence Day (observed)
+	template := func(policy string) string {
+		return `{
+			"format": "recurring",
+			"holidays": {"calendar": "US", "policy": "` + policy + `"},
+			"events": [{
+				"name": "Lecture", "start_date": "2027-06-28", "start_time": "09:00", "duration": "2h",
+				"recurrence": {"frequency": "WEEKLY", "count": 3}
+			}]
+		}`
+	}
+	july5 := time.Date(2027, 7, 5, 9, 0, 0, 0, time.UTC)
+
+	p := newTestParser(t)
+	events := parse(t, p, template("skip"))
+	x := events[0].Exceptions
+	if x == nil || len(x.ExcludeDates) != 1 || !x.ExcludeDates[0].Equal(july5) || len(x.Overrides) != 0 {
+		t.Errorf("skip: got exceptions %+v, want Jul 5 excluded", x)
+	}
+
+	events = parse(t, p, template("shift"))
+	x = events[0].Exceptions
+	if x == nil || len(x.Overrides) != 1 || len(x.ExcludeDates) != 0 {
+		t.Fatalf("shift: got exceptions %+v, want one override", x)
+	}
+	o := x.Overrides[0]
+	if !o.OriginalStart.Equal(july5) || !o.StartTime.Equal(july5.AddDate(0, 0, 1)) || o.EndTime.Sub(o.StartTime) != 2*time.Hour {
+		t.Errorf("shift: got %+v, want Jul 5 moved to Jul 6 for 2 hours", o)
+	}
+
+	events = parse(t, p, template("warn"))
+	if events[0].Exceptions != nil || len(p.Warnings) != 1 {
+		t.Errorf("warn: got exceptions %+v and warnings %q, want one warning only", events[0].Exceptions, p.Warnings)
+	}
+}
+
+func TestHolidayICSFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"calendars/school.ics": strings.Join([]string{
+			"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN",
+			"BEGIN:VEVENT", "UID:day@example.com", "SUMMARY:Teacher training day",
+			"DTSTART;VALUE=DATE:20270913", "DTEND;VALUE=DATE:20270914",
+			"END:VEVENT", "END:VCALENDAR",
+		}, "\r\n"),
+		"schedule.json": `{
+			"format": "single",
+			"holidays": {"calendar": "calendars/school.ics", "policy": "shift"},
+			"events": [{"name": "Club", "date": "2027-09-13", "start_time": "15:00"}]
+		}`,
+	})
+
+	// The calendar is found next to the template, whatever the working directory
+	p := newTestParser(t)
+	events, err := p.ParseFile(filepath.Join(dir, "schedule.json"), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventDays(events); len(got) != 1 || got[0] != "Club Sep 14" {
+		t.Errorf("got %v, want the Club moved to Sep 14", got)
+	}
+}
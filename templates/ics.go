@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	ical "github.com/arran4/golang-ical"
+	"github.com/monil/calendar-event-generator/exporter"
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// isICS reports whether data looks like an iCalendar file
+func isICS(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("BEGIN:VCALENDAR"))
+}
+
+// parseICS parses the VEVENTs of an iCalendar file. VEVENTs with a
+// RECURRENCE-ID become overrides of their recurring event, and cancelled
+// events are left out. Floating times and dates are read in the parser's
+// timezone; other times are shown in it. The defaults and vars of templates
+// including the file, and p.Vars, apply as for CSV files.
+func (p *Parser) parseICS(data []byte) ([]models.CalendarEvent, error) {
+	cal, err := ical.ParseCalendar(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse iCalendar data: %w", err)
+	}
+
+	values := p.loadValues(nil, nil)
+	var events []models.CalendarEvent
+	index := make(map[string]int) // UID -> position in events
+	var overrides []*ical.VEvent
+	for _, vevent := range cal.Events() {
+		if status := vevent.GetProperty(ical.ComponentPropertyStatus); status != nil && strings.EqualFold(status.Value, "CANCELLED") {
+			continue
+		}
+		if vevent.GetProperty(ical.ComponentPropertyRecurrenceId) != nil {
+			overrides = append(overrides, vevent)
+			continue
+		}
+
+		event, err := exporter.ParseEvent(vevent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert event '%s': %w", event.Name, err)
+		}
+		p.localizeICSEvent(&event)
+
+		// UIDs written by export identify events by their content already
+		uid := vevent.Id()
+		if !strings.HasSuffix(uid, "@calendar-generator") {
+			event.ID = uid
+		}
+
+		if uid != "" {
+			index[uid] = len(events)
+		}
+		events = append(events, event)
+	}
+
+	for _, vevent := range overrides {
+		i, ok := index[vevent.Id()]
+		if !ok {
+			p.warnf("exception to unknown event %s ignored", vevent.Id())
+			continue
+		}
+		master := &events[i]
+
+		o, err := exporter.ParseOverride(vevent, *master)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert event '%s': %w", master.Name, err)
+		}
+		o.OriginalStart = p.localizeICSTime(o.OriginalStart)
+		o.StartTime = p.localizeICSTime(o.StartTime)
+		o.EndTime = p.localizeICSTime(o.EndTime)
+
+		if master.Exceptions == nil {
+			master.Exceptions = &models.Exceptions{}
+		}
+		master.Exceptions.Overrides = append(master.Exceptions.Overrides, o)
+	}
+
+	for i := range events {
+		if err := values.apply(&events[i]); err != nil {
+			return nil, fmt.Errorf("failed to convert event '%s': %w", events[i].Name, err)
+		}
+		p.track("", events[i])
+	}
+	return events, nil
+}
+
+// localizeICSEvent moves the times of an event into the parser's timezone
+func (p *Parser) localizeICSEvent(e *models.CalendarEvent) {
+	e.StartTime = p.localizeICSTime(e.StartTime)
+	e.EndTime = p.localizeICSTime(e.EndTime)
+	if x := e.Exceptions; x != nil {
+		for i := range x.ExcludeDates {
+			x.ExcludeDates[i] = p.localizeICSTime(x.ExcludeDates[i])
+		}
+		for i := range x.ExtraDates {
+			x.ExtraDates[i] = p.localizeICSTime(x.ExtraDates[i])
+		}
+	}
+}
+
+// localizeICSTime keeps the wall clock of floating times and dates, which are
+// parsed as local time, and converts UTC times to the parser's timezone.
+// Times with a TZID keep their own zone.
+func (p *Parser) localizeICSTime(t time.Time) time.Time {
+	switch t.Location() {
+	case time.Local:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, p.TimeParser.Location)
+	case time.UTC:
+		return t.In(p.TimeParser.Location)
+	}
+	return t
+}
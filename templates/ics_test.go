@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestICS(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:lecture@example.edu",
+		"SUMMARY:Lecture",
+		"LOCATION:Hall A",
+		"DTSTART:20270301T090000Z",
+		"DTEND:20270301T100000Z",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"EXDATE:20270302T090000Z",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT10M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:lecture@example.edu",
+		"RECURRENCE-ID:20270303T090000Z",
+		"SUMMARY:Lecture",
+		"LOCATION:Hall B",
+		"DTSTART:20270303T130000Z",
+		"DTEND:20270303T140000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:social@example.edu",
+		"SUMMARY:Social",
+		"DTSTART:20270305T180000",
+		"DTEND:20270305T200000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:cancelled@example.edu",
+		"SUMMARY:Cancelled talk",
+		"STATUS:CANCELLED",
+		"DTSTART:20270306T090000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:orphan@example.edu",
+		"RECURRENCE-ID:20270306T090000Z",
+		"SUMMARY:Moved",
+		"DTSTART:20270306T100000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:0123abcd@calendar-generator",
+		"SUMMARY:Exported",
+		"DTSTART;VALUE=DATE:20270310",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	p, err := NewParser("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := p.Parse([]byte(data), FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(eventDays(events), ", "); got != "Lecture Mar 1, Social Mar 5, Exported Mar 10" {
+		t.Fatalf("got %s, want the Lecture, Social and Exported events", got)
+	}
+	if got, want := strings.Join(p.Warnings, "\n"), "exception to unknown event orphan@example.edu ignored"; got != want {
+		t.Errorf("got warnings %q, want %q", got, want)
+	}
+
+	// UTC times are shown in the parser's timezone
+	lecture := events[0]
+	if lecture.ID != "lecture@example.edu" || lecture.StartTime.Location().String() != "Europe/London" {
+		t.Errorf("got ID %q at %s, want the UID in Europe/London", lecture.ID, lecture.StartTime)
+	}
+	if got, want := lecture.Recurrence.ToRRuleString(), "RRULE:FREQ=DAILY;COUNT=5"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(lecture.Reminders) != 1 || lecture.Reminders[0].Minutes != 10 {
+		t.Errorf("got reminders %v, want one 10 minutes before", lecture.Reminders)
+	}
+	x := lecture.Exceptions
+	if x == nil || len(x.ExcludeDates) != 1 || x.ExcludeDates[0].Day() != 2 || len(x.Overrides) != 1 {
+		t.Fatalf("got exceptions %+v, want Mar 2 excluded and one override", x)
+	}
+	if o := x.Overrides[0]; o.OriginalStart.Day() != 3 || o.StartTime.Hour() != 13 || o.Location != "Hall B" {
+		t.Errorf("got override %+v, want Mar 3 moved to 13:00 in Hall B", o)
+	}
+
+	// Floating times keep their wall clock in the parser's timezone
+	london, _ := time.LoadLocation("Europe/London")
+	if social := events[1]; !social.StartTime.Equal(time.Date(2027, 3, 5, 18, 0, 0, 0, london)) {
+		t.Errorf("got %s, want 18:00 in London", social.StartTime)
+	}
+
+	// UIDs written by export are not kept as IDs
+	if exported := events[2]; exported.ID != "" || !exported.AllDay {
+		t.Errorf("got ID %q and all day %v, want no ID and all day", exported.ID, exported.AllDay)
+	}
+}
+
+func TestICSDetected(t *testing.T) {
+	data := "\xef\xbb\xbf\r\nBEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"
+	if got := newTestParser(t).detectFormat([]byte(data)); got != FormatICS {
+		t.Errorf("got %s, want %s", got, FormatICS)
+	}
+
+	_, err := newTestParser(t).Parse([]byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:No start\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), FormatICS)
+	if err == nil || !strings.Contains(err.Error(), "has no start time") {
+		t.Errorf("got %v, want an error about the missing start", err)
+	}
+}
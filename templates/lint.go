@@ -0,0 +1,418 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+	"github.com/monil/calendar-event-generator/recurrence"
+)
+
+// Lint rule names
+const (
+	RuleOverlap        = "overlap"
+	RuleEndBeforeStart = "end-before-start"
+	RuleDuration       = "duration"
+	RulePastDate       = "past-date"
+	RuleDuplicateName  = "duplicate-name"
+	RuleByDay          = "by-day"
+	RuleColorID        = "color-id"
+)
+
+// LintRule is a check of the schedule a template describes, beyond whether it can be read
+type LintRule struct {
+	Name        string
+	Description string
+	Severity    Severity
+}
+
+// LintRules are the rules checked by validate --lint, all enabled by default
+var LintRules = []LintRule{
+	{RuleOverlap, "Timed events that overlap each other", SeverityWarning},
+	{RuleEndBeforeStart, "Events that end before they start, or whose end time is read as the next day", SeverityError},
+	{RuleDuration, "Timed events of zero length or longer than 24 hours", SeverityWarning},
+	{RulePastDate, "Events that are over before today", SeverityWarning},
+	{RuleDuplicateName, "Events with the same name on the same day", SeverityWarning},
+	{RuleByDay, "by_day values written as abbreviations or in lower case rather than as RFC 5545 weekdays such as MO or 2TU", SeverityWarning},
+	{RuleColorID, "color_id values that are not Google Calendar event colors 1 to 11", SeverityWarning},
+}
+
+// LintRulesFor returns the enabled state of every lint rule, with the rules in
+// overrides switched on or off. Unknown rule names are an error.
+func LintRulesFor(overrides map[string]bool) (map[string]bool, error) {
+	rules := make(map[string]bool, len(LintRules))
+	for _, rule := range LintRules {
+		rules[rule.Name] = true
+	}
+	for name, enabled := range overrides {
+		if _, ok := rules[name]; !ok {
+			return nil, fmt.Errorf("unknown lint rule: %s (expected one of: %s)", name, strings.Join(lintRuleNames(), ", "))
+		}
+		rules[name] = enabled
+	}
+	return rules, nil
+}
+
+func lintRuleNames() []string {
+	names := make([]string, len(LintRules))
+	for i, rule := range LintRules {
+		names[i] = rule.Name
+	}
+	return names
+}
+
+// lintRule returns the rule with the given name
+func lintRule(name string) LintRule {
+	i := slices.IndexFunc(LintRules, func(r LintRule) bool { return r.Name == name })
+	return LintRules[i]
+}
+
+// trackedEvent is where an event was read from, for the position of lint diagnostics
+type trackedEvent struct {
+	name  string
+	start time.Time
+	src   *source
+	path  string
+	line  int // Line of the event in files without JSON paths
+}
+
+// track records where an event was read from, when linting
+func (p *Parser) track(path string, event models.CalendarEvent) {
+	if len(p.Lint) > 0 {
+		p.tracked = append(p.tracked, trackedEvent{name: event.Name, start: event.StartTime, src: p.src, path: path})
+	}
+}
+
+// trackLine records the line of a file an event was read from, when linting
+func (p *Parser) trackLine(line int, event models.CalendarEvent) {
+	if len(p.Lint) > 0 {
+		p.tracked = append(p.tracked, trackedEvent{name: event.Name, start: event.StartTime, src: p.src, line: line})
+	}
+}
+
+// linter reports the problems lint rules find in a parsed template
+type linter struct {
+	p      *Parser
+	events []models.CalendarEvent
+	at     []*trackedEvent // Where each event was read from, if known
+}
+
+// lint checks the parsed events of a template against the enabled lint rules
+func (p *Parser) lint(events []models.CalendarEvent) {
+	l := &linter{p: p, events: events, at: make([]*trackedEvent, len(events))}
+	for i, event := range events {
+		// Later templates override earlier ones, so look for the last event read
+		for j := len(p.tracked) - 1; j >= 0; j-- {
+			if t := &p.tracked[j]; t.name == event.Name && t.start.Equal(event.StartTime) {
+				l.at[i] = t
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	for i := range events {
+		l.checkEvent(i, &events[i], now)
+	}
+	if !p.Lint[RuleOverlap] && !p.Lint[RuleDuplicateName] {
+		return
+	}
+
+	// The rules across events compare every occurrence, in start order
+	var occurrences []timedOccurrence
+	for i := range events {
+		expanded, err := recurrence.Expand(&events[i], time.Time{}, time.Time{})
+		if err != nil {
+			continue
+		}
+		for _, o := range expanded {
+			occurrences = append(occurrences, timedOccurrence{event: i, Occurrence: o})
+		}
+	}
+	sort.SliceStable(occurrences, func(a, b int) bool { return occurrences[a].Start.Before(occurrences[b].Start) })
+
+	if p.Lint[RuleOverlap] {
+		l.checkOverlaps(occurrences)
+	}
+	if p.Lint[RuleDuplicateName] {
+		l.checkDuplicateNames(occurrences)
+	}
+}
+
+// timedOccurrence is an occurrence of the event at an index of linter.events
+type timedOccurrence struct {
+	event int
+	recurrence.Occurrence
+}
+
+// report records a diagnostic of a rule for the event at index i, at one of its fields
+func (l *linter) report(rule string, i int, field, message, suggestion string) {
+	l.p.Diagnostics = append(l.p.Diagnostics, l.diagnostic(rule, i, field, message, suggestion))
+}
+
+// reportPair records a diagnostic of a rule for the event at index i that
+// involves the event at index other, whose location is given as related
+func (l *linter) reportPair(rule string, i, other int, message, suggestion string) {
+	d := l.diagnostic(rule, i, "", message, suggestion)
+	if related := l.location(other, ""); related != (Location{}) {
+		d.Related = &related
+	}
+	l.p.Diagnostics = append(l.p.Diagnostics, d)
+}
+
+// diagnostic returns a diagnostic of a rule for the event at index i, at one of its fields
+func (l *linter) diagnostic(rule string, i int, field, message, suggestion string) Diagnostic {
+	at := l.location(i, field)
+	return Diagnostic{
+		Severity:   lintRule(rule).Severity,
+		Rule:       rule,
+		File:       at.File,
+		Path:       at.Path,
+		Line:       at.Line,
+		Column:     at.Column,
+		Message:    fmt.Sprintf("'%s' %s", l.events[i].Name, message),
+		Suggestion: suggestion,
+	}
+}
+
+// location returns where a field of the event at index i was read from, as far as known
+func (l *linter) location(i int, field string) Location {
+	var at Location
+	if t := l.at[i]; t != nil {
+		if t.src != nil {
+			at.File = t.src.name
+		}
+		if t.path != "" {
+			at.Path = joinPath(t.path, field)
+			if t.src != nil {
+				at.Line, at.Column = t.src.locate(at.Path)
+			}
+		}
+		if t.line > 0 {
+			at.Line, at.Column = t.line, 1
+		}
+	}
+	return at
+}
+
+// checkEvent applies the rules that look at one event at a time
+func (l *linter) checkEvent(i int, event *models.CalendarEvent, now time.Time) {
+	rules := l.p.Lint
+	length := event.EndTime.Sub(event.StartTime)
+
+	switch {
+	case length < 0 && rules[RuleEndBeforeStart]:
+		l.report(RuleEndBeforeStart, i, "", fmt.Sprintf("ends at %s, before it starts at %s",
+			event.EndTime.Format("Jan 2, 2006 15:04"), event.StartTime.Format("Jan 2, 2006 15:04")),
+			"check the end date and time")
+	case length < 0 || event.AllDay || !rules[RuleDuration]:
+	case length == 0:
+		l.report(RuleDuration, i, "", "has no length", "set an end_time or a duration")
+	case length > 24*time.Hour:
+		l.report(RuleDuration, i, "", fmt.Sprintf("lasts %s, more than 24 hours", formatDuration(length)),
+			"make it an all-day event or split it into one event per day")
+	}
+
+	if event.ColorID != "" && rules[RuleColorID] {
+		if n, err := strconv.Atoi(event.ColorID); err != nil || n < 1 || n > 11 {
+			l.report(RuleColorID, i, "color_id", fmt.Sprintf("has color_id %q, which is not a Google Calendar event color", event.ColorID),
+				"use a color_id from 1 to 11")
+		}
+	}
+
+	if rules[RulePastDate] {
+		if end, ok := lastEnd(event); ok && end.Before(now) {
+			l.report(RulePastDate, i, "", fmt.Sprintf("is over, on %s", end.Format("Jan 2, 2006")),
+				"update the dates or remove the event")
+		}
+	}
+}
+
+// lastEnd returns the end of the last occurrence of an event, or false for series without end
+func lastEnd(event *models.CalendarEvent) (time.Time, bool) {
+	if r := event.Recurrence; r != nil && r.Count == 0 && r.Until == nil {
+		return time.Time{}, false
+	}
+	occurrences, err := recurrence.Expand(event, time.Time{}, time.Time{})
+	if err != nil || len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	end := occurrences[0].End
+	for _, o := range occurrences[1:] {
+		if o.End.After(end) {
+			end = o.End
+		}
+	}
+	return end, true
+}
+
+// checkOverlaps reports each pair of timed events with overlapping
+// occurrences once, at the later event. Occurrences are in start order.
+func (l *linter) checkOverlaps(occurrences []timedOccurrence) {
+	reported := make(map[[2]int]bool)
+	var active []timedOccurrence
+	for _, o := range occurrences {
+		if l.events[o.event].AllDay {
+			continue
+		}
+
+		active = slices.DeleteFunc(active, func(a timedOccurrence) bool { return !a.End.After(o.Start) })
+		for _, a := range active {
+			pair := [2]int{min(a.event, o.event), max(a.event, o.event)}
+			if a.event == o.event || reported[pair] {
+				continue
+			}
+			reported[pair] = true
+			l.reportPair(RuleOverlap, o.event, a.event, fmt.Sprintf("overlaps '%s' on %s (%s-%s and %s-%s)",
+				l.events[a.event].Name, o.Start.Format("Jan 2, 2006"),
+				a.Start.Format("15:04"), a.End.Format("15:04"), o.Start.Format("15:04"), o.End.Format("15:04")),
+				"move one of the events")
+		}
+		active = append(active, o)
+	}
+}
+
+// checkDuplicateNames reports each pair of events of the same name with
+// occurrences on the same day once, at the later event
+func (l *linter) checkDuplicateNames(occurrences []timedOccurrence) {
+	reported := make(map[[2]int]bool)
+	first := make(map[string]int) // Name and day -> event with an occurrence then
+	for _, o := range occurrences {
+		key := l.events[o.event].Name + "\x00" + o.Start.Format("2006-01-02")
+		other, ok := first[key]
+		if !ok {
+			first[key] = o.event
+			continue
+		}
+
+		pair := [2]int{min(other, o.event), max(other, o.event)}
+		if other == o.event || reported[pair] {
+			continue
+		}
+		reported[pair] = true
+		l.reportPair(RuleDuplicateName, o.event, other, fmt.Sprintf("is on %s, as is another event of the same name",
+			o.Start.Format("Jan 2, 2006")),
+			"rename one of the events, or give them ids if both are intended")
+	}
+}
+
+// lintData checks the rules that look at the values written in a template
+// rather than the events read from it
+func (p *Parser) lintData(data []byte) {
+	if !p.Lint[RuleByDay] && !p.Lint[RuleEndBeforeStart] {
+		return
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return
+	}
+	if p.Lint[RuleByDay] {
+		p.lintByDay("$", doc)
+	}
+	if p.Lint[RuleEndBeforeStart] {
+		p.lintRollover("$", doc)
+	}
+}
+
+// lintRollover reports end times written before the start time of the same
+// day, which the parsers read as the next day: a mistake unless the event runs
+// past midnight. Events with an end_date are checked once read.
+func (p *Parser) lintRollover(path string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		start, _ := v["start_time"].(string)
+		end, _ := v["end_time"].(string)
+		timeRange, _ := v["time"].(string)
+		if _, ok := v["end_date"]; !ok {
+			switch {
+			case start != "" && end != "" && p.rollsOver(start, end):
+				p.reportRollover(Diagnostic{Path: joinPath(path, "end_time")}, end, start)
+			case timeRange != "":
+				if sh, sm, eh, em, err := p.TimeParser.ParseTimeRange(timeRange); err == nil && eh*60+em < sh*60+sm {
+					p.reportRollover(Diagnostic{Path: joinPath(path, "time")}, fmt.Sprintf("%02d:%02d", eh, em), fmt.Sprintf("%02d:%02d", sh, sm))
+				}
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			p.lintRollover(joinPath(path, key), v[key])
+		}
+	case []any:
+		for i, item := range v {
+			p.lintRollover(fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	}
+}
+
+// rollsOver reports whether an end time is before a start time on the same day
+func (p *Parser) rollsOver(start, end string) bool {
+	sh, sm, err := p.TimeParser.ParseTime(start)
+	if err != nil {
+		return false
+	}
+	eh, em, err := p.TimeParser.ParseTime(end)
+	return err == nil && eh*60+em < sh*60+sm
+}
+
+// reportRollover reports an end time read as the next day, at the position in d
+func (p *Parser) reportRollover(d Diagnostic, end, start string) {
+	d.Severity = SeverityWarning
+	d.Rule = RuleEndBeforeStart
+	d.Message = fmt.Sprintf("end time %s is before start time %s, so the event ends the next day", end, start)
+	d.Suggestion = "check the end time; for events that run past midnight, this can be ignored"
+	p.report(d)
+}
+
+// lintByDay reports by_day values that normalizeDay had to read as a weekday
+// because they are abbreviated, such as "mon", or not in upper case, such as
+// "Tu". Full day names are fine; values it cannot read at all are errors of the
+// event they are in.
+func (p *Parser) lintByDay(path string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			days, ok := v[key].([]any)
+			if key != "by_day" || !ok {
+				p.lintByDay(joinPath(path, key), v[key])
+				continue
+			}
+			for i, day := range days {
+				s, ok := day.(string)
+				if !ok {
+					continue
+				}
+				normalized, ok := normalizeDay(s)
+				if !ok || normalized == s || isDayName(s) {
+					continue
+				}
+				p.report(Diagnostic{
+					Severity:   lintRule(RuleByDay).Severity,
+					Rule:       RuleByDay,
+					Path:       fmt.Sprintf("%s.by_day[%d]", path, i),
+					Message:    fmt.Sprintf("by_day value %q is not an RFC 5545 weekday; it is read as %s", s, normalized),
+					Suggestion: "write " + normalized,
+				})
+			}
+		}
+	case []any:
+		for i, item := range v {
+			p.lintByDay(fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	}
+}
+
+// isDayName reports whether a by_day value is a full day name such as
+// "friday" or "2 Tuesday", in any case
+func isDayName(day string) bool {
+	name := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(day), "+-0123456789"))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) {
+			return true
+		}
+	}
+	return false
+}
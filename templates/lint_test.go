@@ -0,0 +1,181 @@
+package templates
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lintFindings formats the diagnostics of lint rules as "rule path" for comparison
+func lintFindings(diagnostics []Diagnostic) string {
+	var list []string
+	for _, d := range diagnostics {
+		if d.Rule != "" {
+			list = append(list, d.Rule+" "+d.Path)
+		}
+	}
+	return strings.Join(list, "\n")
+}
+
+// newLintParser returns a test parser with every lint rule on but those switched off
+func newLintParser(t *testing.T, overrides map[string]bool) *Parser {
+	t.Helper()
+	rules, err := LintRulesFor(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestParser(t)
+	p.Lint = rules
+	return p
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string // Findings, as formatted by lintFindings
+	}{
+		{
+			"overlap",
+			`{"format": "single", "events": [
+				{"name": "Lecture", "date": "2027-03-01", "start_time": "10:00", "end_time": "11:00"},
+				{"name": "Lab", "date": "2027-03-01", "start_time": "10:30", "end_time": "11:30"},
+				{"name": "Lunch", "date": "2027-03-01", "start_time": "11:30", "end_time": "12:30"}
+			]}`,
+			"overlap $.events[1]",
+		},
+		{
+			"overlap of occurrences",
+			`{"format": "recurring", "events": [
+				{"name": "Standup", "start_date": "2027-03-01", "start_time": "09:00", "duration": "30m", "recurrence": {"frequency": "DAILY", "count": 5}},
+				{"name": "Review", "start_date": "2027-03-05", "start_time": "09:15", "duration": "1h", "recurrence": {"frequency": "WEEKLY", "count": 2}}
+			]}`,
+			"overlap $.events[1]",
+		},
+		{
+			"end before start",
+			`{"format": "single", "events": [
+				{"name": "Late shift", "date": "2027-03-01", "start_time": "22:00", "end_time": "06:00"}
+			]}`,
+			"end-before-start $.events[0].end_time",
+		},
+		{
+			"duration",
+			`{"format": "single", "events": [
+				{"name": "Marathon", "date": "2027-03-01", "start_time": "09:00", "duration": "30h"},
+				{"name": "Offsite", "date": "2027-03-02", "all_day": true}
+			]}`,
+			"duration $.events[0]",
+		},
+		{
+			"past date",
+			`{"format": "recurring", "events": [
+				{"name": "Old", "start_date": "2020-03-01", "start_time": "09:00", "recurrence": {"frequency": "DAILY", "count": 2}},
+				{"name": "Forever", "start_date": "2020-03-01", "start_time": "12:00", "recurrence": {"frequency": "DAILY"}}
+			]}`,
+			"past-date $.events[0]",
+		},
+		{
+			"duplicate name",
+			`{"format": "single", "events": [
+				{"name": "Lab", "date": "2027-03-01", "start_time": "09:00"},
+				{"name": "Lab", "date": "2027-03-01", "start_time": "14:00"},
+				{"name": "Lab", "date": "2027-03-02", "start_time": "09:00"}
+			]}`,
+			"duplicate-name $.events[1]",
+		},
+		{
+			"by day",
+			`{"format": "recurring", "events": [
+				{"name": "Lab", "start_date": "2027-03-01", "start_time": "09:00", "recurrence": {"frequency": "MONTHLY", "count": 6,
+					"by_day": ["MO", "friday", "2 Tuesday", "mon", "Tu", "-1fr"]}}
+			]}`,
+			"by-day $.events[0].recurrence.by_day[3]\nby-day $.events[0].recurrence.by_day[4]\nby-day $.events[0].recurrence.by_day[5]",
+		},
+		{
+			"color id",
+			`{"format": "single", "events": [
+				{"name": "A", "date": "2027-03-01", "start_time": "09:00", "color_id": "11"},
+				{"name": "B", "date": "2027-03-02", "start_time": "09:00", "color_id": "12"},
+				{"name": "C", "date": "2027-03-03", "start_time": "09:00", "color_id": "red"}
+			]}`,
+			"color-id $.events[1].color_id\ncolor-id $.events[2].color_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newLintParser(t, nil)
+			parse(t, p, tt.data)
+			p.sortDiagnostics()
+			if got := lintFindings(p.Diagnostics); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintByDayMessage(t *testing.T) {
+	p := newLintParser(t, nil)
+	parse(t, p, `{"format": "recurring", "events": [
+		{"name": "Lab", "start_date": "2027-03-01", "start_time": "09:00", "recurrence": {"frequency": "MONTHLY", "count": 2, "by_day": ["2tue"]}}
+	]}`)
+	if len(p.Diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(p.Diagnostics))
+	}
+	d := p.Diagnostics[0]
+	if d.Severity != SeverityWarning || d.Message != `by_day value "2tue" is not an RFC 5545 weekday; it is read as 2TU` || d.Suggestion != "write 2TU" {
+		t.Errorf("got %s %q with %q, want a warning to write 2TU", d.Severity, d.Message, d.Suggestion)
+	}
+}
+
+func TestLintRulesFor(t *testing.T) {
+	data := `{"format": "single", "events": [
+		{"name": "Lab", "date": "2020-03-01", "start_time": "09:00", "color_id": "12"},
+		{"name": "Lab", "date": "2020-03-01", "start_time": "09:30", "color_id": "12"}
+	]}`
+
+	p := newLintParser(t, map[string]bool{RulePastDate: false, RuleColorID: false})
+	parse(t, p, data)
+	p.sortDiagnostics()
+	if got, want := lintFindings(p.Diagnostics), "overlap $.events[1]\nduplicate-name $.events[1]"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Without lint rules nothing is checked
+	p = newTestParser(t)
+	parse(t, p, data)
+	if len(p.Diagnostics) != 0 {
+		t.Errorf("got %v, want no diagnostics", p.Diagnostics)
+	}
+
+	_, err := LintRulesFor(map[string]bool{"overlaps": true})
+	if err == nil || !strings.HasPrefix(err.Error(), "unknown lint rule: overlaps (expected one of: overlap, end-before-start,") {
+		t.Errorf("got %v, want an unknown rule error", err)
+	}
+	if rules, _ := LintRulesFor(nil); len(rules) != len(LintRules) {
+		t.Errorf("got %d rules, want all %d on", len(rules), len(LintRules))
+	}
+}
+
+func TestLintRelated(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"course.json": `{"format": "single", "events": [
+  {"name": "Lecture", "date": "2027-03-01", "start_time": "10:00", "end_time": "11:00"},
+  {"name": "Lab", "date": "2027-03-01", "start_time": "10:30", "end_time": "11:30"}
+]}`})
+
+	p := newLintParser(t, nil)
+	if _, err := p.ParseFile(filepath.Join(dir, "course.json"), FormatAuto); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(p.Diagnostics))
+	}
+	d := p.Diagnostics[0]
+	if d.Line != 3 || d.Related == nil || d.Related.Line != 2 || d.Related.Path != "$.events[0]" {
+		t.Errorf("got %s, want the overlap at line 3 related to line 2", d)
+	}
+	if want := "'Lab' overlaps 'Lecture' on Mar 1, 2027 (10:00-11:00 and 10:30-11:30)"; d.Message != want {
+		t.Errorf("got %q, want %q", d.Message, want)
+	}
+}
@@ -0,0 +1,92 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// toJSON converts YAML (.yaml, .yml) and TOML (.toml) templates to JSON, so
+// they map onto the same template structures and format detection as JSON
+// templates. Other files are returned unchanged.
+func toJSON(filename string, data []byte) ([]byte, error) {
+	var doc any
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	case ".toml":
+		var table map[string]any
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML: %w", err)
+		}
+		doc = table
+	default:
+		return data, nil
+	}
+
+	return json.Marshal(normalize(doc))
+}
+
+// normalize turns decoded YAML and TOML values into values the JSON
+// templates would hold: maps get string keys and unquoted dates and times
+// become the strings the template parsers expect
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case []map[string]any: // TOML arrays of tables
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	case time.Time:
+		return formatTime(v)
+	}
+	return value
+}
+
+// formatTime formats an unquoted YAML or TOML date, time or date-time
+func formatTime(t time.Time) string {
+	clock := "15:04"
+	if t.Second() != 0 {
+		clock = "15:04:05"
+	}
+
+	// TOML marks local values with these locations
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format(clock)
+	case "datetime-local":
+		return t.Format("2006-01-02T" + clock)
+	}
+
+	// YAML dates without a time decode as midnight UTC
+	if t.Location() == time.UTC && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,170 @@
+package templates
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkupMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		yaml  string
+		toml  string
+		count int
+	}{
+		{
+			name: "recurring",
+			json: `{"format": "recurring", "events": [{
+				"name": "Review", "start_date": "2027-03-05", "start_time": "14:00", "duration": "1h",
+				"recurrence": {"frequency": "WEEKLY", "by_day": ["FR"], "count": 4}
+			}]}`,
+			yaml: `
+# Unquoted dates are read as strings
+format: recurring
+events:
+  - name: Review
+    start_date: 2027-03-05
+    start_time: "14:00"
+    duration: 1h
+    recurrence:
+      frequency: WEEKLY
+      by_day: [FR]
+      count: 4
+`,
+			toml: `
+format = "recurring"
+
+[[events]]
+name = "Review"
+start_date = 2027-03-05
+start_time = 14:00:00
+duration = "1h"
+recurrence = { frequency = "WEEKLY", by_day = ["FR"], count = 4 }
+`,
+			count: 1,
+		},
+		{
+			// Weekly templates have no format field and are detected by their keys
+			name: "weekly",
+			json: `{"week_1": [
+				{"event_name": "Lab", "date": "2027-03-01", "time": "11:00am – 1:00pm", "topic_details": "Setup", "useful_links": ["https://example.com/lab"]},
+				{"event_name": "Lab", "date": "2027-03-03", "time": "2:00pm – 3:00pm", "topic_details": "Review"}
+			]}`,
+			yaml: `
+week_1:
+  - event_name: Lab
+    date: 2027-03-01
+    time: 11:00am – 1:00pm
+    topic_details: Setup
+    useful_links:
+      - https://example.com/lab
+  - event_name: Lab
+    date: 2027-03-03
+    time: 2:00pm – 3:00pm
+    topic_details: Review
+`,
+			toml: `
+[[week_1]]
+event_name = "Lab"
+date = 2027-03-01
+time = "11:00am – 1:00pm"
+topic_details = "Setup"
+useful_links = ["https://example.com/lab"]
+
+[[week_1]]
+event_name = "Lab"
+date = 2027-03-03
+time = "2:00pm – 3:00pm"
+topic_details = "Review"
+`,
+			count: 2,
+		},
+		{
+			name: "daterange",
+			json: `{"format": "daterange", "events": [
+				{"name": "Offsite", "start_date": "2027-01-15", "end_date": "2027-01-17", "all_day": true}
+			]}`,
+			yaml: `
+format: daterange
+events:
+  - {name: Offsite, start_date: 2027-01-15, end_date: 2027-01-17, all_day: true}
+`,
+			toml: `
+format = "daterange"
+events = [{ name = "Offsite", start_date = 2027-01-15, end_date = 2027-01-17, all_day = true }]
+`,
+			count: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{
+				"template.json": tt.json,
+				"template.yaml": tt.yaml,
+				"template.yml":  tt.yaml,
+				"template.toml": tt.toml,
+			})
+
+			var want string
+			for _, name := range []string{"template.json", "template.yaml", "template.yml", "template.toml"} {
+				events, err := newTestParser(t).ParseFile(filepath.Join(dir, name), FormatAuto)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if len(events) != tt.count {
+					t.Errorf("%s: got %d events, want %d", name, len(events), tt.count)
+				}
+				got, err := json.Marshal(events)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want == "" {
+					want = string(got)
+				} else if string(got) != want {
+					t.Errorf("%s: got %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkupTimes(t *testing.T) {
+	tests := []struct {
+		file string
+		data string
+		want string
+	}{
+		{"a.yaml", "date: 2027-03-05\nat: 2027-03-05T09:30:00Z\ntime: 09:30", `{"at":"2027-03-05T09:30:00Z","date":"2027-03-05","time":"09:30"}`},
+		{"a.toml", "date = 2027-03-05\ntime = 09:30:00\nlocal = 2027-03-05T09:30:15", `{"date":"2027-03-05","local":"2027-03-05T09:30:15","time":"09:30"}`},
+		{"a.yml", "1: one\ntrue: yes", `{"1":"one","true":"yes"}`},
+		{"a.json", `{"date": 2027}`, `{"date": 2027}`},
+	}
+
+	for _, tt := range tests {
+		got, err := toJSON(tt.file, []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestMarkupErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.yaml": "format: recurring\nevents: [unclosed",
+		"bad.toml": "format = recurring",
+	})
+	for name, want := range map[string]string{"bad.yaml": "failed to parse YAML", "bad.toml": "failed to parse TOML"} {
+		_, err := newTestParser(t).ParseFile(filepath.Join(dir, name), FormatAuto)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", name, err, want)
+		}
+	}
+}
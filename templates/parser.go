@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/monil/calendar-event-generator/models"
@@ -18,12 +19,29 @@ const (
 	FormatSingle    TemplateFormat = "single"
 	FormatRecurring TemplateFormat = "recurring"
 	FormatDateRange TemplateFormat = "daterange"
+	FormatCSV       TemplateFormat = "csv"
+	FormatICS       TemplateFormat = "ics"
+	FormatBundle    TemplateFormat = "bundle"
 	FormatAuto      TemplateFormat = "auto"
 )
 
 // Parser is the main template parser that routes to specific parsers
 type Parser struct {
-	TimeParser *utils.TimeParser
+	TimeParser  *utils.TimeParser
+	Warnings    []string          // Problems found by the last Parse that did not stop it
+	Diagnostics []Diagnostic      // Every problem found by the last Parse, with where it was found
+	CSVColumns  map[string]string // CSV field -> column header, for headers not recognised by name
+	Vars        map[string]string // Template variables, overriding the template's vars
+	Lint        map[string]bool   // Lint rules checked after parsing, by name; see LintRules
+	Strict      bool              // Reject template keys that no field is read from
+	CheckSchema bool              // Check templates against the JSON Schema of their format before reading them
+
+	baseDir           string         // Directory of the template being parsed, for relative paths
+	files             []string       // Absolute paths of the templates being parsed, outermost first
+	inherited         VarsInput      // Vars of the templates extending or including the one being parsed
+	inheritedDefaults *DefaultsInput // Defaults of those templates
+	src               *source        // Template file being parsed, for the position of diagnostics
+	tracked           []trackedEvent
 }
 
 // NewParser creates a new template parser
@@ -43,22 +61,115 @@ func NewParser(timezone string) (*Parser, error) {
 	return &Parser{TimeParser: tp}, nil
 }
 
-// ParseFile reads and parses a JSON file, auto-detecting the format
+// ParseFile reads and parses a JSON, YAML, TOML, CSV or iCalendar file, auto-detecting the format.
+// Templates it extends or includes are parsed with it.
 func (p *Parser) ParseFile(filename string, format TemplateFormat) ([]models.CalendarEvent, error) {
+	p.Warnings = nil
+	p.Diagnostics = nil
+	p.tracked = nil
+	defer func() { p.baseDir = "" }()
+
+	events, err := p.parseFile(filename, format)
+	if err != nil && p.errorSince(0) == nil {
+		p.report(Diagnostic{Severity: SeverityError, File: filename, Message: err.Error(), err: err})
+	}
+	if err == nil && len(p.Lint) > 0 {
+		p.lint(events)
+	}
+	p.sortDiagnostics()
+	return events, err
+}
+
+// parseFile parses a template file and the templates it extends or includes.
+// Problems found once the file is read are recorded as diagnostics of the file.
+func (p *Parser) parseFile(filename string, format TemplateFormat) ([]models.CalendarEvent, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	p.files = append(p.files, abs)
+	defer func() { p.files = p.files[:len(p.files)-1] }()
+
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
 
-	return p.Parse(data, format)
+	src := p.src
+	p.src = newSource(filename, data)
+	defer func() { p.src = src }()
+
+	since := len(p.Diagnostics)
+	events, err := p.parseData(filename, data, format)
+	if err != nil && p.errorSince(since) == nil {
+		p.fail("$", err)
+	}
+	return events, err
+}
+
+// parseData parses the content of a template file
+func (p *Parser) parseData(filename string, data []byte, format TemplateFormat) ([]models.CalendarEvent, error) {
+	data, err := toJSON(filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+
+	if (format == FormatAuto || format == "") && strings.EqualFold(filepath.Ext(filename), ".csv") {
+		format = FormatCSV
+	}
+
+	return p.compose(filename, data, func() ([]models.CalendarEvent, error) {
+		p.baseDir = filepath.Dir(filename)
+		return p.parse(data, format)
+	})
 }
 
 // Parse parses JSON data, auto-detecting the format if not specified
 func (p *Parser) Parse(data []byte, format TemplateFormat) ([]models.CalendarEvent, error) {
+	p.Warnings = nil
+	p.Diagnostics = nil
+	p.tracked = nil
+
+	events, err := p.parse(data, format)
+	if err == nil && len(p.Lint) > 0 {
+		p.lint(events)
+	}
+	return events, err
+}
+
+// parse parses the data of a single template. The format parsers record
+// problems with single events as diagnostics and go on with the next event;
+// parse fails if any were recorded.
+func (p *Parser) parse(data []byte, format TemplateFormat) ([]models.CalendarEvent, error) {
+	since := len(p.Diagnostics)
 	if format == FormatAuto || format == "" {
 		format = p.detectFormat(data)
 	}
+	if p.CheckSchema {
+		p.checkSchema(data, format)
+	}
+	if p.Strict {
+		p.checkStrict(data, format)
+	}
+	if len(p.Lint) > 0 {
+		p.lintData(data)
+	}
+
+	events, err := p.parseFormat(data, format)
+	if err != nil {
+		if p.errorSince(since) == nil {
+			p.fail("$", err)
+		}
+		return nil, err
+	}
+	if err := p.errorSince(since); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
 
+// parseFormat parses template data with the parser for its format
+func (p *Parser) parseFormat(data []byte, format TemplateFormat) ([]models.CalendarEvent, error) {
 	switch format {
 	case FormatWeekly:
 		return p.parseWeekly(data)
@@ -68,13 +179,30 @@ func (p *Parser) Parse(data []byte, format TemplateFormat) ([]models.CalendarEve
 		return p.parseRecurring(data)
 	case FormatDateRange:
 		return p.parseDateRange(data)
+	case FormatBundle:
+		return p.parseBundle(data)
+	case FormatCSV:
+		return p.parseCSV(data)
+	case FormatICS:
+		return p.parseICS(data)
 	default:
 		return nil, fmt.Errorf("unknown template format: %s", format)
 	}
 }
 
+// warnf records a warning for the template being parsed
+func (p *Parser) warnf(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	p.Warnings = append(p.Warnings, message)
+	p.report(Diagnostic{Severity: SeverityWarning, Message: message})
+}
+
 // detectFormat attempts to auto-detect the JSON template format
 func (p *Parser) detectFormat(data []byte) TemplateFormat {
+	if isICS(data) {
+		return FormatICS
+	}
+
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return FormatSingle // Default fallback
@@ -88,6 +216,13 @@ func (p *Parser) detectFormat(data []byte) TemplateFormat {
 		}
 	}
 
+	// Bundles have a section per format
+	for _, section := range bundleSections {
+		if _, ok := raw[section]; ok {
+			return FormatBundle
+		}
+	}
+
 	// Check for week_* keys (weekly format)
 	for key := range raw {
 		if strings.HasPrefix(strings.ToLower(key), "week_") ||
@@ -97,19 +232,18 @@ func (p *Parser) detectFormat(data []byte) TemplateFormat {
 		}
 	}
 
-	// Check for events array with recurrence
-	if eventsRaw, ok := raw["events"]; ok {
-		var events []map[string]json.RawMessage
-		if err := json.Unmarshal(eventsRaw, &events); err == nil && len(events) > 0 {
-			// Check first event for recurrence field
-			if _, hasRecurrence := events[0]["recurrence"]; hasRecurrence {
-				return FormatRecurring
-			}
-			// Check for date range fields
-			if _, hasEndDate := events[0]["end_date"]; hasEndDate {
-				return FormatDateRange
+	// Check every event for recurrence and date range fields; lists
+	// mixing formats are read as bundles
+	if formats := eventFormats(data); len(formats) > 0 {
+		for _, f := range formats[1:] {
+			if f != formats[0] {
+				return FormatBundle
 			}
 		}
+		if formats[0] == FormatWeekly {
+			return FormatBundle
+		}
+		return formats[0]
 	}
 
 	return FormatSingle
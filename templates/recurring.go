@@ -11,32 +11,60 @@ import (
 
 // RecurrenceInput represents recurrence settings in the JSON
 type RecurrenceInput struct {
-	Frequency       string   `json:"frequency"` // DAILY, WEEKLY, MONTHLY, YEARLY
+	Frequency       string   `json:"frequency" schema:"required"` // DAILY, WEEKLY, MONTHLY, YEARLY
 	Interval        int      `json:"interval,omitempty"`
 	Until           string   `json:"until,omitempty"`
 	Count           int      `json:"count,omitempty"`
-	ByDay           []string `json:"by_day,omitempty"`
+	ByDay           []string `json:"by_day,omitempty"` // e.g. "FR", "friday", "2TU", "-1FR"
+	ByMonthDay      []int    `json:"by_month_day,omitempty"`
+	ByMonth         []int    `json:"by_month,omitempty"`
+	BySetPos        []int    `json:"by_set_pos,omitempty"`
+	ByYearDay       []int    `json:"by_year_day,omitempty"`
+	ByWeekNo        []int    `json:"by_week_no,omitempty"`
+	WeekStart       string   `json:"week_start,omitempty"`
 	ExcludeWeekends bool     `json:"exclude_weekends,omitempty"`
 }
 
 // RecurringEventInput represents a recurring event in the JSON
 type RecurringEventInput struct {
+	ID          string          `json:"id,omitempty"`
 	Name        string          `json:"name"`
 	StartDate   string          `json:"start_date,omitempty"` // Optional start date
-	StartTime   string          `json:"start_time"`
+	StartTime   string          `json:"start_time" schema:"required"`
 	EndTime     string          `json:"end_time,omitempty"`
 	Duration    string          `json:"duration,omitempty"`
 	Description string          `json:"description,omitempty"`
 	Location    string          `json:"location,omitempty"`
 	Links       []string        `json:"links,omitempty"`
-	Recurrence  RecurrenceInput `json:"recurrence"`
+	Recurrence  RecurrenceInput `json:"recurrence" schema:"required"`
 	ColorID     string          `json:"color_id,omitempty"`
+
+	ExcludeDates []string        `json:"exclude_dates,omitempty"` // Occurrences to skip
+	ExtraDates   []string        `json:"extra_dates,omitempty"`   // Extra occurrences at the usual time
+	Overrides    []OverrideInput `json:"overrides,omitempty"`     // One-off changes to single occurrences
+
+	HolidayPolicy string `json:"holiday_policy,omitempty"` // Overrides the template's holiday policy
+}
+
+// OverrideInput changes a single occurrence of a recurring event
+type OverrideInput struct {
+	Date        string `json:"date" schema:"required"` // Date of the occurrence to change
+	StartTime   string `json:"start_time,omitempty"`   // Defaults to the series start time
+	EndTime     string `json:"end_time,omitempty"`
+	Duration    string `json:"duration,omitempty"` // Defaults to the series duration
+	Location    string `json:"location,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 // RecurringTemplate represents the recurring events template format
 type RecurringTemplate struct {
-	Format string                `json:"format"`
-	Events []RecurringEventInput `json:"events"`
+	Format   string                `json:"format"`
+	Holidays *HolidayInput         `json:"holidays,omitempty"`
+	Defaults *DefaultsInput        `json:"defaults,omitempty"`
+	Vars     VarsInput             `json:"vars,omitempty"`
+	Extends  string                `json:"extends,omitempty"`
+	Include  []string              `json:"include,omitempty"`
+	Events   []RecurringEventInput `json:"events"`
 }
 
 // parseRecurring parses the recurring event format
@@ -45,14 +73,37 @@ func (p *Parser) parseRecurring(data []byte) ([]models.CalendarEvent, error) {
 	if err := json.Unmarshal(data, &template); err != nil {
 		return nil, fmt.Errorf("failed to parse recurring events JSON: %w", err)
 	}
+	if err := checkEventFormats(data, FormatRecurring); err != nil {
+		return nil, err
+	}
+
+	holidays, err := p.loadHolidays(template.Holidays)
+	if err != nil {
+		return nil, err
+	}
+	values := p.loadValues(template.Defaults, template.Vars)
 
 	var events []models.CalendarEvent
-	for _, re := range template.Events {
+	for i, re := range template.Events {
+		path := fmt.Sprintf("$.events[%d]", i)
 		event, err := p.convertRecurringEvent(re)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert event '%s': %w", re.Name, err)
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", re.Name, err))
+			continue
+		}
+		if err := values.apply(&event); err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", re.Name, err))
+			continue
+		}
+		keep, err := p.applyHolidays(holidays, &event, re.HolidayPolicy)
+		if err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", re.Name, err))
+			continue
+		}
+		if keep {
+			p.track(path, event)
+			events = append(events, event)
 		}
-		events = append(events, event)
 	}
 
 	return events, nil
@@ -63,11 +114,11 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 	// Determine start date (use today if not specified)
 	var startDate time.Time
 	var err error
-	
+
 	if re.StartDate != "" {
 		startDate, err = p.TimeParser.ParseDate(re.StartDate)
 		if err != nil {
-			return models.CalendarEvent{}, fmt.Errorf("failed to parse start date: %w", err)
+			return models.CalendarEvent{}, inField("start_date", badValue(fmt.Errorf("failed to parse start date: %w", err)))
 		}
 	} else {
 		startDate = time.Now().In(p.TimeParser.Location)
@@ -78,7 +129,7 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 	// Parse start time
 	startHour, startMin, err := p.TimeParser.ParseTime(re.StartTime)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse start time: %w", err)
+		return models.CalendarEvent{}, inField("start_time", badValue(fmt.Errorf("failed to parse start time: %w", err)))
 	}
 	startTime := p.TimeParser.CombineDateTime(startDate, startHour, startMin)
 
@@ -87,7 +138,7 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 	if re.EndTime != "" {
 		endHour, endMin, err := p.TimeParser.ParseTime(re.EndTime)
 		if err != nil {
-			return models.CalendarEvent{}, fmt.Errorf("failed to parse end time: %w", err)
+			return models.CalendarEvent{}, inField("end_time", badValue(fmt.Errorf("failed to parse end time: %w", err)))
 		}
 		endTime = p.TimeParser.CombineDateTime(startDate, endHour, endMin)
 		if endTime.Before(startTime) {
@@ -96,7 +147,7 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 	} else if re.Duration != "" {
 		duration, err := p.TimeParser.ParseDuration(re.Duration)
 		if err != nil {
-			return models.CalendarEvent{}, fmt.Errorf("failed to parse duration: %w", err)
+			return models.CalendarEvent{}, inField("duration", badValue(fmt.Errorf("failed to parse duration: %w", err)))
 		}
 		endTime = startTime.Add(duration)
 	} else {
@@ -107,10 +158,16 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 	// Convert recurrence rule
 	recurrence, err := p.convertRecurrenceRule(re.Recurrence)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse recurrence rule: %w", err)
+		return models.CalendarEvent{}, inField("recurrence", fmt.Errorf("failed to parse recurrence rule: %w", err))
+	}
+
+	exceptions, err := p.convertExceptions(re, startTime, endTime)
+	if err != nil {
+		return models.CalendarEvent{}, err
 	}
 
 	return models.CalendarEvent{
+		ID:          re.ID,
 		Name:        re.Name,
 		Description: re.Description,
 		StartTime:   startTime,
@@ -118,28 +175,119 @@ func (p *Parser) convertRecurringEvent(re RecurringEventInput) (models.CalendarE
 		Location:    re.Location,
 		Links:       re.Links,
 		Recurrence:  recurrence,
+		Exceptions:  exceptions,
 		ColorID:     re.ColorID,
 	}, nil
 }
 
-// convertRecurrenceRule converts the input recurrence to a RecurrenceRule
-func (p *Parser) convertRecurrenceRule(ri RecurrenceInput) (*models.RecurrenceRule, error) {
-	frequency := strings.ToUpper(ri.Frequency)
-	validFrequencies := map[string]bool{
-		"DAILY":  true,
-		"WEEKLY": true,
-		"MONTHLY": true,
-		"YEARLY": true,
+// convertExceptions converts the excluded, extra and overridden occurrences of
+// a recurring event whose first occurrence runs from start to end
+func (p *Parser) convertExceptions(re RecurringEventInput, start, end time.Time) (*models.Exceptions, error) {
+	if len(re.ExcludeDates)+len(re.ExtraDates)+len(re.Overrides) == 0 {
+		return nil, nil
 	}
 
-	if !validFrequencies[frequency] {
-		return nil, fmt.Errorf("invalid frequency: %s", ri.Frequency)
+	exceptions := &models.Exceptions{}
+	used := make(map[string]string) // Occurrence date -> list it appears in
+
+	// occurrence returns the start of the occurrence on the given date
+	occurrence := func(field, value string) (time.Time, error) {
+		date, err := p.TimeParser.ParseDate(value)
+		if err != nil {
+			return time.Time{}, badValue(fmt.Errorf("failed to parse %s date: %w", field, err))
+		}
+		t := p.TimeParser.CombineDateTime(date, start.Hour(), start.Minute())
+		if t.Before(start) {
+			return time.Time{}, fmt.Errorf("%s date %s is before the first occurrence", field, value)
+		}
+
+		day := t.Format("2006-01-02")
+		if other, ok := used[day]; ok {
+			return time.Time{}, fmt.Errorf("%s date %s is already listed in %s", field, value, other)
+		}
+		used[day] = field
+		return t, nil
 	}
 
+	for i, value := range re.ExcludeDates {
+		t, err := occurrence("exclude_dates", value)
+		if err != nil {
+			return nil, inField("exclude_dates", inField(fmt.Sprintf("[%d]", i), err))
+		}
+		exceptions.ExcludeDates = append(exceptions.ExcludeDates, t)
+	}
+
+	for i, value := range re.ExtraDates {
+		t, err := occurrence("extra_dates", value)
+		if err != nil {
+			return nil, inField("extra_dates", inField(fmt.Sprintf("[%d]", i), err))
+		}
+		exceptions.ExtraDates = append(exceptions.ExtraDates, t)
+	}
+
+	for i, o := range re.Overrides {
+		// inOverride marks an error in a field of the override
+		inOverride := func(field string, err error) error {
+			return inField("overrides", inField(fmt.Sprintf("[%d]", i), inField(field, err)))
+		}
+
+		original, err := occurrence("overrides", o.Date)
+		if err != nil {
+			return nil, inOverride("date", err)
+		}
+
+		override := models.Override{
+			OriginalStart: original,
+			StartTime:     original,
+			Location:      o.Location,
+			Description:   o.Description,
+		}
+
+		if o.StartTime != "" {
+			hour, min, err := p.TimeParser.ParseTime(o.StartTime)
+			if err != nil {
+				return nil, inOverride("start_time", badValue(fmt.Errorf("failed to parse override start time: %w", err)))
+			}
+			override.StartTime = p.TimeParser.CombineDateTime(original, hour, min)
+		}
+
+		switch {
+		case o.EndTime != "":
+			hour, min, err := p.TimeParser.ParseTime(o.EndTime)
+			if err != nil {
+				return nil, inOverride("end_time", badValue(fmt.Errorf("failed to parse override end time: %w", err)))
+			}
+			override.EndTime = p.TimeParser.CombineDateTime(original, hour, min)
+			if override.EndTime.Before(override.StartTime) {
+				override.EndTime = override.EndTime.AddDate(0, 0, 1)
+			}
+		case o.Duration != "":
+			duration, err := p.TimeParser.ParseDuration(o.Duration)
+			if err != nil {
+				return nil, inOverride("duration", badValue(fmt.Errorf("failed to parse override duration: %w", err)))
+			}
+			override.EndTime = override.StartTime.Add(duration)
+		default:
+			override.EndTime = override.StartTime.Add(end.Sub(start))
+		}
+
+		exceptions.Overrides = append(exceptions.Overrides, override)
+	}
+
+	return exceptions, nil
+}
+
+// convertRecurrenceRule converts the input recurrence to a RecurrenceRule
+func (p *Parser) convertRecurrenceRule(ri RecurrenceInput) (*models.RecurrenceRule, error) {
 	rule := &models.RecurrenceRule{
-		Frequency:       frequency,
+		Frequency:       strings.ToUpper(ri.Frequency),
 		Interval:        ri.Interval,
 		Count:           ri.Count,
+		ByMonthDay:      ri.ByMonthDay,
+		ByMonth:         ri.ByMonth,
+		BySetPos:        ri.BySetPos,
+		ByYearDay:       ri.ByYearDay,
+		ByWeekNo:        ri.ByWeekNo,
 		ExcludeWeekends: ri.ExcludeWeekends,
 	}
 
@@ -151,7 +299,7 @@ func (p *Parser) convertRecurrenceRule(ri RecurrenceInput) (*models.RecurrenceRu
 	if ri.Until != "" {
 		until, err := p.TimeParser.ParseDate(ri.Until)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse until date: %w", err)
+			return nil, inField("until", badValue(fmt.Errorf("failed to parse until date: %w", err)))
 		}
 		// Set to end of day
 		until = time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, p.TimeParser.Location)
@@ -160,8 +308,19 @@ func (p *Parser) convertRecurrenceRule(ri RecurrenceInput) (*models.RecurrenceRu
 
 	// Normalize day names
 	for i, day := range ri.ByDay {
-		rule.ByDay = append(rule.ByDay, normalizeDay(day))
-		_ = i
+		normalized, ok := normalizeDay(day)
+		if !ok {
+			return nil, inField("by_day", inField(fmt.Sprintf("[%d]", i), badValue(fmt.Errorf("invalid day in by_day: %s", day))))
+		}
+		rule.ByDay = append(rule.ByDay, normalized)
+	}
+
+	if ri.WeekStart != "" {
+		weekStart, ok := normalizeDay(ri.WeekStart)
+		if !ok {
+			return nil, inField("week_start", badValue(fmt.Errorf("invalid week_start: %s", ri.WeekStart)))
+		}
+		rule.WeekStart = weekStart
 	}
 
 	// Handle exclude_weekends by setting ByDay
@@ -169,13 +328,22 @@ func (p *Parser) convertRecurrenceRule(ri RecurrenceInput) (*models.RecurrenceRu
 		rule.ByDay = []string{"MO", "TU", "WE", "TH", "FR"}
 	}
 
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
 	return rule, nil
 }
 
-// normalizeDay converts day names to two-letter format
-func normalizeDay(day string) string {
+// normalizeDay converts day names to two-letter format, keeping an ordinal
+// prefix such as the "-1" of "-1 friday". It reports false for unknown days.
+func normalizeDay(day string) (string, bool) {
 	day = strings.ToUpper(strings.TrimSpace(day))
-	
+
+	// Split off the ordinal
+	name := strings.TrimSpace(strings.TrimLeft(day, "+-0123456789"))
+	ordinal := strings.TrimSpace(day[:len(day)-len(strings.TrimLeft(day, "+-0123456789"))])
+
 	dayMap := map[string]string{
 		"MONDAY":    "MO",
 		"TUESDAY":   "TU",
@@ -193,10 +361,13 @@ func normalizeDay(day string) string {
 		"SUN":       "SU",
 	}
 
-	if normalized, ok := dayMap[day]; ok {
-		return normalized
+	if normalized, ok := dayMap[name]; ok {
+		name = normalized
+	}
+
+	// Already in short format, or unknown
+	if _, _, err := models.ParseWeekday(ordinal + name); err != nil {
+		return day, false
 	}
-	
-	// Already in short format or unknown
-	return day
+	return ordinal + name, true
 }
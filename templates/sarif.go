@@ -0,0 +1,130 @@
+package templates
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// sarifLog is the subset of a SARIF 2.1.0 log that diagnostics are reported in
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                    `json:"id,omitempty"`
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+// sarifLocationOf converts a location, reporting false if nothing is known of it
+func sarifLocationOf(l Location) (sarifLocation, bool) {
+	var location sarifLocation
+	if l.File != "" {
+		location.PhysicalLocation = &sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(l.File)},
+		}
+		if l.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: l.Line, StartColumn: l.Column}
+		}
+	}
+	if l.Path != "" {
+		location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: l.Path}}
+	}
+	return location, location.PhysicalLocation != nil || location.LogicalLocations != nil
+}
+
+// WriteSARIF writes diagnostics as a SARIF 2.1.0 log, for code scanning tools and editors
+func WriteSARIF(w io.Writer, diagnostics []Diagnostic, tool, version string) error {
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		result := sarifResult{
+			RuleID:  d.Rule,
+			Level:   string(d.Severity),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.Suggestion != "" {
+			result.Message.Text += "; " + d.Suggestion
+		}
+
+		if location, ok := sarifLocationOf(Location{File: d.File, Path: d.Path, Line: d.Line, Column: d.Column}); ok {
+			result.Locations = []sarifLocation{location}
+		}
+		if d.Related != nil {
+			if location, ok := sarifLocationOf(*d.Related); ok {
+				location.ID = 1
+				result.RelatedLocations = []sarifLocation{location}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	rules := make([]sarifRule, len(LintRules))
+	for i, rule := range LintRules {
+		rules[i] = sarifRule{ID: rule.Name, ShortDescription: sarifMessage{Text: rule.Description}}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: tool, Version: version, Rules: rules}},
+			Results: results,
+		}},
+	})
+}
@@ -0,0 +1,294 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaFormats are the template formats a JSON Schema is generated for
+var SchemaFormats = []TemplateFormat{FormatWeekly, FormatSingle, FormatRecurring, FormatDateRange, FormatBundle}
+
+// Schema is a JSON Schema (draft 2020-12), as far as templates need one
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 schemaType         `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties    map[string]*Schema `json:"patternProperties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	If                   *Schema            `json:"if,omitempty"`
+	Then                 *Schema            `json:"then,omitempty"`
+	Else                 *Schema            `json:"else,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	closed bool // No properties but the listed ones; written as additionalProperties: false
+}
+
+// schemaType is one JSON type, or a list of them
+type schemaType []string
+
+func (t schemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	data, err := json.Marshal((*plain)(s))
+	if err != nil || !s.closed {
+		return data, err
+	}
+	return append(data[:len(data)-1], []byte(`,"additionalProperties":false}`)...), nil
+}
+
+// weekKey matches the keys of the weeks of a weekly template, as parseWeekly reads them
+const weekKey = "^[Ww][Ee][Ee][Kk]"
+
+// SchemaFor returns the JSON Schema of a template format, generated from the
+// structs the format is read into
+func SchemaFor(format TemplateFormat) (*Schema, error) {
+	b := &schemaBuilder{defs: make(map[string]*Schema)}
+
+	var root *Schema
+	switch format {
+	case FormatWeekly:
+		root = b.object(reflect.TypeFor[weeklyTemplate]())
+		root.PatternProperties = map[string]*Schema{weekKey: b.of(reflect.TypeFor[[]WeeklyEvent]())}
+	case FormatSingle:
+		root = b.object(reflect.TypeFor[SingleTemplate]())
+	case FormatRecurring:
+		root = b.object(reflect.TypeFor[RecurringTemplate]())
+	case FormatDateRange:
+		root = b.object(reflect.TypeFor[DateRangeTemplate]())
+	case FormatBundle:
+		root = b.object(reflect.TypeFor[BundleTemplate]())
+		// Each event of the events list is read in the format its fields call
+		// for, as eventFormat picks it
+		event := b.of(reflect.TypeFor[SingleEventInput]())
+		for _, f := range []struct {
+			field string
+			t     reflect.Type
+		}{
+			{"event_name", reflect.TypeFor[WeeklyEvent]()},
+			{"end_date", reflect.TypeFor[DateRangeEventInput]()},
+			{"recurrence", reflect.TypeFor[RecurringEventInput]()},
+		} {
+			event = &Schema{If: &Schema{Required: []string{f.field}}, Then: b.of(f.t), Else: event}
+		}
+		root.Properties["events"].Items = event
+	default:
+		return nil, fmt.Errorf("no schema for template format: %s", format)
+	}
+
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.Title = fmt.Sprintf("Calendar event generator %s template", format)
+	root.Properties["format"].Enum = []string{string(format)}
+	root.Defs = b.defs
+	return root, nil
+}
+
+// schemaBuilder generates schemas for Go types, collecting the schemas of structs as $defs
+type schemaBuilder struct {
+	defs map[string]*Schema
+}
+
+// of returns the schema of values of type t
+func (b *schemaBuilder) of(t reflect.Type) *Schema {
+	switch t {
+	case reflect.TypeFor[VarsInput]():
+		return &Schema{Type: schemaType{"object"}, AdditionalProperties: &Schema{Type: schemaType{"string", "number", "boolean"}}}
+	case reflect.TypeFor[json.RawMessage]():
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.of(t.Elem())
+	case reflect.String:
+		return &Schema{Type: schemaType{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: schemaType{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: schemaType{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: schemaType{"number"}}
+	case reflect.Slice:
+		return &Schema{Type: schemaType{"array"}, Items: b.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: schemaType{"object"}, AdditionalProperties: b.of(t.Elem())}
+	case reflect.Struct:
+		if _, ok := b.defs[t.Name()]; !ok {
+			b.defs[t.Name()] = nil // Reserve the name for structs that contain themselves
+			b.defs[t.Name()] = b.object(t)
+		}
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	}
+	return &Schema{}
+}
+
+// object returns the schema of a struct, with its fields tagged schema:"required" required
+func (b *schemaBuilder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: schemaType{"object"}, Properties: make(map[string]*Schema), closed: true}
+	for name, field := range jsonFields(t) {
+		property := b.of(field.Type)
+		property.Description = fieldHints[name]
+		s.Properties[name] = property
+		if field.Tag.Get("schema") == "required" {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// schemaError is a value that does not match a schema
+type schemaError struct {
+	path, message, suggestion string
+}
+
+// validate returns the values of a document decoded with UseNumber that do
+// not match the schema, whose $defs are in defs
+func (s *Schema) validate(path string, value any, defs map[string]*Schema) []schemaError {
+	if s.Ref != "" {
+		return defs[strings.TrimPrefix(s.Ref, "#/$defs/")].validate(path, value, defs)
+	}
+
+	if s.If != nil {
+		if len(s.If.validate(path, value, defs)) == 0 {
+			return s.Then.validate(path, value, defs)
+		}
+		return s.Else.validate(path, value, defs)
+	}
+
+	if len(s.Type) > 0 && !slices.Contains(s.Type, jsonType(value)) &&
+		!(jsonType(value) == "integer" && slices.Contains(s.Type, "number")) {
+		return []schemaError{{path: path, message: fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), jsonType(value))}}
+	}
+	if len(s.Enum) > 0 {
+		if v, _ := value.(string); !slices.Contains(s.Enum, v) {
+			return []schemaError{{path: path, message: fmt.Sprintf("must be %s", quoteAll(s.Enum))}}
+		}
+	}
+
+	var errs []schemaError
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, schemaError{path: path, message: fmt.Sprintf("missing required field %q", name)})
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			property := s.property(key)
+			if property == nil {
+				errs = append(errs, s.unknownKey(joinPath(path, key), key))
+				continue
+			}
+			errs = append(errs, property.validate(joinPath(path, key), v[key], defs)...)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, defs)...)
+			}
+		}
+	}
+	return errs
+}
+
+// property returns the schema of the property key of an object, or nil if
+// the object may not have it
+func (s *Schema) property(key string) *Schema {
+	if property, ok := s.Properties[key]; ok {
+		return property
+	}
+	for pattern, property := range s.PatternProperties {
+		if regexp.MustCompile(pattern).MatchString(key) {
+			return property
+		}
+	}
+	if s.closed {
+		return nil
+	}
+	if s.AdditionalProperties != nil {
+		return s.AdditionalProperties
+	}
+	return &Schema{}
+}
+
+// unknownKey reports a property an object does not allow, suggesting the one probably meant
+func (s *Schema) unknownKey(path, key string) schemaError {
+	err := schemaError{path: path, message: fmt.Sprintf("unknown field %q", key)}
+	if match := didYouMean(key, sortedKeys(s.Properties)); match != "" {
+		err.suggestion = fmt.Sprintf("did you mean %q?", match)
+	}
+	return err
+}
+
+// quoteAll lists values quoted, e.g. "a" or "b"
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// jsonType returns the JSON Schema type of a value decoded with UseNumber
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
+
+// checkSchema reports the values of a template that do not match the JSON
+// Schema of its format. CSV and ICS files have no schema.
+func (p *Parser) checkSchema(data []byte, format TemplateFormat) {
+	schema, err := SchemaFor(format)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return
+	}
+
+	for _, e := range schema.validate("$", doc, schema.Defs) {
+		p.report(Diagnostic{
+			Severity:   SeverityError,
+			Path:       e.path,
+			Message:    "schema: " + e.message,
+			Suggestion: e.suggestion,
+			err:        fmt.Errorf("%s: %s", e.path, e.message),
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package templates
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	for _, format := range SchemaFormats {
+		schema, err := SchemaFor(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if doc["$schema"] != "https://json-schema.org/draft/2020-12/schema" || doc["additionalProperties"] != false {
+			t.Errorf("%s: got %s, want a closed draft 2020-12 schema", format, data)
+		}
+		if enum := schema.Properties["format"].Enum; len(enum) != 1 || enum[0] != string(format) {
+			t.Errorf("%s: got format %v, want %s", format, enum, format)
+		}
+
+		// Every reference is to a definition of the schema
+		for _, ref := range strings.Split(string(data), `"$ref":"#/$defs/`)[1:] {
+			name, _, _ := strings.Cut(ref, `"`)
+			if _, ok := schema.Defs[name]; !ok {
+				t.Errorf("%s: got a reference to %s, which is not defined", format, name)
+			}
+		}
+	}
+
+	weekly, _ := SchemaFor(FormatWeekly)
+	if _, ok := weekly.PatternProperties[weekKey]; !ok || weekly.property("Week 3") == nil || weekly.property("days") != nil {
+		t.Error("got a weekly schema that does not read any key starting with week as a week")
+	}
+
+	single, _ := SchemaFor(FormatSingle)
+	if got := single.Defs["SingleEventInput"].Required; len(got) != 1 || got[0] != "date" {
+		t.Errorf("got required %v, want [date]", got)
+	}
+
+	if _, err := SchemaFor(FormatCSV); err == nil || err.Error() != "no schema for template format: csv" {
+		t.Errorf("got %v, want no schema for csv", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string // "path: message" of each finding
+	}{
+		{
+			"types",
+			`{"format": "single", "events": [{"name": "Lab", "date": 20270301, "start_time": "09:00", "all_day": "yes", "links": "https://example.com"}]}`,
+			"$.events[0].all_day: schema: expected boolean, got string\n" +
+				"$.events[0].date: schema: expected string, got integer\n" +
+				"$.events[0].links: schema: expected array, got string",
+		},
+		{
+			"required",
+			`{"format": "recurring", "events": [{"name": "Lab", "start_time": "09:00", "recurrence": {"interval": 2}}]}`,
+			`$.events[0].recurrence: schema: missing required field "frequency"`,
+		},
+		{
+			"unknown keys",
+			`{"format": "daterange", "events": [{"name": "Offsite", "start_date": "2027-03-10", "end_dat": "2027-03-12"}]}`,
+			`$.events[0]: schema: missing required field "end_date"` + "\n" +
+				`$.events[0].end_dat: schema: unknown field "end_dat"`,
+		},
+		{
+			"vars",
+			`{"format": "single", "vars": {"room": 1, "remote": true, "building": {"name": "A"}}, "events": []}`,
+			"$.vars.building: schema: expected string or number or boolean, got object",
+		},
+		{
+			"weekly",
+			`{"holidays": {"calendar": "US", "policy": 1}, "week_1": [{"event_name": "Lab", "date": "2027-03-01", "time": "9:00am – 10:00am", "topic_details": "", "useful_links": [1]}]}`,
+			"$.holidays.policy: schema: expected string, got integer\n" +
+				"$.week_1[0].useful_links[0]: schema: expected string, got integer",
+		},
+		{
+			"bundle events",
+			`{"format": "bundle", "events": [
+				{"name": "Lab", "start_time": "09:00", "recurrence": {"frequency": "DAILY", "count": "2"}},
+				{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "all_day": 1}
+			]}`,
+			"$.events[0].recurrence.count: schema: expected integer, got string\n" +
+				"$.events[1].all_day: schema: expected boolean, got integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t)
+			p.CheckSchema = true
+			if _, err := p.Parse([]byte(tt.data), FormatAuto); err == nil {
+				t.Fatal("got no error")
+			}
+			p.sortDiagnostics()
+			var got []string
+			for _, d := range p.Diagnostics {
+				if strings.HasPrefix(d.Message, "schema: ") {
+					got = append(got, d.Path+": "+d.Message)
+				}
+			}
+			if strings.Join(got, "\n") != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSchemaSuggestion(t *testing.T) {
+	p := newTestParser(t)
+	p.CheckSchema = true
+	_, err := p.Parse([]byte(`{"format": "single", "events": [{"name": "Lab", "date": "2027-03-01", "strat_time": "09:00"}]}`), FormatAuto)
+	if err == nil || !strings.Contains(err.Error(), `$.events[0].strat_time: unknown field "strat_time"`) {
+		t.Errorf("got %v, want an unknown field error", err)
+	}
+	if len(p.Diagnostics) == 0 || p.Diagnostics[0].Suggestion != `did you mean "start_time"?` {
+		t.Errorf("got %v, want a suggestion of start_time", p.Diagnostics)
+	}
+}
+
+func TestExamplesMatchSchema(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "examples", "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("got %v, %v, want the example templates", files, err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err = toJSON(file, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := newTestParser(t)
+		p.checkSchema(data, p.detectFormat(data))
+		if len(p.Diagnostics) > 0 {
+			t.Errorf("%s: got %v, want no schema errors", filepath.Base(file), p.Diagnostics)
+		}
+	}
+}
@@ -9,22 +9,30 @@ import (
 
 // SingleEventInput represents a single event in the simple format
 type SingleEventInput struct {
+	ID          string   `json:"id,omitempty"` // Stable identifier used to match existing events
 	Name        string   `json:"name"`
-	Date        string   `json:"date"`
-	StartTime   string   `json:"start_time"`
-	EndTime     string   `json:"end_time"`
+	Date        string   `json:"date" schema:"required"`
+	StartTime   string   `json:"start_time,omitempty"`
+	EndTime     string   `json:"end_time,omitempty"`
 	Duration    string   `json:"duration,omitempty"` // Alternative to end_time
 	Description string   `json:"description,omitempty"`
 	Location    string   `json:"location,omitempty"`
 	Links       []string `json:"links,omitempty"`
 	AllDay      bool     `json:"all_day,omitempty"`
 	ColorID     string   `json:"color_id,omitempty"`
+
+	HolidayPolicy string `json:"holiday_policy,omitempty"` // Overrides the template's holiday policy
 }
 
 // SingleTemplate represents the single events template format
 type SingleTemplate struct {
-	Format string             `json:"format"`
-	Events []SingleEventInput `json:"events"`
+	Format   string             `json:"format"`
+	Holidays *HolidayInput      `json:"holidays,omitempty"`
+	Defaults *DefaultsInput     `json:"defaults,omitempty"`
+	Vars     VarsInput          `json:"vars,omitempty"`
+	Extends  string             `json:"extends,omitempty"`
+	Include  []string           `json:"include,omitempty"`
+	Events   []SingleEventInput `json:"events"`
 }
 
 // parseSingle parses the single event format
@@ -33,14 +41,37 @@ func (p *Parser) parseSingle(data []byte) ([]models.CalendarEvent, error) {
 	if err := json.Unmarshal(data, &template); err != nil {
 		return nil, fmt.Errorf("failed to parse single events JSON: %w", err)
 	}
+	if err := checkEventFormats(data, FormatSingle); err != nil {
+		return nil, err
+	}
+
+	holidays, err := p.loadHolidays(template.Holidays)
+	if err != nil {
+		return nil, err
+	}
+	values := p.loadValues(template.Defaults, template.Vars)
 
 	var events []models.CalendarEvent
-	for _, se := range template.Events {
+	for i, se := range template.Events {
+		path := fmt.Sprintf("$.events[%d]", i)
 		event, err := p.convertSingleEvent(se)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert event '%s': %w", se.Name, err)
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", se.Name, err))
+			continue
+		}
+		if err := values.apply(&event); err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", se.Name, err))
+			continue
+		}
+		keep, err := p.applyHolidays(holidays, &event, se.HolidayPolicy)
+		if err != nil {
+			p.fail(path, fmt.Errorf("failed to convert event '%s': %w", se.Name, err))
+			continue
+		}
+		if keep {
+			p.track(path, event)
+			events = append(events, event)
 		}
-		events = append(events, event)
 	}
 
 	return events, nil
@@ -50,7 +81,7 @@ func (p *Parser) parseSingle(data []byte) ([]models.CalendarEvent, error) {
 func (p *Parser) convertSingleEvent(se SingleEventInput) (models.CalendarEvent, error) {
 	date, err := p.TimeParser.ParseDate(se.Date)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse date: %w", err)
+		return models.CalendarEvent{}, inField("date", badValue(fmt.Errorf("failed to parse date: %w", err)))
 	}
 
 	var startTime, endTime = date, date
@@ -59,7 +90,7 @@ func (p *Parser) convertSingleEvent(se SingleEventInput) (models.CalendarEvent,
 		// Parse start time
 		startHour, startMin, err := p.TimeParser.ParseTime(se.StartTime)
 		if err != nil {
-			return models.CalendarEvent{}, fmt.Errorf("failed to parse start time: %w", err)
+			return models.CalendarEvent{}, inField("start_time", badValue(fmt.Errorf("failed to parse start time: %w", err)))
 		}
 		startTime = p.TimeParser.CombineDateTime(date, startHour, startMin)
 
@@ -67,7 +98,7 @@ func (p *Parser) convertSingleEvent(se SingleEventInput) (models.CalendarEvent,
 		if se.EndTime != "" {
 			endHour, endMin, err := p.TimeParser.ParseTime(se.EndTime)
 			if err != nil {
-				return models.CalendarEvent{}, fmt.Errorf("failed to parse end time: %w", err)
+				return models.CalendarEvent{}, inField("end_time", badValue(fmt.Errorf("failed to parse end time: %w", err)))
 			}
 			endTime = p.TimeParser.CombineDateTime(date, endHour, endMin)
 
@@ -78,7 +109,7 @@ func (p *Parser) convertSingleEvent(se SingleEventInput) (models.CalendarEvent,
 		} else if se.Duration != "" {
 			duration, err := p.TimeParser.ParseDuration(se.Duration)
 			if err != nil {
-				return models.CalendarEvent{}, fmt.Errorf("failed to parse duration: %w", err)
+				return models.CalendarEvent{}, inField("duration", badValue(fmt.Errorf("failed to parse duration: %w", err)))
 			}
 			endTime = startTime.Add(duration)
 		} else {
@@ -91,6 +122,7 @@ func (p *Parser) convertSingleEvent(se SingleEventInput) (models.CalendarEvent,
 	}
 
 	return models.CalendarEvent{
+		ID:          se.ID,
 		Name:        se.Name,
 		Description: se.Description,
 		StartTime:   startTime,
@@ -0,0 +1,243 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// checkStrict reports the keys of a template that its format does not read,
+// as decoding with DisallowUnknownFields would, but for every key at once and
+// with the field that was probably meant
+func (p *Parser) checkStrict(data []byte, format TemplateFormat) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return
+	}
+
+	switch format {
+	case FormatWeekly:
+		p.checkWeeklyFields(doc)
+	case FormatSingle:
+		p.checkFields("$", doc, reflect.TypeFor[SingleTemplate]())
+	case FormatRecurring:
+		p.checkFields("$", doc, reflect.TypeFor[RecurringTemplate]())
+	case FormatDateRange:
+		p.checkFields("$", doc, reflect.TypeFor[DateRangeTemplate]())
+	case FormatBundle:
+		p.checkFields("$", doc, reflect.TypeFor[BundleTemplate]())
+
+		// Events of the events list are read in the format their fields call for
+		events, _ := doc["events"].([]any)
+		for i, event := range events {
+			fields, ok := event.(map[string]any)
+			if !ok {
+				continue
+			}
+			raw := make(map[string]json.RawMessage, len(fields))
+			for key := range fields {
+				raw[key] = json.RawMessage("null")
+			}
+			p.checkFields(fmt.Sprintf("$.events[%d]", i), fields, eventType(eventFormat(raw)))
+		}
+	}
+}
+
+// eventType returns the input struct of the events of a format
+func eventType(format TemplateFormat) reflect.Type {
+	switch format {
+	case FormatRecurring:
+		return reflect.TypeFor[RecurringEventInput]()
+	case FormatDateRange:
+		return reflect.TypeFor[DateRangeEventInput]()
+	case FormatWeekly:
+		return reflect.TypeFor[WeeklyEvent]()
+	}
+	return reflect.TypeFor[SingleEventInput]()
+}
+
+// weeklyTemplate is the fields of a weekly template besides its weeks, which
+// are any keys starting with "week"
+type weeklyTemplate struct {
+	Format   string         `json:"format"`
+	Holidays *HolidayInput  `json:"holidays,omitempty"`
+	Defaults *DefaultsInput `json:"defaults,omitempty"`
+	Vars     VarsInput      `json:"vars,omitempty"`
+	Extends  string         `json:"extends,omitempty"`
+	Include  []string       `json:"include,omitempty"`
+}
+
+// checkWeeklyFields checks a weekly template, whose week keys are free-form
+func (p *Parser) checkWeeklyFields(doc map[string]any) {
+	options := make(map[string]any, len(doc))
+	for key, value := range doc {
+		if strings.HasPrefix(strings.ToLower(key), "week") {
+			p.checkFields(joinPath("$", key), value, reflect.TypeFor[[]WeeklyEvent]())
+		} else {
+			options[key] = value
+		}
+	}
+	p.checkFields("$", options, reflect.TypeFor[weeklyTemplate]())
+}
+
+// checkFields reports the keys of a decoded JSON value that type t has no
+// field for, and checks the values of the fields it has
+func (p *Parser) checkFields(path string, value any, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		return // Reads its own JSON, such as vars
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		known := make([]string, 0, len(fields))
+		for name := range fields {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+
+		for _, key := range sortedKeys(object) {
+			field, ok := fields[key]
+			if !ok {
+				// Keys are matched to fields regardless of case, as json does
+				for name, f := range fields {
+					if strings.EqualFold(name, key) {
+						field, ok = f, true
+						break
+					}
+				}
+			}
+			if !ok {
+				p.unknownField(path, key, known)
+				continue
+			}
+			p.checkFields(joinPath(path, key), object[key], field.Type)
+		}
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok || t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i, item := range items {
+			p.checkFields(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+		}
+	case reflect.Map:
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		for _, key := range sortedKeys(entries) {
+			p.checkFields(joinPath(path, key), entries[key], t.Elem())
+		}
+	}
+}
+
+// jsonFields returns the fields of a struct by their JSON key
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// unknownField records an error for a key that is not one of the known fields
+func (p *Parser) unknownField(path, key string, known []string) {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Path:     joinPath(path, key),
+		Message:  fmt.Sprintf("unknown field %q", key),
+	}
+	if match := didYouMean(key, known); match != "" {
+		d.Suggestion = fmt.Sprintf("did you mean %q?", match)
+	} else {
+		d.Suggestion = "known fields are " + strings.Join(known, ", ")
+	}
+	d.err = fmt.Errorf("%s: %s", d.Path, d.Message)
+	p.report(d)
+}
+
+// didYouMean returns the known name closest to a misspelt one, or "" if none is close
+func didYouMean(name string, known []string) string {
+	normalize := strings.NewReplacer("-", "_", " ", "_").Replace
+	name = normalize(strings.ToLower(name))
+
+	best, bestDistance := "", max(2, len(name)/3)+1
+	for _, candidate := range known {
+		if d := levenshtein(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance <= 1 {
+		return best
+	}
+
+	// A field the name is a word of, e.g. start_date for date, is more
+	// likely meant than one a few letters away
+	for _, candidate := range known {
+		if slices.Contains(strings.Split(candidate, "_"), name) {
+			return candidate
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, candidate := range known {
+		if len(name) >= 4 && strings.Contains(candidate, name) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// levenshtein returns the number of single character edits between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+// strictFindings formats the diagnostics as "path: suggestion" for comparison
+func strictFindings(diagnostics []Diagnostic) string {
+	var list []string
+	for _, d := range diagnostics {
+		list = append(list, d.Path+": "+d.Suggestion)
+	}
+	return strings.Join(list, "\n")
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string // Findings, as formatted by strictFindings
+	}{
+		{
+			"single",
+			`{"format": "single", "defaults": {"loction": "Room 1"}, "events": [
+				{"name": "Lab", "date": "2027-03-01", "start_time": "09:00", "end": "10:00", "Location": "Room 2"}
+			]}`,
+			`$.defaults.loction: did you mean "location"?` + "\n" +
+				`$.events[0].end: did you mean "end_time"?`,
+		},
+		{
+			"recurring",
+			`{"format": "recurring", "defaults": {"reminders": [{"method": "popup", "minute": 10}]}, "events": [
+				{"name": "Lab", "startdate": "2027-03-01", "start_time": "09:00",
+					"recurrence": {"frequency": "WEEKLY", "count": 2, "byday": ["MO"]}}
+			]}`,
+			`$.defaults.reminders[0].minute: did you mean "minutes"?` + "\n" +
+				`$.events[0].recurrence.byday: did you mean "by_day"?` + "\n" +
+				`$.events[0].startdate: did you mean "start_date"?`,
+		},
+		{
+			"weekly",
+			`{"defaults": {"color_id": "3"}, "holiday": {"country": "US"},
+				"week_1": [{"event_name": "Lab", "date": "2027-03-01", "time": "9:00am – 10:00am", "topic_details": "", "notes": "x"}]}`,
+			`$.week_1[0].notes: known fields are date, description, event_name, holiday_policy, id, location, time, topic_details, useful_links` + "\n" +
+				`$.holiday: did you mean "holidays"?`,
+		},
+		{
+			"bundle",
+			`{"format": "bundle", "events": [
+				{"name": "Lab", "start_time": "09:00", "recurrence": {"frequency": "DAILY", "count": 2}, "untill": "2027-03-05"},
+				{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "allday": true}
+			]}`,
+			`$.events[0].untill: known fields are ` + strings.Join(sortedKeys(jsonFields(eventType(FormatRecurring))), ", ") + "\n" +
+				`$.events[1].allday: did you mean "all_day"?`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser(t)
+			p.Strict = true
+			if _, err := p.Parse([]byte(tt.data), FormatAuto); err == nil || !strings.Contains(err.Error(), "unknown field") {
+				t.Fatalf("got %v, want an unknown field error", err)
+			}
+			p.sortDiagnostics()
+			if got := strictFindings(p.Diagnostics); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictOff(t *testing.T) {
+	// Without strict mode unknown keys are ignored
+	events := parse(t, newTestParser(t), `{"format": "single", "events": [
+		{"name": "Lab", "date": "2027-03-01", "start_time": "09:00", "loction": "Room 1"}
+	]}`)
+	if len(events) != 1 || events[0].Location != "" {
+		t.Errorf("got %+v, want the event without a location", events)
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	known := []string{"all_day", "date", "end_date", "end_time", "location", "start_date", "start_time"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"locaton", "location"},
+		{"Start-Time", "start_time"},
+		{"all day", "all_day"},
+		{"end", "end_date"},
+		{"starttime", "start_time"},
+		{"colour", ""},
+	}
+	for _, tt := range tests {
+		if got := didYouMean(tt.name, known); got != tt.want {
+			t.Errorf("got %q, want %q for %s", got, tt.want, tt.name)
+		}
+	}
+}
@@ -11,23 +11,51 @@ import (
 
 // WeeklyEvent represents an event in the weekly schedule format
 type WeeklyEvent struct {
+	ID           string   `json:"id,omitempty"`
 	EventName    string   `json:"event_name"`
-	Date         string   `json:"date"`
-	Time         string   `json:"time"`
+	Date         string   `json:"date" schema:"required"`
+	Time         string   `json:"time" schema:"required"`
 	TopicDetails string   `json:"topic_details"`
 	UsefulLinks  []string `json:"useful_links"`
 	Location     string   `json:"location,omitempty"`
 	Description  string   `json:"description,omitempty"`
+
+	HolidayPolicy string `json:"holiday_policy,omitempty"` // Overrides the template's holiday policy
 }
 
 // parseWeekly parses the weekly schedule format
-// Format: { "week_1": [...], "week_2": [...] }
+// Format: { "week_1": [...], "week_2": [...], "holidays": {...} }
 func (p *Parser) parseWeekly(data []byte) ([]models.CalendarEvent, error) {
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse weekly JSON: %w", err)
 	}
 
+	var holidayInput *HolidayInput
+	if rawHolidays, ok := raw["holidays"]; ok {
+		if err := json.Unmarshal(rawHolidays, &holidayInput); err != nil {
+			return nil, fmt.Errorf("failed to parse holidays: %w", err)
+		}
+	}
+	holidays, err := p.loadHolidays(holidayInput)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults *DefaultsInput
+	if rawDefaults, ok := raw["defaults"]; ok {
+		if err := json.Unmarshal(rawDefaults, &defaults); err != nil {
+			return nil, fmt.Errorf("failed to parse defaults: %w", err)
+		}
+	}
+	var vars VarsInput
+	if rawVars, ok := raw["vars"]; ok {
+		if err := json.Unmarshal(rawVars, &vars); err != nil {
+			return nil, fmt.Errorf("failed to parse vars: %w", err)
+		}
+	}
+	values := p.loadValues(defaults, vars)
+
 	var events []models.CalendarEvent
 
 	// Sort keys to process weeks in order
@@ -43,15 +71,30 @@ func (p *Parser) parseWeekly(data []byte) ([]models.CalendarEvent, error) {
 	for _, weekKey := range keys {
 		var weekEvents []WeeklyEvent
 		if err := json.Unmarshal(raw[weekKey], &weekEvents); err != nil {
-			return nil, fmt.Errorf("failed to parse week %s: %w", weekKey, err)
+			p.fail("$", inField(weekKey, fmt.Errorf("failed to parse week %s: %w", weekKey, err)))
+			continue
 		}
 
-		for _, we := range weekEvents {
+		for i, we := range weekEvents {
+			path := fmt.Sprintf("$.%s[%d]", weekKey, i)
 			event, err := p.convertWeeklyEvent(we)
 			if err != nil {
-				return nil, fmt.Errorf("failed to convert event '%s': %w", we.EventName, err)
+				p.fail(path, fmt.Errorf("failed to convert event '%s': %w", we.EventName, err))
+				continue
+			}
+			if err := values.apply(&event); err != nil {
+				p.fail(path, fmt.Errorf("failed to convert event '%s': %w", we.EventName, err))
+				continue
+			}
+			keep, err := p.applyHolidays(holidays, &event, we.HolidayPolicy)
+			if err != nil {
+				p.fail(path, fmt.Errorf("failed to convert event '%s': %w", we.EventName, err))
+				continue
+			}
+			if keep {
+				p.track(path, event)
+				events = append(events, event)
 			}
-			events = append(events, event)
 		}
 	}
 
@@ -63,7 +106,11 @@ func (p *Parser) convertWeeklyEvent(we WeeklyEvent) (models.CalendarEvent, error
 	// Parse date and time range
 	startTime, endTime, err := p.TimeParser.ParseDateTimeRange(we.Date, we.Time)
 	if err != nil {
-		return models.CalendarEvent{}, fmt.Errorf("failed to parse date/time: %w", err)
+		field := "time"
+		if _, err := p.TimeParser.ParseDate(we.Date); err != nil {
+			field = "date"
+		}
+		return models.CalendarEvent{}, inField(field, badValue(fmt.Errorf("failed to parse date/time: %w", err)))
 	}
 
 	// Build description from topic details
@@ -76,6 +123,7 @@ func (p *Parser) convertWeeklyEvent(we WeeklyEvent) (models.CalendarEvent, error
 	}
 
 	return models.CalendarEvent{
+		ID:          we.ID,
 		Name:        we.EventName,
 		Description: description,
 		StartTime:   startTime,
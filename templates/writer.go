@@ -0,0 +1,512 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/monil/calendar-event-generator/exporter"
+	"github.com/monil/calendar-event-generator/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Encoding is the syntax a structured template is written in
+type Encoding string
+
+const (
+	EncodingJSON Encoding = "json"
+	EncodingYAML Encoding = "yaml"
+	EncodingTOML Encoding = "toml"
+)
+
+// EncodingFor returns the encoding matching a file name's extension, JSON by default
+func EncodingFor(filename string) Encoding {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return EncodingYAML
+	case ".toml":
+		return EncodingTOML
+	}
+	return EncodingJSON
+}
+
+// Write writes events as a template of the given format: single, recurring,
+// daterange or bundle written in enc, FormatAuto to pick whichever of those
+// holds the events, csv or ics. It returns warnings about details the format cannot hold.
+func Write(w io.Writer, events []models.CalendarEvent, format TemplateFormat, enc Encoding) ([]string, error) {
+	tw := &templateWriter{}
+
+	if format == FormatAuto || format == "" {
+		format = chooseFormat(events)
+	}
+
+	var template any
+	var err error
+	switch format {
+	case FormatSingle:
+		template, err = tw.single(events)
+	case FormatRecurring:
+		template, err = tw.recurring(events)
+	case FormatDateRange:
+		template, err = tw.dateRange(events)
+	case FormatBundle:
+		template, err = tw.bundle(events)
+	case FormatCSV:
+		return tw.warnings, tw.csv(w, events)
+	case FormatICS:
+		return nil, exporter.GenerateICS(events, w)
+	default:
+		return nil, fmt.Errorf("cannot write %s templates", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encode(template, enc)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	return tw.warnings, err
+}
+
+// chooseFormat picks the structured format that holds every event: recurring
+// when all events recur, bundle when only some do, daterange when some all-day
+// events span several days, single otherwise
+func chooseFormat(events []models.CalendarEvent) TemplateFormat {
+	recurring, multiDay := 0, false
+	for _, e := range events {
+		if e.Recurrence != nil {
+			recurring++
+		}
+		if _, _, ok := singleDay(e); !ok {
+			multiDay = true
+		}
+	}
+
+	switch {
+	case recurring > 0 && recurring == len(events):
+		return FormatRecurring
+	case recurring > 0:
+		return FormatBundle
+	case multiDay:
+		return FormatDateRange
+	}
+	return FormatSingle
+}
+
+// templateWriter converts events to template inputs, collecting warnings
+type templateWriter struct {
+	warnings []string
+}
+
+func (tw *templateWriter) warnf(format string, args ...any) {
+	tw.warnings = append(tw.warnings, fmt.Sprintf(format, args...))
+}
+
+// dropped warns about the parts of an event no template format holds
+func (tw *templateWriter) dropped(e models.CalendarEvent) {
+	if len(e.Reminders) > 0 {
+		tw.warnf("reminders of '%s' are not kept", e.Name)
+	}
+}
+
+// single converts events to the single format
+func (tw *templateWriter) single(events []models.CalendarEvent) (SingleTemplate, error) {
+	template := SingleTemplate{Format: string(FormatSingle), Events: []SingleEventInput{}}
+	for _, e := range events {
+		if e.Recurrence != nil || e.Exceptions != nil {
+			return template, fmt.Errorf("event '%s' recurs and cannot be written as a single event; use the recurring format", e.Name)
+		}
+		endTime, duration, ok := singleDay(e)
+		if !ok {
+			return template, fmt.Errorf("event '%s' spans several days and cannot be written as a single event; use the daterange format", e.Name)
+		}
+		tw.dropped(e)
+
+		in := SingleEventInput{
+			ID:          e.ID,
+			Name:        e.Name,
+			Date:        formatDate(e.StartTime),
+			Description: e.Description,
+			Location:    e.Location,
+			Links:       e.Links,
+			AllDay:      e.AllDay,
+			ColorID:     e.ColorID,
+		}
+		if !e.AllDay {
+			in.StartTime = formatClock(e.StartTime)
+			in.EndTime = endTime
+			in.Duration = duration
+		}
+		template.Events = append(template.Events, in)
+	}
+	return template, nil
+}
+
+// recurring converts events to the recurring format
+func (tw *templateWriter) recurring(events []models.CalendarEvent) (RecurringTemplate, error) {
+	template := RecurringTemplate{Format: string(FormatRecurring), Events: []RecurringEventInput{}}
+	for _, e := range events {
+		if e.Recurrence == nil {
+			return template, fmt.Errorf("event '%s' does not recur and cannot be written as a recurring event", e.Name)
+		}
+		if e.AllDay {
+			return template, fmt.Errorf("event '%s' is an all-day series, which the recurring format cannot hold; use ics", e.Name)
+		}
+		endTime, duration, _ := singleDay(e)
+		tw.dropped(e)
+
+		in := RecurringEventInput{
+			ID:          e.ID,
+			Name:        e.Name,
+			StartDate:   formatDate(e.StartTime),
+			StartTime:   formatClock(e.StartTime),
+			EndTime:     endTime,
+			Duration:    duration,
+			Description: e.Description,
+			Location:    e.Location,
+			Links:       e.Links,
+			Recurrence:  recurrenceInput(e),
+			ColorID:     e.ColorID,
+		}
+		tw.exceptions(e, &in)
+		template.Events = append(template.Events, in)
+	}
+	return template, nil
+}
+
+// recurrenceInput converts the recurrence rule of an event
+func recurrenceInput(e models.CalendarEvent) RecurrenceInput {
+	r := e.Recurrence
+	in := RecurrenceInput{
+		Frequency:  r.Frequency,
+		Count:      r.Count,
+		ByDay:      r.ByDay,
+		ByMonthDay: r.ByMonthDay,
+		ByMonth:    r.ByMonth,
+		BySetPos:   r.BySetPos,
+		ByYearDay:  r.ByYearDay,
+		ByWeekNo:   r.ByWeekNo,
+		WeekStart:  r.WeekStart,
+	}
+	if r.Interval > 1 {
+		in.Interval = r.Interval
+	}
+	if r.Until != nil {
+		in.Until = formatDate(r.Until.In(e.StartTime.Location()))
+	}
+	if r.ExcludeWeekends && slices.Equal(r.ByDay, []string{"MO", "TU", "WE", "TH", "FR"}) {
+		in.ExcludeWeekends = true
+		in.ByDay = nil
+	}
+	return in
+}
+
+// exceptions converts the exceptions of a recurring event. The recurring
+// format places excluded and extra dates at the series time and keeps
+// overrides on their original day, so anything else is dropped with a warning.
+func (tw *templateWriter) exceptions(e models.CalendarEvent, in *RecurringEventInput) {
+	x := e.Exceptions
+	if x == nil {
+		return
+	}
+
+	atSeriesTime := func(t time.Time) bool {
+		return formatClock(t) == formatClock(e.StartTime)
+	}
+
+	for _, t := range x.ExcludeDates {
+		if !atSeriesTime(t) {
+			tw.warnf("excluded occurrence of '%s' at %s is not at the series time and is not kept", e.Name, t.Format(time.RFC3339))
+			continue
+		}
+		in.ExcludeDates = append(in.ExcludeDates, formatDate(t))
+	}
+	for _, t := range x.ExtraDates {
+		if !atSeriesTime(t) {
+			tw.warnf("extra occurrence of '%s' at %s is not at the series time and is not kept", e.Name, t.Format(time.RFC3339))
+			continue
+		}
+		in.ExtraDates = append(in.ExtraDates, formatDate(t))
+	}
+
+	for _, o := range x.Overrides {
+		if formatDate(o.StartTime) != formatDate(o.OriginalStart) {
+			tw.warnf("occurrence of '%s' on %s moved to another day and is not kept", e.Name, formatDate(o.OriginalStart))
+			continue
+		}
+		override := OverrideInput{
+			Date:        formatDate(o.OriginalStart),
+			Location:    o.Location,
+			Description: o.Description,
+		}
+		if !atSeriesTime(o.StartTime) {
+			override.StartTime = formatClock(o.StartTime)
+		}
+		if o.EndTime.Sub(o.StartTime) != e.EndTime.Sub(e.StartTime) {
+			override.Duration = formatDuration(o.EndTime.Sub(o.StartTime))
+		}
+		in.Overrides = append(in.Overrides, override)
+	}
+}
+
+// dateRange converts events to the daterange format
+func (tw *templateWriter) dateRange(events []models.CalendarEvent) (DateRangeTemplate, error) {
+	template := DateRangeTemplate{Format: string(FormatDateRange), Events: []DateRangeEventInput{}}
+	for _, e := range events {
+		if e.Recurrence != nil || e.Exceptions != nil {
+			return template, fmt.Errorf("event '%s' recurs and cannot be written as a date range; use the recurring format", e.Name)
+		}
+		tw.dropped(e)
+
+		in := DateRangeEventInput{
+			ID:          e.ID,
+			Name:        e.Name,
+			StartDate:   formatDate(e.StartTime),
+			EndDate:     formatDate(e.EndTime),
+			AllDay:      e.AllDay,
+			Description: e.Description,
+			Location:    e.Location,
+			Links:       e.Links,
+			ColorID:     e.ColorID,
+		}
+		if e.AllDay {
+			in.EndDate = formatDate(lastDay(e))
+		} else {
+			in.StartTime = formatClock(e.StartTime)
+			in.EndTime = formatClock(e.EndTime)
+		}
+		template.Events = append(template.Events, in)
+	}
+	return template, nil
+}
+
+// bundle converts events to the bundle format, each in the section of the
+// format chosen for it alone
+func (tw *templateWriter) bundle(events []models.CalendarEvent) (BundleTemplate, error) {
+	template := BundleTemplate{Format: string(FormatBundle)}
+	for _, e := range events {
+		one := []models.CalendarEvent{e}
+		switch chooseFormat(one) {
+		case FormatRecurring:
+			t, err := tw.recurring(one)
+			if err != nil {
+				return template, err
+			}
+			template.Recurring = append(template.Recurring, t.Events...)
+		case FormatDateRange:
+			t, err := tw.dateRange(one)
+			if err != nil {
+				return template, err
+			}
+			template.DateRange = append(template.DateRange, t.Events...)
+		default:
+			t, err := tw.single(one)
+			if err != nil {
+				return template, err
+			}
+			template.Single = append(template.Single, t.Events...)
+		}
+	}
+	return template, nil
+}
+
+// csv writes events as a CSV file with the columns parseCSV recognises.
+// Columns no event uses are left out.
+func (tw *templateWriter) csv(w io.Writer, events []models.CalendarEvent) error {
+	columns := []string{"id", "name", "date", "end_date", "start", "end", "duration", "all_day", "location", "description", "links", "color", "recurrence"}
+
+	rows := make([]map[string]string, 0, len(events))
+	used := map[string]bool{"name": true, "date": true}
+	for _, e := range events {
+		if e.Exceptions != nil {
+			tw.warnf("exceptions of '%s' are not kept in CSV", e.Name)
+		}
+		tw.dropped(e)
+
+		row := map[string]string{
+			"id":          e.ID,
+			"name":        e.Name,
+			"date":        formatDate(e.StartTime),
+			"location":    e.Location,
+			"description": e.Description,
+			"links":       strings.Join(e.Links, " "),
+			"color":       e.ColorID,
+		}
+		if e.Recurrence != nil {
+			row["recurrence"] = strings.TrimPrefix(e.Recurrence.ToRRuleString(), "RRULE:")
+		}
+
+		endTime, duration, ok := singleDay(e)
+		if e.AllDay {
+			row["all_day"] = "true"
+			if !ok {
+				row["end_date"] = formatDate(lastDay(e))
+			}
+		} else {
+			row["start"] = formatClock(e.StartTime)
+			row["end"] = endTime
+			row["duration"] = duration
+		}
+
+		for column, value := range row {
+			if value != "" {
+				used[column] = true
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var header []string
+	for _, column := range columns {
+		if used[column] {
+			header = append(header, column)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, column := range header {
+			record[i] = row[column]
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// singleDay describes how the end of an event is written in formats with a
+// single date: as an end time when it ends the same day or overnight, as a
+// duration otherwise. ok is false for all-day events spanning several days,
+// which need an end date instead.
+func singleDay(e models.CalendarEvent) (endTime, duration string, ok bool) {
+	start, end := e.StartTime, e.EndTime.In(e.StartTime.Location())
+	days := int(dateOf(end).Sub(dateOf(start)).Hours()/24 + 0.5)
+
+	if e.AllDay {
+		return "", "", days <= 1
+	}
+	switch {
+	case days == 0:
+		return formatClock(end), "", true
+	case days == 1 && formatClock(end) < formatClock(start):
+		// Read back as ending the next day
+		return formatClock(end), "", true
+	}
+	return "", formatDuration(end.Sub(start)), true
+}
+
+// lastDay returns the last day of an all-day event, whose end is exclusive
+func lastDay(e models.CalendarEvent) time.Time {
+	if !e.EndTime.After(e.StartTime) {
+		return e.StartTime
+	}
+	return e.EndTime.AddDate(0, 0, -1)
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func formatClock(t time.Time) string {
+	if t.Second() != 0 {
+		return t.Format("15:04:05")
+	}
+	return t.Format("15:04")
+}
+
+// formatDuration formats a duration the way templates write it, e.g. "1h30m"
+func formatDuration(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+// encode serializes a template in the given encoding, keeping the JSON field
+// names and, for YAML, their order
+func encode(template any, enc Encoding) ([]byte, error) {
+	data, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch enc {
+	case EncodingJSON, "":
+		return append(data, '\n'), nil
+	case EncodingYAML:
+		// JSON is YAML; re-emit it in block style
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		blockStyle(&node)
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingTOML:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(integers(doc)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown encoding: %s", enc)
+}
+
+// blockStyle clears the flow and quoting styles JSON input leaves on YAML nodes
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// integers turns the json.Numbers of a decoded document into int64 or float64
+func integers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = integers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = integers(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
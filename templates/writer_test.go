@@ -0,0 +1,186 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monil/calendar-event-generator/models"
+)
+
+// sortedJSON returns the events as JSON, sorted so that their order does not matter
+func sortedJSON(t *testing.T, events []models.CalendarEvent) []string {
+	t.Helper()
+	var list []string
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, string(data))
+	}
+	slices.Sort(list)
+	return list
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	single := `{"format": "single", "events": [
+		{"id": "kickoff", "name": "Kickoff", "date": "2027-03-01", "start_time": "09:00", "end_time": "10:30",
+		 "description": "First day", "location": "Hall A", "links": ["https://example.com/a"], "color_id": "5"},
+		{"name": "Night shift", "date": "2027-03-02", "start_time": "22:00", "end_time": "06:00"},
+		{"name": "Hackathon", "date": "2027-03-03", "start_time": "09:00", "duration": "30h"},
+		{"name": "Reading day", "date": "2027-03-04", "all_day": true}
+	]}`
+	recurring := `{"format": "recurring", "events": [
+		{"name": "Lecture", "start_date": "2027-03-01", "start_time": "09:00", "duration": "1h30m", "location": "Hall A",
+		 "recurrence": {"frequency": "WEEKLY", "by_day": ["MO", "WE"], "until": "2027-04-30"},
+		 "exclude_dates": ["2027-03-08"], "extra_dates": ["2027-03-12"],
+		 "overrides": [{"date": "2027-03-10", "start_time": "13:00", "location": "Hall B"}]},
+		{"name": "Standup", "start_date": "2027-03-01", "start_time": "09:30", "duration": "15m",
+		 "recurrence": {"frequency": "DAILY", "count": 20, "exclude_weekends": true}},
+		{"name": "Board", "start_date": "2027-03-01", "start_time": "16:00", "end_time": "18:00",
+		 "recurrence": {"frequency": "MONTHLY", "interval": 2, "by_day": ["MO", "TU", "WE", "TH", "FR"], "by_set_pos": [-1], "count": 3}}
+	]}`
+	dateRange := `{"format": "daterange", "events": [
+		{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "all_day": true},
+		{"name": "Conference", "start_date": "2027-03-15", "end_date": "2027-03-17", "start_time": "09:00", "end_time": "17:00"}
+	]}`
+	mixed := `{"format": "bundle",
+		"single": [{"name": "Kickoff", "date": "2027-03-01", "start_time": "09:00"}],
+		"recurring": [{"name": "Lecture", "start_date": "2027-03-01", "start_time": "09:00", "recurrence": {"frequency": "WEEKLY", "count": 4}}],
+		"daterange": [{"name": "Offsite", "start_date": "2027-03-10", "end_date": "2027-03-12", "all_day": true}]
+	}`
+
+	tests := []struct {
+		name    string
+		source  string
+		formats []TemplateFormat
+	}{
+		{"single", single, []TemplateFormat{FormatAuto, FormatSingle, FormatBundle, FormatCSV, FormatICS}},
+		{"recurring", recurring, []TemplateFormat{FormatAuto, FormatRecurring, FormatBundle, FormatICS}},
+		{"daterange", dateRange, []TemplateFormat{FormatAuto, FormatDateRange, FormatBundle, FormatCSV, FormatICS}},
+		{"bundle", mixed, []TemplateFormat{FormatAuto, FormatBundle, FormatCSV, FormatICS}},
+	}
+
+	for _, tt := range tests {
+		events := parse(t, newTestParser(t), tt.source)
+
+		for _, format := range tt.formats {
+			want := sortedJSON(t, events)
+			if format == FormatICS {
+				// iCalendar has no template IDs or colors, and spells
+				// exclude_weekends out as weekdays
+				plain := slices.Clone(events)
+				for i := range plain {
+					plain[i].ID, plain[i].ColorID = "", ""
+					if r := plain[i].Recurrence; r != nil {
+						r := *r
+						r.ExcludeWeekends = false
+						plain[i].Recurrence = &r
+					}
+				}
+				want = sortedJSON(t, plain)
+			}
+
+			for _, file := range []string{"out.json", "out.yaml", "out.toml"} {
+				switch format {
+				case FormatCSV:
+					file = "out.csv"
+				case FormatICS:
+					file = "out.ics"
+				}
+
+				t.Run(tt.name+" to "+string(format)+" "+file, func(t *testing.T) {
+					var buf bytes.Buffer
+					warnings, err := Write(&buf, events, format, EncodingFor(file))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(warnings) > 0 {
+						t.Errorf("got warnings %q, want none", warnings)
+					}
+
+					dir := writeFiles(t, map[string]string{file: buf.String()})
+					got, err := newTestParser(t).ParseFile(filepath.Join(dir, file), FormatAuto)
+					if err != nil {
+						t.Fatalf("%v in\n%s", err, buf.String())
+					}
+					if got := sortedJSON(t, got); !slices.Equal(got, want) {
+						t.Errorf("got\n%s\nwant\n%s\nfrom\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"), buf.String())
+					}
+				})
+
+				if format == FormatCSV || format == FormatICS {
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestWriteChoosesFormat(t *testing.T) {
+	tests := []struct {
+		source string
+		want   TemplateFormat
+	}{
+		{`{"format": "single", "events": [{"name": "A", "date": "2027-03-01", "start_time": "09:00"}]}`, FormatSingle},
+		{`{"format": "daterange", "events": [{"name": "A", "start_date": "2027-03-01", "end_date": "2027-03-02", "all_day": true}]}`, FormatDateRange},
+		{`{"format": "recurring", "events": [{"name": "A", "start_time": "09:00", "recurrence": {"frequency": "DAILY", "count": 2}}]}`, FormatRecurring},
+		{`{"format": "bundle", "single": [{"name": "A", "date": "2027-03-01", "start_time": "09:00"}],
+			"recurring": [{"name": "B", "start_time": "09:00", "recurrence": {"frequency": "DAILY", "count": 2}}]}`, FormatBundle},
+	}
+
+	for _, tt := range tests {
+		if got := chooseFormat(parse(t, newTestParser(t), tt.source)); got != tt.want {
+			t.Errorf("got %s, want %s for %s", got, tt.want, tt.source)
+		}
+	}
+}
+
+func TestWriteLoses(t *testing.T) {
+	events := parse(t, newTestParser(t), `{"format": "recurring", "events": [
+		{"name": "Lecture", "start_date": "2027-03-01", "start_time": "09:00", "duration": "1h",
+		 "recurrence": {"frequency": "DAILY", "count": 5},
+		 "overrides": [{"date": "2027-03-02", "start_time": "13:00"}]}
+	]}`)
+	events[0].Reminders = []models.Reminder{{Method: "popup", Minutes: 10}}
+	events[0].Exceptions.ExcludeDates = append(events[0].Exceptions.ExcludeDates, events[0].StartTime.Add(26*time.Hour))
+	events[0].Exceptions.Overrides = append(events[0].Exceptions.Overrides, models.Override{
+		OriginalStart: events[0].StartTime.AddDate(0, 0, 3),
+		StartTime:     events[0].StartTime.AddDate(0, 0, 4),
+		EndTime:       events[0].EndTime.AddDate(0, 0, 4),
+	})
+
+	var buf bytes.Buffer
+	warnings, err := Write(&buf, events, FormatRecurring, EncodingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"reminders of 'Lecture' are not kept",
+		"excluded occurrence of 'Lecture' at 2027-03-02T11:00:00Z is not at the series time and is not kept",
+		"occurrence of 'Lecture' on 2027-03-04 moved to another day and is not kept",
+	}
+	if !slices.Equal(warnings, want) {
+		t.Errorf("got %q, want %q", warnings, want)
+	}
+
+	warnings, err = Write(&buf, events, FormatCSV, EncodingJSON)
+	if err != nil || len(warnings) != 2 || warnings[0] != "exceptions of 'Lecture' are not kept in CSV" {
+		t.Errorf("got %q, %v, want the exceptions and reminders dropped", warnings, err)
+	}
+
+	for format, want := range map[TemplateFormat]string{
+		FormatSingle:    "use the recurring format",
+		FormatDateRange: "use the recurring format",
+		FormatWeekly:    "cannot write weekly templates",
+	} {
+		if _, err := Write(&buf, events, format, EncodingJSON); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", format, err, want)
+		}
+	}
+}